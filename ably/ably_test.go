@@ -7,7 +7,9 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -92,7 +94,7 @@ func (ms messages) Receive(m *ably.Message) {
 
 type connMock struct {
 	SendFunc    func(*ably.ProtocolMessage) error
-	ReceiveFunc func(deadline time.Time) (*ably.ProtocolMessage, error)
+	ReceiveFunc func(timeout time.Duration) (*ably.ProtocolMessage, error)
 	CloseFunc   func() error
 }
 
@@ -100,8 +102,8 @@ func (r connMock) Send(a0 *ably.ProtocolMessage) error {
 	return r.SendFunc(a0)
 }
 
-func (r connMock) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
-	return r.ReceiveFunc(deadline)
+func (r connMock) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
+	return r.ReceiveFunc(timeout)
 }
 
 func (r connMock) Close() error {
@@ -110,16 +112,6 @@ func (r connMock) Close() error {
 
 type MessagePipeOption func(*pipeConn)
 
-// MessagePipeWithNowFunc sets a function to get the current time. This time
-// will be used to determine whether a Receive times out.
-//
-// If not set, receives won't timeout.
-func MessagePipeWithNowFunc(now func() time.Time) MessagePipeOption {
-	return func(pc *pipeConn) {
-		pc.now = now
-	}
-}
-
 // MessagePipeWithAfterFunc sets a function to get a timer. This timer
 // will be used to determine whether a Receive times out.
 //
@@ -133,9 +125,8 @@ func MessagePipeWithAfterFunc(after func(context.Context, time.Duration) <-chan
 func MessagePipe(in <-chan *ably.ProtocolMessage, out chan<- *ably.ProtocolMessage, opts ...MessagePipeOption) func(string, *url.URL, time.Duration) (ably.Conn, error) {
 	return func(proto string, u *url.URL, timeout time.Duration) (ably.Conn, error) {
 		pc := pipeConn{
-			in:    in,
-			out:   out,
-			after: ablyutil.After,
+			in:  in,
+			out: out,
 		}
 		for _, opt := range opts {
 			opt(&pc)
@@ -147,7 +138,6 @@ func MessagePipe(in <-chan *ably.ProtocolMessage, out chan<- *ably.ProtocolMessa
 type pipeConn struct {
 	in    <-chan *ably.ProtocolMessage
 	out   chan<- *ably.ProtocolMessage
-	now   func() time.Time
 	after func(context.Context, time.Duration) <-chan time.Time
 }
 
@@ -156,13 +146,13 @@ func (pc pipeConn) Send(msg *ably.ProtocolMessage) error {
 	return nil
 }
 
-func (pc pipeConn) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
+func (pc pipeConn) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var timeout <-chan time.Time
-	if pc.now != nil {
-		timeout = pc.after(ctx, deadline.Sub(pc.now()))
+	var timedOut <-chan time.Time
+	if pc.after != nil {
+		timedOut = pc.after(ctx, timeout)
 	}
 
 	select {
@@ -171,7 +161,7 @@ func (pc pipeConn) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
 			return nil, io.EOF
 		}
 		return m, nil
-	case <-timeout:
+	case <-timedOut:
 		return nil, errTimeout{}
 	}
 }
@@ -294,8 +284,8 @@ func (c recConn) Send(msg *ably.ProtocolMessage) error {
 	return nil
 }
 
-func (c recConn) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
-	msg, err := c.conn.Receive(deadline)
+func (c recConn) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
+	msg, err := c.conn.Receive(timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -399,7 +389,7 @@ func (c connWithFakeDisconnect) Send(m *ably.ProtocolMessage) error {
 	return c.conn.Send(m)
 }
 
-func (c connWithFakeDisconnect) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
+func (c connWithFakeDisconnect) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
 	// Call the real Receive while waiting for a fake disconnection request.
 	// The first wins. After a disconnection request, the connection is closed,
 	// the ongoing real Receive is ignored and subsequent calls to Receive
@@ -417,7 +407,7 @@ func (c connWithFakeDisconnect) Receive(deadline time.Time) (*ably.ProtocolMessa
 	}
 	realReceive := make(chan receiveResult, 1)
 	go func() {
-		m, err := c.conn.Receive(deadline)
+		m, err := c.conn.Receive(timeout)
 		select {
 		case <-c.closed:
 		case realReceive <- receiveResult{m: m, err: err}:
@@ -496,8 +486,8 @@ func (c interceptConn) Unwrap() ably.Conn {
 	return c.Conn
 }
 
-func (c interceptConn) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
-	msg, err := c.Conn.Receive(deadline)
+func (c interceptConn) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
+	msg, err := c.Conn.Receive(timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -547,3 +537,33 @@ func assertUnique(t *testing.T, list []string) {
 		}
 	}
 }
+
+// httpTestServerOptions starts an httptest server running handler, and returns
+// client options that point a client at it using token auth and JSON.
+func httpTestServerOptions(t *testing.T, handler http.Handler) []ably.ClientOption {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(serverURL.Port())
+	return []ably.ClientOption{
+		ably.WithToken("fake:token"),
+		ably.WithTLS(false),
+		ably.WithRESTHost(serverURL.Hostname()),
+		ably.WithPort(port),
+		ably.WithUseBinaryProtocol(false),
+	}
+}
+
+// newHTTPTestREST returns a REST client whose requests are served by handler.
+func newHTTPTestREST(t *testing.T, handler http.Handler, opts ...ably.ClientOption) *ably.REST {
+	t.Helper()
+	client, err := ably.NewREST(append(httpTestServerOptions(t, handler), opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
@@ -270,8 +270,7 @@ func TestRequestToken(t *testing.T) {
 			auth: &Auth{
 				clientID: "aClientID",
 				client: &REST{
-					hostCache: &hostCache{},
-					log:       logger{l: &stdLogger{mocklogger}},
+					log: logger{l: &stdLogger{mocklogger}},
 					opts: &clientOptions{authOptions: authOptions{
 						AuthURL: "foo.com",
 						Key:     "abc:def",
@@ -0,0 +1,214 @@
+package ably
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+// RevocationTargetType is the kind of value tokens are matched by when
+// revoking them.
+type RevocationTargetType string
+
+const (
+	// RevocationTargetClientID matches tokens issued to a client ID.
+	RevocationTargetClientID RevocationTargetType = "clientId"
+	// RevocationTargetRevocationKey matches tokens issued with a revocation
+	// key; see [ably.TokenParams.RevocationKey].
+	RevocationTargetRevocationKey RevocationTargetType = "revocationKey"
+	// RevocationTargetChannel matches tokens whose capability grants access to
+	// a channel.
+	RevocationTargetChannel RevocationTargetType = "channel"
+)
+
+// RevocationTarget identifies a set of tokens to be revoked by
+// [ably.Auth.RevokeTokens] (TRT1).
+type RevocationTarget struct {
+	// Type is the kind of value the tokens are matched by (TRT2a).
+	Type RevocationTargetType
+	// Value is the client ID, revocation key or channel name (TRT2b).
+	Value string
+}
+
+// String returns the target in the "type:value" form used by the REST API.
+func (t RevocationTarget) String() string {
+	return string(t.Type) + ":" + t.Value
+}
+
+// RevokeClientID returns a target matching the tokens issued to clientID.
+func RevokeClientID(clientID string) RevocationTarget {
+	return RevocationTarget{Type: RevocationTargetClientID, Value: clientID}
+}
+
+// RevokeRevocationKey returns a target matching the tokens issued with
+// revocationKey.
+func RevokeRevocationKey(revocationKey string) RevocationTarget {
+	return RevocationTarget{Type: RevocationTargetRevocationKey, Value: revocationKey}
+}
+
+// RevokeChannel returns a target matching the tokens granting access to
+// channel.
+func RevokeChannel(channel string) RevocationTarget {
+	return RevocationTarget{Type: RevocationTargetChannel, Value: channel}
+}
+
+// A RevokeTokensOption configures a call to [ably.Auth.RevokeTokens].
+type RevokeTokensOption func(*revokeTokensOptions)
+
+type revokeTokensOptions struct {
+	IssuedBefore      int64 `json:"issuedBefore,omitempty" codec:"issuedBefore,omitempty"`
+	AllowReauthMargin bool  `json:"allowReauthMargin,omitempty" codec:"allowReauthMargin,omitempty"`
+}
+
+type revokeTokensRequest struct {
+	Targets []string `json:"targets" codec:"targets"`
+	revokeTokensOptions
+}
+
+// RevokeTokensWithIssuedBefore restricts revocation to tokens issued before
+// t. It defaults to the time the request is received by Ably (RSA17d).
+func RevokeTokensWithIssuedBefore(t time.Time) RevokeTokensOption {
+	return func(o *revokeTokensOptions) {
+		o.IssuedBefore = unixMilli(t)
+	}
+}
+
+// RevokeTokensWithAllowReauthMargin delays the enforcement of the revocation
+// by 30 seconds, giving clients with a revoked token the chance to obtain a
+// new one before being disconnected (RSA17e).
+func RevokeTokensWithAllowReauthMargin(allow bool) RevokeTokensOption {
+	return func(o *revokeTokensOptions) {
+		o.AllowReauthMargin = allow
+	}
+}
+
+// TokenRevocationResult is the outcome of revoking the tokens matching one
+// [ably.RevocationTarget] (TRS1, TRF1).
+type TokenRevocationResult struct {
+	// Target is the target in "type:value" form (TRS2a, TRF2a).
+	Target string `json:"target" codec:"target"`
+	// AppliesAt is the time at which the revocation takes effect, as
+	// milliseconds since the Unix epoch (TRS2b).
+	AppliesAt int64 `json:"appliesAt,omitempty" codec:"appliesAt,omitempty"`
+	// IssuedBefore is the time before which matching tokens were issued for
+	// them to be revoked, as milliseconds since the Unix epoch (TRS2c).
+	IssuedBefore int64 `json:"issuedBefore,omitempty" codec:"issuedBefore,omitempty"`
+	// Error describes why the target couldn't be revoked, or is nil on success (TRF2b).
+	Error *ErrorInfo `json:"error,omitempty" codec:"error,omitempty"`
+}
+
+// tokenRevocationResultWire is TokenRevocationResult as sent by Ably, with
+// its error in the wire format of errors.
+type tokenRevocationResultWire struct {
+	Target       string     `json:"target" codec:"target"`
+	AppliesAt    int64      `json:"appliesAt,omitempty" codec:"appliesAt,omitempty"`
+	IssuedBefore int64      `json:"issuedBefore,omitempty" codec:"issuedBefore,omitempty"`
+	Error        *errorInfo `json:"error,omitempty" codec:"error,omitempty"`
+}
+
+func (r TokenRevocationResult) toWire() tokenRevocationResultWire {
+	return tokenRevocationResultWire{
+		Target:       r.Target,
+		AppliesAt:    r.AppliesAt,
+		IssuedBefore: r.IssuedBefore,
+		Error:        r.Error.toProto(),
+	}
+}
+
+func (r *TokenRevocationResult) fromWire(w tokenRevocationResultWire) {
+	*r = TokenRevocationResult{
+		Target:       w.Target,
+		AppliesAt:    w.AppliesAt,
+		IssuedBefore: w.IssuedBefore,
+		Error:        newErrorFromProto(w.Error),
+	}
+}
+
+func (r TokenRevocationResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toWire())
+}
+
+func (r *TokenRevocationResult) UnmarshalJSON(js []byte) error {
+	var w tokenRevocationResultWire
+	if err := json.Unmarshal(js, &w); err != nil {
+		return err
+	}
+	r.fromWire(w)
+	return nil
+}
+
+func (r TokenRevocationResult) CodecEncodeSelf(encoder *codec.Encoder) {
+	encoder.MustEncode(r.toWire())
+}
+
+func (r *TokenRevocationResult) CodecDecodeSelf(decoder *codec.Decoder) {
+	var w tokenRevocationResultWire
+	decoder.MustDecode(&w)
+	r.fromWire(w)
+}
+
+// TokenRevocationResults holds the per-target results of a call to
+// [ably.Auth.RevokeTokens].
+type TokenRevocationResults struct {
+	// SuccessCount is the number of targets whose tokens were revoked.
+	SuccessCount int `json:"successCount" codec:"successCount"`
+	// FailureCount is the number of targets whose tokens couldn't be revoked.
+	FailureCount int `json:"failureCount" codec:"failureCount"`
+	// Results has one entry per target, in order.
+	Results []TokenRevocationResult `json:"results" codec:"results"`
+}
+
+var errRevokeTokensNeedsKey = errors.New("revoking tokens requires the client to be configured with an API key")
+
+// RevokeTokens revokes the tokens matching the given targets, e.g. all tokens
+// issued to a banned user's client ID. Only tokens issued by the API key the
+// client is configured with can be revoked, and the key must have revocable
+// tokens enabled. The request is made with basic auth, even if the client
+// otherwise uses token auth (RSA17).
+//
+// Revoking the tokens of some targets may fail while others succeed; such
+// failures are reported in the results and not as the returned error, which
+// is only set if the request as a whole failed.
+func (a *Auth) RevokeTokens(ctx context.Context, targets []RevocationTarget, opts ...RevokeTokensOption) (*TokenRevocationResults, error) {
+	if len(targets) == 0 {
+		return nil, newError(ErrBadRequest, errors.New("at least one revocation target must be provided"))
+	}
+	clientOpts := a.opts()
+	if clientOpts.Key == "" {
+		return nil, newError(ErrInvalidCredentials, errRevokeTokensNeedsKey)
+	}
+	if clientOpts.NoTLS {
+		return nil, newError(ErrInvalidUseOfBasicAuthOverNonTLSTransport, errInsecureBasicAuth)
+	}
+	var body revokeTokensRequest
+	for _, t := range targets {
+		if t.Type == "" || t.Value == "" {
+			return nil, newErrorf(ErrBadRequest, "invalid revocation target %q", t.String())
+		}
+		body.Targets = append(body.Targets, t.String())
+	}
+	for _, o := range opts {
+		o(&body.revokeTokensOptions)
+	}
+
+	credentials := base64.StdEncoding.EncodeToString([]byte(clientOpts.KeyName() + ":" + clientOpts.KeySecret()))
+	var results TokenRevocationResults
+	_, err := a.client.do(ctx, &request{
+		Method: "POST",
+		Path:   "/keys/" + url.PathEscape(clientOpts.KeyName()) + "/revokeTokens",
+		In:     &body,
+		Out:    &results,
+		NoAuth: true,
+		header: http.Header{"Authorization": {"Basic " + credentials}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
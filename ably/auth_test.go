@@ -5,7 +5,12 @@ package ably_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -91,3 +96,91 @@ func TestAuth_ClientID_Error(t *testing.T) {
 	err = checkError(40102, err)
 	assert.NoError(t, err)
 }
+
+func TestAuth_RevokeTokens_RSA17(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/keys/app.key/revokeTokens", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok, "expected basic auth")
+		assert.Equal(t, "app.key", user)
+		assert.Equal(t, "secret", pass)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"successCount": 1,
+			"failureCount": 1,
+			"results": [
+				{"target": "clientId:banned", "appliesAt": 1700000030000, "issuedBefore": 1700000000000},
+				{"target": "channel:nope", "error": {"code": 40300, "statusCode": 403, "message": "forbidden"}}
+			]
+		}`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+	port, _ := strconv.Atoi(serverURL.Port())
+	client, err := ably.NewREST(
+		ably.WithKey("app.key:secret"),
+		ably.WithUseTokenAuth(true),
+		ably.WithRESTHost(serverURL.Hostname()),
+		ably.WithTLSPort(port),
+		ably.WithHTTPClient(server.Client()),
+		ably.WithUseBinaryProtocol(false),
+	)
+	assert.NoError(t, err)
+
+	issuedBefore := time.UnixMilli(1700000000000)
+	results, err := client.Auth.RevokeTokens(context.Background(),
+		[]ably.RevocationTarget{ably.RevokeClientID("banned"), ably.RevokeChannel("nope")},
+		ably.RevokeTokensWithIssuedBefore(issuedBefore),
+		ably.RevokeTokensWithAllowReauthMargin(true),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"targets":           []interface{}{"clientId:banned", "channel:nope"},
+		"issuedBefore":      float64(1700000000000),
+		"allowReauthMargin": true,
+	}, body)
+	assert.Equal(t, 1, results.SuccessCount)
+	assert.Equal(t, 1, results.FailureCount)
+	assert.Equal(t, ably.TokenRevocationResult{
+		Target:       "clientId:banned",
+		AppliesAt:    1700000030000,
+		IssuedBefore: 1700000000000,
+	}, results.Results[0])
+	assert.Equal(t, "channel:nope", results.Results[1].Target)
+	assert.Equal(t, ably.ErrForbidden, results.Results[1].Error.Code)
+
+	t.Run("requires a key", func(t *testing.T) {
+		client, err := ably.NewREST(ably.WithToken("fake:token"))
+		assert.NoError(t, err)
+		_, err = client.Auth.RevokeTokens(context.Background(), []ably.RevocationTarget{ably.RevokeRevocationKey("k")})
+		assert.Equal(t, ably.ErrInvalidCredentials, ably.UnwrapErrorCode(err))
+	})
+}
+
+func TestTokenParams_RevocationKey(t *testing.T) {
+	params := &ably.TokenParams{ClientID: "alice", RevocationKey: "group-1"}
+	assert.Equal(t, "group-1", params.Query().Get("revocationKey"))
+	js, err := json.Marshal(params)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"clientId":"alice","revocationKey":"group-1"}`, string(js))
+}
+
+func TestTokenRequest_RevocationKeyIsNotSigned(t *testing.T) {
+	client, err := ably.NewREST(ably.WithKey("app.key:secret"))
+	assert.NoError(t, err)
+	req, err := client.Auth.CreateTokenRequest(&ably.TokenParams{ClientID: "alice", RevocationKey: "group-1"})
+	assert.NoError(t, err)
+	js, err := json.Marshal(req)
+	assert.NoError(t, err)
+	assert.Contains(t, string(js), `"revocationKey":"group-1"`)
+
+	// The MAC only covers the fields in RSA9g, as Ably computes it.
+	assert.True(t, req.VerifyMAC([]byte("secret")))
+	unrevocable := *req
+	unrevocable.RevocationKey = ""
+	assert.True(t, unrevocable.VerifyMAC([]byte("secret")))
+}
@@ -0,0 +1,34 @@
+package ably
+
+import (
+	"context"
+	"time"
+)
+
+// A Clock tells the time and runs the timers of a client, e.g. for retrying
+// connections and channels, waiting for connectionStateTtl, timing out
+// Realtime requests and checking whether tokens have expired. See
+// [ably.WithClock].
+//
+// The ablytest package provides a FakeClock, which tests can advance at
+// will instead of sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that's sent the current time once d has
+	// passed. If ctx is done before that, the channel is closed instead.
+	After(ctx context.Context, d time.Duration) <-chan time.Time
+}
+
+// WithClock sets the clock used by the client instead of the system's. It
+// doesn't affect dialing or HTTP client timeouts. A nil clock is ignored.
+func WithClock(clock Clock) ClientOption {
+	return func(os *clientOptions) {
+		if clock == nil {
+			return
+		}
+		os.Now = clock.Now
+		os.After = clock.After
+	}
+}
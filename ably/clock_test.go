@@ -0,0 +1,247 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithClock_RetriesConnection(t *testing.T) {
+	clock := ablytest.NewFakeClock(time.Now())
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	pipe := MessagePipe(in, out)
+	var dials atomic.Int32
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithClock(clock),
+		ably.WithDial(func(proto string, u *url.URL, timeout time.Duration) (ably.Conn, error) {
+			if dials.Add(1) == 1 {
+				return nil, errors.New("dial failed")
+			}
+			return pipe(proto, u, timeout)
+		}),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventDisconnected), nil)
+	assert.Error(t, err)
+
+	// The client retries once the clock has advanced by
+	// disconnectedRetryTimeout, not before.
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+	err = clock.WaitPending(ctx, 1)
+	assert.NoError(t, err)
+	clock.Advance(14 * time.Second)
+	assert.False(t, ablytest.Instantly.IsTrue(func() bool {
+		return dials.Load() > 1
+	}))
+
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	connected := make(chan ably.ConnectionStateChange, 1)
+	off := c.Connection.Once(ably.ConnectionEventConnected, func(change ably.ConnectionStateChange) {
+		connected <- change
+	})
+	defer off()
+	clock.Advance(time.Second)
+	ablytest.Soon.Recv(t, nil, connected, t.Fatalf)
+	assert.Equal(t, int32(2), dials.Load())
+}
+
+func TestWithClock_Nil(t *testing.T) {
+	_, err := ably.NewREST(ably.WithToken("fake:token"), ably.WithClock(nil))
+	assert.NoError(t, err)
+}
+
+func TestWithClock_RenewsExpiredTokens(t *testing.T) {
+	clock := ablytest.NewFakeClock(time.Now())
+	var tokens atomic.Int32
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]interface{}{})
+	}),
+		ably.WithToken(""),
+		ably.WithClock(clock),
+		ably.WithAuthCallback(func(context.Context, ably.TokenParams) (ably.Tokener, error) {
+			tokens.Add(1)
+			return &ably.TokenDetails{
+				Token:   "fake:token",
+				Expires: clock.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond),
+			}, nil
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	// The token expires once the clock has advanced by its TTL, regardless
+	// of the system's time.
+	channel := client.Channels.Get("test")
+	for i := 0; i < 2; i++ {
+		err := channel.Publish(ctx, "event", nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), tokens.Load())
+	clock.Advance(time.Hour)
+	err := channel.Publish(ctx, "event", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), tokens.Load())
+}
+
+func TestWithClock_SuspendsConnection(t *testing.T) {
+	clock := ablytest.NewFakeClock(time.Now())
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithClock(clock),
+		ably.WithDial(func(proto string, u *url.URL, timeout time.Duration) (ably.Conn, error) {
+			return nil, errors.New("dial failed")
+		}),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+	suspended := make(chan ably.ConnectionStateChange, 1)
+	off := c.Connection.Once(ably.ConnectionEventSuspended, func(change ably.ConnectionStateChange) {
+		suspended <- change
+	})
+	defer off()
+
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventDisconnected), nil)
+	assert.Error(t, err)
+
+	// The client keeps retrying until the clock has advanced by
+	// connectionStateTTL, and then it's SUSPENDED.
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+	var elapsed time.Duration
+	for elapsed < 2*time.Minute {
+		ablytest.Instantly.NoRecv(t, nil, suspended, t.Fatalf)
+		// The connectionStateTTL timer and the retry's.
+		err := clock.WaitPending(ctx, 2)
+		assert.NoError(t, err)
+		elapsed += clock.AdvanceToNext()
+	}
+	ablytest.Soon.Recv(t, nil, suspended, t.Fatalf)
+	assert.Equal(t, 2*time.Minute, elapsed)
+}
+
+// newClockRealtime returns a Realtime client with clock, connected through
+// a pipe of protocol messages.
+func newClockRealtime(t *testing.T, clock ably.Clock) (*ably.Realtime, chan<- *ably.ProtocolMessage, <-chan *ably.ProtocolMessage) {
+	t.Helper()
+	in := make(chan *ably.ProtocolMessage, 16)
+	out := make(chan *ably.ProtocolMessage, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithClock(clock),
+		ably.WithDial(MessagePipe(in, out)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, in, out
+}
+
+func TestWithClock_TimesOutRealtimeRequests(t *testing.T) {
+	clock := ablytest.NewFakeClock(time.Now())
+	c, _, out := newClockRealtime(t, clock)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	channel := c.Channels.Get("test")
+	attached := make(chan error, 1)
+	go func() {
+		attached <- channel.Attach(context.Background())
+	}()
+	var msg *ably.ProtocolMessage
+	ablytest.Soon.Recv(t, &msg, out, t.Fatalf)
+	assert.Equal(t, ably.ActionAttach, msg.Action)
+
+	// Without an ATTACHED, the attach times out once the clock has advanced
+	// by realtimeRequestTimeout, not before.
+	err := clock.WaitPending(ctx, 1)
+	assert.NoError(t, err)
+	clock.Advance(9 * time.Second)
+	ablytest.Instantly.NoRecv(t, nil, attached, t.Fatalf)
+	clock.Advance(time.Second)
+	ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+	if assert.Error(t, err) {
+		assert.Equal(t, ably.ErrTimeoutError, ably.UnwrapErrorCode(err))
+	}
+	assert.Equal(t, ably.ChannelStateSuspended, channel.State())
+}
+
+// timerRecorder is a FakeClock that records the durations of the timers
+// set, as long as its timers channel has room for them.
+type timerRecorder struct {
+	*ablytest.FakeClock
+	timers chan time.Duration
+}
+
+func (c timerRecorder) After(ctx context.Context, d time.Duration) <-chan time.Time {
+	select {
+	case c.timers <- d:
+	default:
+	}
+	return c.FakeClock.After(ctx, d)
+}
+
+func TestWithClock_RetriesChannel(t *testing.T) {
+	clock := timerRecorder{
+		FakeClock: ablytest.NewFakeClock(time.Now()),
+		timers:    make(chan time.Duration, 16),
+	}
+	c, in, out := newClockRealtime(t, clock)
+
+	channel := c.Channels.Get("test")
+	attached := make(chan error, 1)
+	go func() {
+		attached <- channel.Attach(context.Background())
+	}()
+	var msg *ably.ProtocolMessage
+	ablytest.Soon.Recv(t, &msg, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{
+		Action:  ably.ActionDetached,
+		Channel: channel.Name,
+		Error:   &ably.ProtoErrorInfo{StatusCode: 500, Code: 50000, Message: "detached"},
+	}
+	var err error
+	ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+	assert.Error(t, err)
+
+	// After failing to attach, the channel retries once the clock has
+	// advanced by channelRetryTimeout, not before.
+	for d := time.Duration(0); d != 15*time.Second; {
+		ablytest.Soon.Recv(t, &d, clock.timers, t.Fatalf)
+	}
+	clock.Advance(14 * time.Second)
+	ablytest.Instantly.NoRecv(t, nil, out, t.Fatalf)
+	clock.Advance(time.Second)
+	ablytest.Soon.Recv(t, &msg, out, t.Fatalf)
+	assert.Equal(t, ably.ActionAttach, msg.Action)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+	assert.True(t, ablytest.Soon.IsTrue(func() bool {
+		return channel.State() == ably.ChannelStateAttached
+	}))
+}
@@ -0,0 +1,255 @@
+package ably
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CipherKeyProvider supplies channel encryption keys from a source outside the
+// client options, such as a KMS, a file vault or a secrets manager, so that
+// raw keys needn't be passed to [ably.ChannelWithCipherKey].
+//
+// See [ably.ChannelWithCipherKeyProvider].
+type CipherKeyProvider interface {
+	// CipherKey returns the key identified by keyID for the named channel.
+	//
+	// An empty keyID requests the key that new messages should be encrypted
+	// with. Its ID, if any, is added to the encoding of those messages, and
+	// is later passed back as keyID to decrypt them.
+	CipherKey(ctx context.Context, channel, keyID string) (CipherKey, error)
+}
+
+// CipherKeyProviderFunc is an adapter to use a function as a
+// [ably.CipherKeyProvider].
+type CipherKeyProviderFunc func(ctx context.Context, channel, keyID string) (CipherKey, error)
+
+// CipherKey calls f(ctx, channel, keyID).
+func (f CipherKeyProviderFunc) CipherKey(ctx context.Context, channel, keyID string) (CipherKey, error) {
+	return f(ctx, channel, keyID)
+}
+
+// DefaultCipherKeyRefreshInterval is how often a channel asks its
+// [ably.CipherKeyProvider] for the active key again, unless set with
+// [ably.ChannelWithCipherKeyRefreshInterval].
+const DefaultCipherKeyRefreshInterval = 5 * time.Minute
+
+// maxProviderKeys bounds how many keys a channel keeps from its key provider,
+// besides the active one.
+const maxProviderKeys = maxKeyringCiphers
+
+// failedCipherKeyTTL is how long a key the provider failed to supply isn't
+// asked for again, since the key IDs messages name come from whoever
+// published them.
+const failedCipherKeyTTL = 10 * time.Second
+
+// providerKeyring caches the keyring built from a channel's key provider, so
+// that the provider is only called when the active key is due for a refresh
+// or a message names a key the channel hasn't seen.
+type providerKeyring struct {
+	mtx        sync.Mutex
+	keyring    *keyringCipher
+	fetchedAt  time.Time
+	refreshing bool
+
+	// err is why the active key couldn't be fetched at failedAt, if it
+	// hasn't been since.
+	err      error
+	failedAt time.Time
+}
+
+// cipherKeyProviderError is returned when a CipherKeyProvider fails to
+// supply a key. Realtime channels move to FAILED when they get one while
+// attaching or publishing; messages received are delivered still encrypted
+// instead.
+type cipherKeyProviderError struct {
+	channel string
+	keyID   string
+	err     error
+}
+
+func (e *cipherKeyProviderError) Error() string {
+	if e.keyID == "" {
+		return fmt.Sprintf("couldn't get the active cipher key for channel %q: %v", e.channel, e.err)
+	}
+	return fmt.Sprintf("couldn't get cipher key %q for channel %q: %v", e.keyID, e.channel, e.err)
+}
+
+func (e *cipherKeyProviderError) Unwrap() error {
+	return e.err
+}
+
+// fetchCipherKey gets a key from the channel's key provider, wrapping any
+// error so that it names the channel and key.
+// The call is bounded by the client's realtime request timeout, since it may
+// be made while decoding messages received on the connection.
+func (c *protoChannelOptions) fetchCipherKey(keyID string) (CipherKey, error) {
+	opts := c.clientOpts
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	ctx, cancel := opts.contextWithTimeout(context.Background(), opts.realtimeRequestTimeout())
+	defer cancel()
+	key, err := c.KeyProvider.CipherKey(ctx, c.channel, keyID)
+	if err == nil && len(key.Key) == 0 {
+		err = fmt.Errorf("provider returned an empty key")
+	}
+	if err != nil {
+		return CipherKey{}, newError(ErrChannelOperationFailed, &cipherKeyProviderError{
+			channel: c.channel,
+			keyID:   keyID,
+			err:     err,
+		})
+	}
+	return key, nil
+}
+
+// providerCipher returns a cipher that encrypts with the provider's active
+// key and fetches any other key it needs for decryption on demand.
+//
+// The cipher is cached. Once the refresh interval has passed, the cached
+// cipher is still returned while the active key is fetched again in the
+// background, so that a slow provider doesn't hold up publishing or
+// decoding.
+func (c *protoChannelOptions) providerCipher() (channelCipher, error) {
+	p := c.providerKeyring
+	if p == nil {
+		return c.fetchKeyring(nil)
+	}
+	p.mtx.Lock()
+	if keyring := p.keyring; keyring != nil {
+		if !p.refreshing && c.now().Sub(p.fetchedAt) >= c.keyRefreshInterval() {
+			p.refreshing = true
+			go c.refreshKeyring(keyring)
+		}
+		p.mtx.Unlock()
+		return keyring, nil
+	}
+	if err := p.err; err != nil && c.now().Sub(p.failedAt) < failedCipherKeyTTL {
+		p.mtx.Unlock()
+		return nil, err
+	}
+	p.mtx.Unlock()
+
+	keyring, err := c.fetchKeyring(nil)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if err != nil {
+		p.err, p.failedAt = err, c.now()
+		return nil, err
+	}
+	p.err = nil
+	if p.keyring != nil {
+		// Fetched concurrently; keep the first.
+		return p.keyring, nil
+	}
+	p.keyring, p.fetchedAt = keyring, c.now()
+	return keyring, nil
+}
+
+// refreshKeyring fetches the active key again and replaces the cached
+// keyring, carrying over the keys the previous one had fetched. If the
+// provider fails, the previous keyring is kept until the next refresh.
+func (c *protoChannelOptions) refreshKeyring(previous *keyringCipher) {
+	keyring, err := c.fetchKeyring(previous)
+	p := c.providerKeyring
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.refreshing = false
+	p.fetchedAt = c.now()
+	if err != nil {
+		if c.clientOpts != nil {
+			newLogger(c.clientOpts).printf(LogWarning, "Couldn't refresh cipher key for channel %q: %v", c.channel, err)
+		}
+		return
+	}
+	p.keyring = keyring
+}
+
+// fetchKeyring builds a keyring from the provider's active key. Keys already
+// fetched by previous, if any, are kept.
+func (c *protoChannelOptions) fetchKeyring(previous *keyringCipher) (*keyringCipher, error) {
+	active, err := c.fetchCipherKey("")
+	if err != nil {
+		return nil, err
+	}
+	params := c.Cipher
+	if params.Algorithm == 0 {
+		params.Algorithm = defaultCipherAlgorithm
+	}
+	if params.Mode == 0 {
+		params.Mode = defaultCipherMode
+	}
+	params.Key = active.Key
+	params.KeyLength = len(active.Key) * 8
+	params.KeyID = active.ID
+	params.PreviousKeys = nil
+	if active.ID != "" {
+		if err := validateCipherKeyID(active.ID); err != nil {
+			return nil, err
+		}
+	}
+	keys := make(map[string][]byte)
+	if previous != nil {
+		previous.mtx.Lock()
+		for id, key := range previous.keys {
+			keys[id] = key
+		}
+		previous.mtx.Unlock()
+	}
+	keys[active.ID] = active.Key
+	keyring, err := newKeyring(params, keys, func(keyID string) ([]byte, error) {
+		key, err := c.fetchCipherKey(keyID)
+		return key.Key, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	keyring.maxKeys = maxProviderKeys + 1
+	keyring.now = c.now
+	if previous != nil {
+		previous.mtx.Lock()
+		for id, failed := range previous.failed {
+			keyring.failed[id] = failed
+		}
+		previous.mtx.Unlock()
+	}
+	return keyring, nil
+}
+
+// keyFetchNeeded reports whether decoding data with encoding would call the
+// channel's key provider, rather than use a key it has or a recent failure.
+func (c *protoChannelOptions) keyFetchNeeded(encoding string) bool {
+	if c == nil || c.cipher != nil || c.KeyProvider == nil || c.providerKeyring == nil {
+		return false
+	}
+	p := c.providerKeyring
+	p.mtx.Lock()
+	keyring, err, failedAt := p.keyring, p.err, p.failedAt
+	p.mtx.Unlock()
+	if keyring == nil {
+		return err == nil || c.now().Sub(failedAt) >= failedCipherKeyTTL
+	}
+	for _, e := range strings.Split(encoding, "/") {
+		if strings.HasPrefix(e, "cipher+") {
+			return keyring.keyFetchNeeded(e)
+		}
+	}
+	return false
+}
+
+func (c *protoChannelOptions) keyRefreshInterval() time.Duration {
+	if c.KeyRefreshInterval > 0 {
+		return c.KeyRefreshInterval
+	}
+	return DefaultCipherKeyRefreshInterval
+}
+
+func (c *protoChannelOptions) now() time.Time {
+	if c.clientOpts == nil || c.clientOpts.Now == nil {
+		return time.Now()
+	}
+	return c.clientOpts.Now()
+}
@@ -0,0 +1,101 @@
+//go:build !integration
+// +build !integration
+
+package ably
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderCipher_CachesAndRefreshes(t *testing.T) {
+	var mtx sync.Mutex
+	active := "v1"
+	calls := map[string]int{}
+	provider := CipherKeyProviderFunc(func(ctx context.Context, channel, keyID string) (CipherKey, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		calls[keyID]++
+		if keyID == "" {
+			keyID = active
+		}
+		return CipherKey{ID: keyID, Key: make([]byte, 16)}, nil
+	})
+
+	now := time.Now()
+	clientOpts := applyOptionsWithDefaults(WithNow(func() time.Time { return now }))
+	opts := &protoChannelOptions{
+		KeyProvider:     provider,
+		channel:         "secret",
+		clientOpts:      clientOpts,
+		providerKeyring: &providerKeyring{},
+	}
+	countCalls := func(keyID string) int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return calls[keyID]
+	}
+
+	c1, err := opts.GetCipher()
+	assert.NoError(t, err)
+	c2, err := opts.GetCipher()
+	assert.NoError(t, err)
+	assert.Same(t, c1, c2)
+	assert.Equal(t, 1, countCalls(""))
+
+	// Older keys are only fetched the first time they're seen.
+	for i := 0; i < 3; i++ {
+		_, err := c1.(cipherSelector).cipherForEncoding("cipher+aes-128-cbc:v0")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, countCalls("v0"))
+
+	// Once the refresh interval has passed, the cached cipher is still
+	// returned while the active key is fetched again.
+	mtx.Lock()
+	active = "v2"
+	mtx.Unlock()
+	now = now.Add(DefaultCipherKeyRefreshInterval)
+	c3, err := opts.GetCipher()
+	assert.NoError(t, err)
+	assert.Same(t, c1, c3)
+	var refreshed channelCipher
+	assert.Eventually(t, func() bool {
+		refreshed, err = opts.GetCipher()
+		return err == nil && refreshed.GetAlgorithm() == "cipher+aes-128-cbc:v2"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 2, countCalls(""))
+
+	// Keys fetched before the refresh are kept.
+	_, err = refreshed.(cipherSelector).cipherForEncoding("cipher+aes-128-cbc:v0")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countCalls("v0"))
+}
+
+func TestProviderCipher_TimesOut(t *testing.T) {
+	provider := CipherKeyProviderFunc(func(ctx context.Context, channel, keyID string) (CipherKey, error) {
+		<-ctx.Done()
+		return CipherKey{}, ctx.Err()
+	})
+	timeout := make(chan time.Duration, 1)
+	clientOpts := applyOptionsWithDefaults(WithAfter(func(ctx context.Context, d time.Duration) <-chan time.Time {
+		timeout <- d
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}))
+	opts := &protoChannelOptions{
+		KeyProvider:     provider,
+		channel:         "secret",
+		clientOpts:      clientOpts,
+		providerKeyring: &providerKeyring{},
+	}
+
+	_, err := opts.GetCipher()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, clientOpts.realtimeRequestTimeout(), <-timeout)
+}
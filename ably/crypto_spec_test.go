@@ -5,9 +5,13 @@ package ably_test
 
 import (
 	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -111,3 +115,178 @@ func Test_Issue330_IVReuse(t *testing.T) {
 	iv2 := string(cipherText2[:aes.BlockSize])
 	assert.NotEqual(t, iv1, iv2, "IV shouldn't be reused")
 }
+
+func TestCrypto_GCM_Fixtures(t *testing.T) {
+	for _, file := range []string{
+		"crypto-data-gcm-128.json",
+		"crypto-data-gcm-256.json",
+	} {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			test, key, iv, err := ablytest.LoadLocalCryptoData(file)
+			assert.NoError(t, err)
+			params := ably.CipherParams{
+				Algorithm: ably.CipherAES,
+				Mode:      ably.CipherGCM,
+				KeyLength: test.KeyLen,
+				Key:       key,
+			}
+			params.SetIV(iv)
+			opts := &ably.ProtoChannelOptions{
+				Cipher: params,
+			}
+			cipher, err := opts.GetCipher()
+			assert.NoError(t, err)
+
+			for _, item := range test.Items {
+				var encoded ably.Message
+				err := json.Unmarshal(item.Encoded, &encoded)
+				assert.NoError(t, err)
+				encoded, err = ably.MessageWithDecodedData(encoded, nil)
+				assert.NoError(t, err)
+
+				var encrypted ably.Message
+				err = json.Unmarshal(item.Encrypted, &encrypted)
+				assert.NoError(t, err)
+				decrypted, err := ably.MessageWithDecodedData(encrypted, cipher)
+				assert.NoError(t, err)
+				assert.Equal(t, encoded.Data, decrypted.Data)
+
+				// With the fixture's nonce, encrypting the plaintext must
+				// produce the fixture's ciphertext.
+				var plain ably.Message
+				err = json.Unmarshal(item.Encoded, &plain)
+				assert.NoError(t, err)
+				plainText := []byte(plain.Data.(string))
+				if plain.Encoding == "base64" {
+					plainText, err = base64.StdEncoding.DecodeString(plain.Data.(string))
+					assert.NoError(t, err)
+				}
+				cipherText, err := base64.StdEncoding.DecodeString(encrypted.Data.(string))
+				assert.NoError(t, err)
+				reencrypted, err := cipher.Encrypt(plainText)
+				assert.NoError(t, err)
+				assert.Equal(t, cipherText, reencrypted)
+			}
+		})
+	}
+}
+
+func TestCrypto_GCM_TamperedDataFailsToDecode(t *testing.T) {
+	key, err := ably.Crypto.GenerateRandomKey(256)
+	assert.NoError(t, err)
+	opts := &ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{
+			Key:  key,
+			Mode: ably.CipherGCM,
+		}),
+	}
+	cipher, err := opts.GetCipher()
+	assert.NoError(t, err)
+
+	msg, err := ably.MessageWithEncodedData(ably.Message{Data: "secret"}, cipher)
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8/cipher+aes-256-gcm/base64", msg.Encoding)
+
+	sealed, err := base64.StdEncoding.DecodeString(msg.Data.(string))
+	assert.NoError(t, err)
+	sealed[len(sealed)-1] ^= 0xff
+	msg.Data = base64.StdEncoding.EncodeToString(sealed)
+
+	_, err = ably.MessageWithDecodedData(msg, cipher)
+	var errInfo *ably.ErrorInfo
+	assert.True(t, errors.As(err, &errInfo), "expected an *ably.ErrorInfo, got %v", err)
+	assert.Equal(t, ably.ErrInvalidMessageDataOrEncoding, errInfo.Code)
+}
+
+func Test_GCMNonceReuse(t *testing.T) {
+	params, err := ably.DefaultCipherParams()
+	assert.NoError(t, err)
+	params.Mode = ably.CipherGCM
+	cipher, err := ably.NewGCMCipher(*params)
+	assert.NoError(t, err)
+	cipherText1, err := cipher.Encrypt([]byte("foo"))
+	assert.NoError(t, err)
+	cipherText2, err := cipher.Encrypt([]byte("foo"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, cipherText1[:12], cipherText2[:12], "nonce shouldn't be reused")
+}
+
+func TestCrypto_KeyRotation(t *testing.T) {
+	oldKey, err := ably.Crypto.GenerateRandomKey(128)
+	assert.NoError(t, err)
+	newKey, err := ably.Crypto.GenerateRandomKey(256)
+	assert.NoError(t, err)
+
+	// The old publisher uses CBC without a key ID; the next one tags its
+	// messages with the "v1" key ID.
+	legacy, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: oldKey}),
+	}).GetCipher()
+	assert.NoError(t, err)
+	v1, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: oldKey, KeyID: "v1"}),
+	}).GetCipher()
+	assert.NoError(t, err)
+	v2, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{
+			Key:          newKey,
+			KeyID:        "v2",
+			Mode:         ably.CipherGCM,
+			PreviousKeys: []ably.CipherKey{{ID: "v1", Key: oldKey}},
+		}),
+	}).GetCipher()
+	assert.NoError(t, err)
+
+	fromV1, err := ably.MessageWithEncodedData(ably.Message{Data: "old"}, v1)
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8/cipher+aes-128-cbc:v1/base64", fromV1.Encoding)
+	fromV2, err := ably.MessageWithEncodedData(ably.Message{Data: "new"}, v2)
+	assert.NoError(t, err)
+	assert.Equal(t, "utf-8/cipher+aes-256-gcm:v2/base64", fromV2.Encoding)
+
+	decoded, err := ably.MessageWithDecodedData(fromV1, v2)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", decoded.Data)
+	decoded, err = ably.MessageWithDecodedData(fromV2, v2)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", decoded.Data)
+
+	// Messages without a key ID are decrypted with the active key.
+	fromLegacy, err := ably.MessageWithEncodedData(ably.Message{Data: "legacy"}, legacy)
+	assert.NoError(t, err)
+	decoded, err = ably.MessageWithDecodedData(fromLegacy, v1)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", decoded.Data)
+
+	// A subscriber that doesn't know the new key can't decrypt, and keeps
+	// the encodings it couldn't apply.
+	decoded, err = ably.MessageWithDecodedData(fromV2, v1)
+	assert.ErrorContains(t, err, `no cipher key with ID "v2"`)
+	assert.Equal(t, "utf-8/cipher+aes-256-gcm:v2", decoded.Encoding)
+}
+
+func TestCrypto_KeyRotation_InvalidKeyIDs(t *testing.T) {
+	key, err := ably.Crypto.GenerateRandomKey(256)
+	assert.NoError(t, err)
+	tests := map[string]ably.CipherParams{
+		"missing active key ID": {
+			Key:          key,
+			PreviousKeys: []ably.CipherKey{{ID: "v1", Key: key}},
+		},
+		"key ID with separator": {Key: key, KeyID: "v:2"},
+		"key ID with slash":     {Key: key, KeyID: "v/2"},
+		"duplicate key ID": {
+			Key:          key,
+			KeyID:        "v1",
+			PreviousKeys: []ably.CipherKey{{ID: "v1", Key: key}},
+		},
+	}
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := &ably.ProtoChannelOptions{Cipher: ably.Crypto.GetDefaultParams(params)}
+			_, err := opts.GetCipher()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -74,6 +74,20 @@
 // are more page(s) available. IsLast method checks if the page is the
 // last page. Both methods return a true or false value.
 //
+// Request objects embed a [Paginated], which also provides Collect, which
+// gathers up to a maximum number of items into a slice, and, with Go 1.23 or
+// later, All, a range-over-func iterator over every item:
+//
+//	for msg, err := range channel.History().All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		// ...
+//	}
+//
+// To continue iterating later, e.g. after a restart, store the result of the
+// Cursor method and pass it to the request's Resume method.
+//
 // See the PaginatedResults example.
 //
 // [Ably Go Client Library SDK source code]: https://github.com/ably/ably-go/
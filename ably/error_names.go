@@ -9,6 +9,7 @@ const (
 	ErrBadRequest                                ErrorCode = 40000
 	ErrInvalidCredential                         ErrorCode = 40005
 	ErrInvalidClientID                           ErrorCode = 40012
+	ErrInvalidMessageDataOrEncoding              ErrorCode = 40013
 	ErrUnauthorized                              ErrorCode = 40100
 	ErrInvalidCredentials                        ErrorCode = 40101
 	ErrIncompatibleCredentials                   ErrorCode = 40102
@@ -18,6 +19,8 @@ const (
 	ErrForbidden                                 ErrorCode = 40300
 	ErrNotFound                                  ErrorCode = 40400
 	ErrMethodNotAllowed                          ErrorCode = 40500
+	ErrRateLimitExceeded                         ErrorCode = 42910
+	ErrRateLimitExceededFatal                    ErrorCode = 42920
 	ErrInternalError                             ErrorCode = 50000
 	ErrInternalChannelError                      ErrorCode = 50001
 	ErrInternalConnectionError                   ErrorCode = 50002
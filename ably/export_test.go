@@ -57,6 +57,14 @@ func DecodeResp(resp *http.Response, out interface{}) error {
 	return decodeResp(resp, out)
 }
 
+// VerifyMAC reports whether req's MAC matches its fields when signed with
+// secret.
+func (req TokenRequest) VerifyMAC(secret []byte) bool {
+	mac := req.MAC
+	req.sign(secret)
+	return req.MAC == mac
+}
+
 func UnwrapErrorCode(err error) ErrorCode {
 	return code(err)
 }
@@ -90,7 +98,7 @@ func (a *Auth) SetServerTimeFunc(st func() (time.Time, error)) {
 }
 
 func (c *REST) GetCachedFallbackHost() string {
-	return c.hostCache.get()
+	return c.hosts.preferredHost()
 }
 
 func (c *RealtimeChannel) GetChannelSerial() string {
@@ -352,3 +360,7 @@ func NewCBCCipher(opts CipherParams) (*cbcCipher, error) {
 func (p *CipherParams) SetIV(iv []byte) {
 	p.iv = iv
 }
+
+func NewGCMCipher(opts CipherParams) (*gcmCipher, error) {
+	return newGCMCipher(opts)
+}
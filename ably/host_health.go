@@ -0,0 +1,307 @@
+package ably
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HostCircuitState is the state of the circuit breaker of a host used by
+// REST. See [ably.REST.HostHealth].
+type HostCircuitState struct {
+	name string
+}
+
+var (
+	// HostCircuitClosed - The host is used as normal.
+	HostCircuitClosed HostCircuitState = HostCircuitState{name: "CLOSED"}
+
+	// HostCircuitOpen - The host has failed repeatedly, so it's only tried once
+	// no other host is left, until the circuit breaker timeout expires.
+	HostCircuitOpen HostCircuitState = HostCircuitState{name: "OPEN"}
+
+	// HostCircuitHalfOpen - The circuit breaker timeout has expired, so the next
+	// request may probe the host: if it succeeds, the circuit closes; otherwise,
+	// it opens again.
+	HostCircuitHalfOpen HostCircuitState = HostCircuitState{name: "HALF_OPEN"}
+)
+
+func (s HostCircuitState) String() string {
+	return s.name
+}
+
+// HostHealth describes how a host used by REST has been doing.
+type HostHealth struct {
+	// Host is the host name, possibly with a port.
+	Host string
+	// Circuit is the state of the host's circuit breaker.
+	Circuit HostCircuitState
+	// Latency is a moving average of the time taken by requests to the host,
+	// or zero if none has been made.
+	Latency time.Duration
+	// Successes is the number of requests to which the host responded.
+	Successes int
+	// Failures is the number of requests for which the host was unreachable,
+	// timed out or responded with a server error.
+	Failures int
+	// ConsecutiveFailures is the number of failures since the last success.
+	ConsecutiveFailures int
+	// LastFailure is when the last failure happened.
+	LastFailure time.Time
+}
+
+// WithHostCircuitBreaker configures how REST stops using hosts that keep
+// failing. After failures consecutive failures, a host's circuit opens: it's
+// tried after every other host, until timeout has passed. Then, a single
+// request probes the host, which closes the circuit if successful. The
+// defaults are 3 failures and 30 seconds.
+func WithHostCircuitBreaker(failures int, timeout time.Duration) ClientOption {
+	return func(os *clientOptions) {
+		os.HostFailureThreshold = failures
+		os.HostCircuitTimeout = timeout
+	}
+}
+
+// HostHealth returns the health of the primary host and the fallback hosts,
+// in the order in which the next request would try them (RSC15).
+//
+// The primary host is tried first, unless a fallback host has succeeded
+// within FallbackRetryTimeout (RSC15f) or the primary host's circuit is open.
+// Remaining fallback hosts are tried from the healthiest: hosts known to be
+// working, from the fastest; then hosts not tried yet, in random order; then
+// hosts that have failed recently; and then hosts whose circuit is open.
+func (c *REST) HostHealth() []HostHealth {
+	return c.hosts.health(c.hostOrder())
+}
+
+// hostOrder returns the hosts in the order in which the next request should
+// try them.
+func (c *REST) hostOrder() []string {
+	var primary string
+	if u, err := url.Parse(c.opts.restURL()); err == nil {
+		primary = u.Host
+	}
+	fallbacks, _ := c.opts.getFallbackHosts()
+	return c.hosts.order(primary, fallbacks)
+}
+
+// hostTracker records the health of the hosts used by REST, and chooses the
+// order in which to try them.
+type hostTracker struct {
+	now              func() time.Time
+	failureThreshold int
+	circuitTimeout   time.Duration
+	fallbackTimeout  time.Duration
+
+	mtx            sync.Mutex
+	stats          map[string]*hostStats
+	preferred      string // a fallback host that has succeeded (RSC15f)
+	preferredUntil time.Time
+}
+
+type hostStats struct {
+	latency             time.Duration
+	successes           int
+	failures            int
+	consecutiveFailures int
+	lastFailure         time.Time
+	openedAt            time.Time // zero while the circuit is closed
+	probing             bool      // whether a half-open probe is in flight
+}
+
+// latencyWeight is the weight of the latest sample in the moving average of
+// a host's latency.
+const latencyWeight = 0.3
+
+func newHostTracker(opts *clientOptions) *hostTracker {
+	return &hostTracker{
+		now:              opts.Now,
+		failureThreshold: opts.hostFailureThreshold(),
+		circuitTimeout:   opts.hostCircuitTimeout(),
+		fallbackTimeout:  opts.fallbackRetryTimeout(),
+		stats:            make(map[string]*hostStats),
+	}
+}
+
+func (t *hostTracker) statsFor(host string) *hostStats {
+	s, ok := t.stats[host]
+	if !ok {
+		s = &hostStats{}
+		t.stats[host] = s
+	}
+	return s
+}
+
+func (t *hostTracker) circuit(s *hostStats, now time.Time) HostCircuitState {
+	switch {
+	case s == nil || s.openedAt.IsZero():
+		return HostCircuitClosed
+	case now.Sub(s.openedAt) < t.circuitTimeout:
+		return HostCircuitOpen
+	default:
+		return HostCircuitHalfOpen
+	}
+}
+
+// available returns whether a request can be sent to a host without waiting
+// for every other host to fail first.
+func (t *hostTracker) available(s *hostStats, now time.Time) bool {
+	switch t.circuit(s, now) {
+	case HostCircuitOpen:
+		return false
+	case HostCircuitHalfOpen:
+		return !s.probing
+	}
+	return true
+}
+
+// order returns the hosts in the order in which a request should try them,
+// as described by REST.HostHealth.
+func (t *hostTracker) order(primary string, fallbacks []string) []string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+
+	first := primary
+	if t.preferred != "" && now.Before(t.preferredUntil) {
+		first = t.preferred
+	}
+	rest := make([]string, 0, len(fallbacks)+1)
+	seen := map[string]bool{first: true}
+	for _, h := range append([]string{primary}, fallbacks...) {
+		if !seen[h] {
+			seen[h] = true
+			rest = append(rest, h)
+		}
+	}
+	// Shuffle first so that equally healthy hosts, e.g. those not tried yet,
+	// are tried in random order, spreading the load between them.
+	rand.Shuffle(len(rest), func(i, j int) {
+		rest[i], rest[j] = rest[j], rest[i]
+	})
+	sort.SliceStable(rest, func(i, j int) bool {
+		a, b := t.stats[rest[i]], t.stats[rest[j]]
+		if ra, rb := t.rank(a, now), t.rank(b, now); ra != rb {
+			return ra < rb
+		}
+		switch {
+		case a == nil || b == nil:
+			return false
+		case a.consecutiveFailures != b.consecutiveFailures:
+			return a.consecutiveFailures < b.consecutiveFailures
+		default:
+			return a.latency < b.latency
+		}
+	})
+
+	hosts := append([]string{first}, rest...)
+	// Hosts that aren't available go last, keeping their relative order.
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return t.available(t.stats[hosts[i]], now) && !t.available(t.stats[hosts[j]], now)
+	})
+	return hosts
+}
+
+// rank groups hosts by health, healthiest first.
+func (t *hostTracker) rank(s *hostStats, now time.Time) int {
+	switch {
+	case !t.available(s, now):
+		return 3
+	case s == nil || s.successes == 0 && s.failures == 0:
+		return 1
+	case t.circuit(s, now) == HostCircuitHalfOpen || s.consecutiveFailures > 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// attempt records that a request is about to be sent to host, making it the
+// probe of a half-open circuit.
+func (t *hostTracker) attempt(host string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if s := t.stats[host]; t.circuit(s, t.now()) == HostCircuitHalfOpen {
+		s.probing = true
+	}
+}
+
+// record records the outcome of a request sent to host, which took latency.
+// failed is whether the host failed to serve the request, as opposed to
+// rejecting it.
+func (t *hostTracker) record(host string, latency time.Duration, failed bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+	s := t.statsFor(host)
+	halfOpen := t.circuit(s, now) == HostCircuitHalfOpen
+	s.probing = false
+	if failed {
+		s.failures++
+		s.consecutiveFailures++
+		s.lastFailure = now
+		if halfOpen || s.consecutiveFailures >= t.failureThreshold {
+			s.openedAt = now
+		}
+		return
+	}
+	if s.successes == 0 {
+		s.latency = latency
+	} else {
+		s.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(s.latency))
+	}
+	s.successes++
+	s.consecutiveFailures = 0
+	s.openedAt = time.Time{}
+}
+
+// abandon records that a request sent to host was abandoned before its
+// outcome was known.
+func (t *hostTracker) abandon(host string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if s := t.stats[host]; s != nil {
+		s.probing = false
+	}
+}
+
+// prefer makes host, a fallback host that has succeeded, the first one tried
+// for the next FallbackRetryTimeout (RSC15f).
+func (t *hostTracker) prefer(host string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.preferred = host
+	t.preferredUntil = t.now().Add(t.fallbackTimeout)
+}
+
+// preferredHost returns the fallback host preferred by prefer, if any.
+func (t *hostTracker) preferredHost() string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.preferred == "" || !t.now().Before(t.preferredUntil) {
+		return ""
+	}
+	return t.preferred
+}
+
+func (t *hostTracker) health(hosts []string) []HostHealth {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	now := t.now()
+	health := make([]HostHealth, 0, len(hosts))
+	for _, h := range hosts {
+		hh := HostHealth{Host: h, Circuit: HostCircuitClosed}
+		if s := t.stats[h]; s != nil {
+			hh.Circuit = t.circuit(s, now)
+			hh.Latency = s.latency
+			hh.Successes = s.successes
+			hh.Failures = s.failures
+			hh.ConsecutiveFailures = s.consecutiveFailures
+			hh.LastFailure = s.lastFailure
+		}
+		health = append(health, hh)
+	}
+	return health
+}
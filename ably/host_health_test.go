@@ -0,0 +1,140 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREST_HostHealth(t *testing.T) {
+	const primary = "primary.test:80"
+	var mtx sync.Mutex
+	requests := map[string]int{}
+	healthy := map[string]bool{"good.test": true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests[r.Host]++
+		if !healthy[r.Host] {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// Every host resolves to the test server, which tells them apart by the
+	// Host header.
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	clock := &fakeSleeper{now: time.Now()}
+	advance := func(d time.Duration) {
+		<-clock.After(context.Background(), d)
+	}
+	client, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithTLS(false),
+		ably.WithUseBinaryProtocol(false),
+		ably.WithRESTHost("primary.test"),
+		ably.WithFallbackHosts([]string{"good.test", "bad.test"}),
+		ably.WithHTTPClient(httpClient),
+		ably.WithFallbackRetryTimeout(time.Second),
+		ably.WithHostCircuitBreaker(2, time.Minute),
+		ably.WithNow(clock.Now),
+		ably.WithAfter(clock.After),
+	)
+	assert.NoError(t, err)
+	channel := client.Channels.Get("health")
+	ctx := context.Background()
+	hosts := func() (hosts []string, circuits []ably.HostCircuitState) {
+		for _, h := range client.HostHealth() {
+			hosts = append(hosts, h.Host)
+			circuits = append(circuits, h.Circuit)
+		}
+		return hosts, circuits
+	}
+	publish := func() map[string]int {
+		t.Helper()
+		mtx.Lock()
+		requests = map[string]int{}
+		mtx.Unlock()
+		assert.NoError(t, channel.Publish(ctx, "event", "data"))
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests
+	}
+
+	t.Run("prefers a working fallback host", func(t *testing.T) {
+		got := publish()
+		assert.Equal(t, 1, got[primary])
+		assert.Equal(t, 1, got["good.test"])
+
+		health := map[string]ably.HostHealth{}
+		for i, h := range client.HostHealth() {
+			if i == 0 {
+				assert.Equal(t, "good.test", h.Host, "RSC15f")
+			}
+			health[h.Host] = h
+		}
+		assert.Equal(t, 1, health["good.test"].Successes)
+		assert.Equal(t, 1, health[primary].Failures)
+		assert.Equal(t, 1, health[primary].ConsecutiveFailures)
+		assert.Equal(t, ably.HostCircuitClosed, health[primary].Circuit)
+	})
+
+	t.Run("ranks fallback hosts by health", func(t *testing.T) {
+		advance(2 * time.Second)
+		got, _ := hosts()
+		assert.Equal(t, []string{primary, "good.test", "bad.test"}, got)
+
+		requests := publish()
+		assert.Equal(t, map[string]int{primary: 1, "good.test": 1}, requests)
+	})
+
+	t.Run("opens circuit of failing host", func(t *testing.T) {
+		advance(2 * time.Second)
+		got, circuits := hosts()
+		assert.Equal(t, []string{"good.test", "bad.test", primary}, got)
+		assert.Equal(t, ably.HostCircuitOpen, circuits[2])
+
+		requests := publish()
+		assert.Equal(t, map[string]int{"good.test": 1}, requests)
+	})
+
+	t.Run("probes host once circuit is half-open", func(t *testing.T) {
+		advance(time.Minute)
+		got, circuits := hosts()
+		assert.Equal(t, primary, got[0])
+		assert.Equal(t, ably.HostCircuitHalfOpen, circuits[0])
+
+		// A failed probe opens the circuit again.
+		requests := publish()
+		assert.Equal(t, map[string]int{primary: 1, "good.test": 1}, requests)
+		_, circuits = hosts()
+		assert.Equal(t, ably.HostCircuitOpen, circuits[2])
+
+		advance(time.Minute)
+		mtx.Lock()
+		healthy[primary] = true
+		mtx.Unlock()
+		requests = publish()
+		assert.Equal(t, map[string]int{primary: 1}, requests)
+		got, circuits = hosts()
+		assert.Equal(t, primary, got[0])
+		assert.Equal(t, ably.HostCircuitClosed, circuits[0])
+	})
+}
@@ -10,11 +10,18 @@ import (
 
 var handle codec.MsgpackHandle
 
+// binaryHandle is handle, except that it decodes binary values into []byte.
+var binaryHandle codec.MsgpackHandle
+
 func init() {
 	handle.Raw = true
 	handle.WriteExt = true
 	handle.RawToString = true
 	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	binaryHandle.Raw = true
+	binaryHandle.WriteExt = true
+	binaryHandle.MapType = handle.MapType
 }
 
 // UnmarshalMsgpack decodes the MessagePack-encoded data and stores the result in the
@@ -23,6 +30,13 @@ func UnmarshalMsgpack(data []byte, v interface{}) error {
 	return decodeMsg(bytes.NewReader(data), v)
 }
 
+// UnmarshalMsgpackBinary is like UnmarshalMsgpack, except that binary values
+// decoded into an interface{} are []byte instead of string, so that values
+// encoded with MarshalMsgpack round-trip.
+func UnmarshalMsgpackBinary(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, &binaryHandle).Decode(v)
+}
+
 // decodeMsg decodes msgpack message read from r into v.
 func decodeMsg(r io.Reader, v interface{}) error {
 	dec := codec.NewDecoder(r, &handle)
@@ -44,3 +58,9 @@ func encodeMsg(w io.Writer, v interface{}) error {
 	enc := codec.NewEncoder(w, &handle)
 	return enc.Encode(v)
 }
+
+// NewMsgpackDecoder returns a decoder for a stream of msgpack values read
+// from r. Decode returns io.EOF once the stream ends.
+func NewMsgpackDecoder(r io.Reader) *codec.Decoder {
+	return codec.NewDecoder(r, &handle)
+}
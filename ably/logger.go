@@ -3,6 +3,7 @@ package ably
 import (
 	"fmt"
 	"log"
+	"strings"
 )
 
 type LogLevel uint
@@ -48,6 +49,84 @@ type Logger interface {
 	Printf(level LogLevel, format string, v ...interface{})
 }
 
+// StructuredLogger is an interface for ably loggers that receive log records
+// as a message with attributes, instead of a format string. See
+// [ably.WithStructuredLogHandler].
+type StructuredLogger interface {
+	Log(level LogLevel, msg string, attrs ...LogAttr)
+}
+
+// LogAttr is a key-value pair attached to a log record by a
+// [ably.StructuredLogger].
+type LogAttr struct {
+	Key   string
+	Value interface{}
+}
+
+// Keys of the attributes attached to log records.
+const (
+	LogKeyConnectionID = "connectionId"
+	LogKeyChannel      = "channel"
+	LogKeyState        = "state"
+	LogKeyMsgSerial    = "msgSerial"
+	LogKeyHost         = "host"
+)
+
+// WithStructuredLogHandler sets a StructuredLogger that receives the log
+// output of the library, with attributes such as the connection ID and the
+// channel name attached to records (see the LogKey constants). It's used
+// instead of LogHandler. Records are still filtered by LogLevel.
+func WithStructuredLogHandler(handler StructuredLogger) ClientOption {
+	return func(os *clientOptions) {
+		os.StructuredLogHandler = handler
+	}
+}
+
+type filteredStructuredLogger struct {
+	Logger StructuredLogger
+	Level  LogLevel
+}
+
+func (l filteredStructuredLogger) Is(level LogLevel) bool {
+	return l.Level != LogNone && l.Level >= level
+}
+
+func (l filteredStructuredLogger) Log(level LogLevel, msg string, attrs ...LogAttr) {
+	if l.Is(level) {
+		l.Logger.Log(level, msg, attrs...)
+	}
+}
+
+// printfLogger adapts a Logger to the StructuredLogger interface, appending
+// attributes to the format string as key=value pairs.
+type printfLogger struct {
+	l Logger
+}
+
+func (p printfLogger) Log(level LogLevel, msg string, attrs ...LogAttr) {
+	p.printf(level, escapeFormat(msg), nil, attrs)
+}
+
+func (p printfLogger) printf(level LogLevel, format string, v []interface{}, attrs []LogAttr) {
+	if len(attrs) > 0 {
+		var b strings.Builder
+		b.WriteString(format)
+		v = append([]interface{}(nil), v...)
+		for _, a := range attrs {
+			b.WriteString(" " + escapeFormat(a.Key) + "=%v")
+			v = append(v, a.Value)
+		}
+		format = b.String()
+	}
+	p.l.Printf(level, format, v...)
+}
+
+// escapeFormat escapes s so that it's printed as is when used as a format
+// string.
+func escapeFormat(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
 // stdLogger wraps log.Logger to satisfy the Logger interface.
 type stdLogger struct {
 	*log.Logger
@@ -57,9 +136,23 @@ func (s *stdLogger) Printf(level LogLevel, format string, v ...interface{}) {
 	s.Logger.Printf(fmt.Sprintf("[%s] %s", level, format), v...)
 }
 
-// logger is the internal logger type, with helper methods that wrap the raw Logger interface.
+// logger is the internal logger type, with helper methods that wrap the raw
+// Logger or StructuredLogger interfaces.
 type logger struct {
-	l Logger
+	l     Logger
+	s     StructuredLogger // used instead of l if set
+	r     *redactor
+	attrs []LogAttr
+}
+
+func newLogger(opts *clientOptions) logger {
+	return logger{l: opts.LogHandler, s: opts.StructuredLogHandler, r: newRedactor(opts)}
+}
+
+// with returns a copy of l that attaches attrs to every record.
+func (l logger) with(attrs ...LogAttr) logger {
+	l.attrs = append(append([]LogAttr(nil), l.attrs...), attrs...)
+	return l
 }
 
 func (l logger) Error(v ...interface{}) {
@@ -67,7 +160,7 @@ func (l logger) Error(v ...interface{}) {
 }
 
 func (l logger) Errorf(fmt string, v ...interface{}) {
-	l.l.Printf(LogError, fmt, v...)
+	l.printf(LogError, fmt, v...)
 }
 
 func (l logger) Warn(v ...interface{}) {
@@ -75,7 +168,7 @@ func (l logger) Warn(v ...interface{}) {
 }
 
 func (l logger) Warnf(fmt string, v ...interface{}) {
-	l.l.Printf(LogWarning, fmt, v...)
+	l.printf(LogWarning, fmt, v...)
 }
 
 func (l logger) Info(v ...interface{}) {
@@ -83,7 +176,7 @@ func (l logger) Info(v ...interface{}) {
 }
 
 func (l logger) Infof(fmt string, v ...interface{}) {
-	l.l.Printf(LogInfo, fmt, v...)
+	l.printf(LogInfo, fmt, v...)
 }
 
 func (l logger) Verbose(v ...interface{}) {
@@ -91,17 +184,57 @@ func (l logger) Verbose(v ...interface{}) {
 }
 
 func (l logger) Verbosef(fmt string, v ...interface{}) {
-	l.l.Printf(LogVerbose, fmt, v...)
+	l.printf(LogVerbose, fmt, v...)
 }
 
 func (l logger) Debugf(fmt string, v ...interface{}) {
-	l.l.Printf(LogDebug, fmt, v...)
+	l.printf(LogDebug, fmt, v...)
 }
 
 func (l logger) Debug(v ...interface{}) {
 	l.print(LogDebug, v...)
 }
 
+// enabled returns whether records at level are logged, so that they aren't
+// formatted needlessly.
+func (l logger) enabled(level LogLevel) bool {
+	if l.s != nil {
+		f, ok := l.s.(filteredStructuredLogger)
+		return !ok || f.Is(level)
+	}
+	f, ok := l.l.(filteredLogger)
+	return !ok || f.Is(level)
+}
+
 func (l logger) print(level LogLevel, v ...interface{}) {
-	l.l.Printf(level, fmt.Sprint(v...))
+	if l.s == nil && l.r == nil {
+		printfLogger{l: l.l}.Log(level, fmt.Sprint(v...), l.attrs...)
+		return
+	}
+	if l.enabled(level) {
+		l.write(level, fmt.Sprint(v...))
+	}
+}
+
+func (l logger) printf(level LogLevel, format string, v ...interface{}) {
+	// Without redaction, a Logger gets the format string and arguments as
+	// they are.
+	if l.s == nil && l.r == nil {
+		printfLogger{l: l.l}.printf(level, format, v, l.attrs)
+		return
+	}
+	if l.enabled(level) {
+		l.write(level, fmt.Sprintf(format, v...))
+	}
+}
+
+// write redacts a formatted record and writes it.
+func (l logger) write(level LogLevel, msg string) {
+	msg = l.r.redact(msg)
+	attrs := l.r.redactAttrs(l.attrs)
+	if l.s != nil {
+		l.s.Log(level, msg, attrs...)
+		return
+	}
+	printfLogger{l: l.l}.Log(level, msg, attrs...)
 }
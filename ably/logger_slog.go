@@ -0,0 +1,48 @@
+//go:build go1.21
+// +build go1.21
+
+package ably
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// NewSlogLogger returns a StructuredLogger that writes records to a
+// log/slog handler. LogVerbose is mapped to slog.LevelDebug, and LogDebug to
+// four levels below it.
+//
+//	client, err := ably.NewRealtime(
+//		ably.WithKey(key),
+//		ably.WithLogLevel(ably.LogInfo),
+//		ably.WithStructuredLogHandler(ably.NewSlogLogger(slog.Default().Handler())),
+//	)
+func NewSlogLogger(handler slog.Handler) StructuredLogger {
+	return slogLogger{h: handler}
+}
+
+type slogLogger struct {
+	h slog.Handler
+}
+
+var slogLevels = map[LogLevel]slog.Level{
+	LogError:   slog.LevelError,
+	LogWarning: slog.LevelWarn,
+	LogInfo:    slog.LevelInfo,
+	LogVerbose: slog.LevelDebug,
+	LogDebug:   slog.LevelDebug - 4,
+}
+
+func (l slogLogger) Log(level LogLevel, msg string, attrs ...LogAttr) {
+	ctx := context.Background()
+	lvl := slogLevels[level]
+	if !l.h.Enabled(ctx, lvl) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), lvl, msg, 0)
+	for _, a := range attrs {
+		r.AddAttrs(slog.Any(a.Key, a.Value))
+	}
+	l.h.Handle(ctx, r)
+}
@@ -0,0 +1,32 @@
+//go:build !integration && go1.21
+// +build !integration,go1.21
+
+package ably_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := ably.NewSlogLogger(handler)
+	logger.Log(ably.LogWarning, "warning", ably.LogAttr{Key: ably.LogKeyChannel, Value: "a"})
+	logger.Log(ably.LogVerbose, "verbose")
+	logger.Log(ably.LogDebug, "debug")
+	assert.Equal(t, "level=WARN msg=warning channel=a\nlevel=DEBUG msg=verbose\n", buf.String())
+}
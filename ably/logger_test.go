@@ -4,9 +4,14 @@
 package ably_test
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,3 +55,170 @@ func TestFilteredLogger(t *testing.T) {
 			"expected nothing to be logged")
 	})
 }
+
+// recordingLogger is a StructuredLogger that records the records it receives.
+type recordingLogger struct {
+	mtx     sync.Mutex
+	records []loggedRecord
+}
+
+type loggedRecord struct {
+	level ably.LogLevel
+	msg   string
+	attrs map[string]interface{}
+}
+
+func (l *recordingLogger) Log(level ably.LogLevel, msg string, attrs ...ably.LogAttr) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	r := loggedRecord{level: level, msg: msg, attrs: map[string]interface{}{}}
+	for _, a := range attrs {
+		r.attrs[a.Key] = a.Value
+	}
+	l.records = append(l.records, r)
+}
+
+// find returns the first record whose message starts with prefix.
+func (l *recordingLogger) find(prefix string) (loggedRecord, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for _, r := range l.records {
+		if strings.HasPrefix(r.msg, prefix) {
+			return r, true
+		}
+	}
+	return loggedRecord{}, false
+}
+
+func TestWithStructuredLogHandler(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	logger := &recordingLogger{}
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithLogLevel(ably.LogVerbose),
+		ably.WithStructuredLogHandler(logger),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	channel := c.Channels.Get("logged")
+	attached := make(chan error, 1)
+	go func() {
+		attached <- channel.Attach(context.Background())
+	}()
+	var msg *ably.ProtocolMessage
+	ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+	ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+	assert.NoError(t, err)
+
+	published := make(chan error, 1)
+	go func() {
+		published <- channel.Publish(context.Background(), "event", "data")
+	}()
+	ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAck, MsgSerial: msg.MsgSerial, Count: 1}
+	ablytest.Soon.Recv(t, &err, published, t.Fatalf)
+	assert.NoError(t, err)
+
+	r, ok := logger.find("connection state changed from CONNECTING to CONNECTED")
+	assert.True(t, ok, "expected connection state change to be logged")
+	assert.Equal(t, ably.LogVerbose, r.level)
+	assert.Equal(t, "CONNECTED", r.attrs[ably.LogKeyState])
+
+	r, ok = logger.find("channel state changed from ATTACHING to ATTACHED")
+	assert.True(t, ok, "expected channel state change to be logged")
+	assert.Equal(t, map[string]interface{}{
+		ably.LogKeyConnectionID: "connection-id",
+		ably.LogKeyChannel:      "logged",
+		ably.LogKeyState:        "ATTACHED",
+	}, r.attrs)
+
+	r, ok = logger.find("received ack")
+	assert.True(t, ok, "expected ACK to be logged")
+	assert.Equal(t, msg.MsgSerial, r.attrs[ably.LogKeyMsgSerial])
+
+	_, ok = logger.find("Dial")
+	assert.False(t, ok, "expected debug records to be filtered out by LogLevel")
+}
+
+// printfRecorder is a Logger that records formatted lines.
+type printfRecorder struct {
+	lines []string
+}
+
+func (p *printfRecorder) Printf(level ably.LogLevel, format string, v ...interface{}) {
+	p.lines = append(p.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLogger_Attributes(t *testing.T) {
+	l := &printfRecorder{}
+	rest, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithRESTHost("127.0.0.1"),
+		ably.WithPort(1),
+		ably.WithTLS(false),
+		ably.WithFallbackHosts([]string{}),
+		ably.WithLogLevel(ably.LogError),
+		ably.WithLogHandler(l),
+	)
+	assert.NoError(t, err)
+	_, err = rest.Time(context.Background())
+	assert.Error(t, err)
+	var found bool
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, "RestClient: failed sending a request") {
+			found = true
+			assert.True(t, strings.HasSuffix(line, " host=127.0.0.1:1"),
+				"expected attributes to be appended to the Logger's line, got %q", line)
+		}
+	}
+	assert.True(t, found, "expected failed request to be logged, got %q", l.lines)
+}
+
+func TestLogger_PercentInMessage(t *testing.T) {
+	l := &printfRecorder{}
+	logger := ably.NewInternalLogger(l)
+	logger.Error("100% sure, ", "%v is not a verb")
+	logger.Errorf("%d%% sure", 100)
+	assert.Equal(t, []string{"100% sure, %v is not a verb", "100% sure"}, l.lines)
+}
+
+type argsRecorder struct {
+	formats []string
+	args    [][]interface{}
+}
+
+func (r *argsRecorder) Printf(level ably.LogLevel, format string, v ...interface{}) {
+	r.formats = append(r.formats, format)
+	r.args = append(r.args, v)
+}
+
+func TestLogger_PassesFormatAndArgs(t *testing.T) {
+	l := &argsRecorder{}
+	rest, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithRESTHost("127.0.0.1"),
+		ably.WithPort(1),
+		ably.WithTLS(false),
+		ably.WithFallbackHosts([]string{}),
+		ably.WithLogLevel(ably.LogError),
+		ably.WithLogHandler(l),
+	)
+	assert.NoError(t, err)
+	_, err = rest.Time(context.Background())
+	assert.Error(t, err)
+	for i, format := range l.formats {
+		if strings.HasPrefix(format, "RestClient: failed sending a request") {
+			assert.True(t, strings.HasSuffix(format, " host=%v"), "got %q", format)
+			assert.Equal(t, []interface{}{"127.0.0.1:1"}, l.args[i])
+			return
+		}
+	}
+	t.Fatalf("expected failed request to be logged, got %q", l.formats)
+}
@@ -0,0 +1,87 @@
+package ably
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of a client's internals, e.g. to export them
+// to a monitoring system. See [ably.WithMetrics].
+//
+// Methods are called synchronously from the client's internals, possibly
+// while holding locks, so they must be safe for concurrent use and must not
+// block. Implementations should embed [ably.NopMetrics], so that they keep
+// compiling when methods are added.
+//
+// The github.com/ably/ably-go/ablyprometheus module provides a Metrics that
+// exports measurements as Prometheus metrics.
+type Metrics interface {
+	// ConnectionStateChanged is called when the Realtime connection changes
+	// state.
+	ConnectionStateChanged(change ConnectionStateChange)
+
+	// ChannelStateChanged is called when a Realtime channel changes state.
+	ChannelStateChanged(channel string, change ChannelStateChange)
+
+	// MessagesSent is called when messages are sent on the Realtime
+	// connection to a channel.
+	MessagesSent(channel string, count int)
+
+	// MessagesReceived is called when messages are received on the Realtime
+	// connection from a channel.
+	MessagesReceived(channel string, count int)
+
+	// MessageAcknowledged is called when Ably acknowledges a message or
+	// presence message sent on the Realtime connection, with the time it took
+	// since the message was sent. err is non-nil if Ably rejected the message.
+	MessageAcknowledged(latency time.Duration, err error)
+
+	// QueueLengthChanged is called when messages are added to or removed
+	// from the queues holding them until they can be sent, with the number of
+	// messages added, or negative if removed.
+	QueueLengthChanged(delta int)
+
+	// RESTFallbackAttempted is called when a REST request is retried against
+	// a fallback host (RSC15).
+	RESTFallbackAttempted(host string)
+
+	// BytesSent is called when bytes are sent to Ably, either as a REST
+	// request body or on the Realtime connection.
+	BytesSent(n int)
+
+	// BytesReceived is called when bytes are received from Ably, either as
+	// a REST response body or on the Realtime connection.
+	BytesReceived(n int)
+}
+
+// NopMetrics is a Metrics that ignores all measurements. It's meant to be
+// embedded in implementations of Metrics.
+type NopMetrics struct{}
+
+func (NopMetrics) ConnectionStateChanged(ConnectionStateChange)   {}
+func (NopMetrics) ChannelStateChanged(string, ChannelStateChange) {}
+func (NopMetrics) MessagesSent(string, int)                       {}
+func (NopMetrics) MessagesReceived(string, int)                   {}
+func (NopMetrics) MessageAcknowledged(time.Duration, error)       {}
+func (NopMetrics) QueueLengthChanged(int)                         {}
+func (NopMetrics) RESTFallbackAttempted(string)                   {}
+func (NopMetrics) BytesSent(int)                                  {}
+func (NopMetrics) BytesReceived(int)                              {}
+
+// WithMetrics makes the client report measurements of its internals to
+// metrics.
+//
+// Bytes sent and received on the Realtime connection are only measured for
+// the default transport, not for connections made by a function set with
+// [ably.WithDial].
+func WithMetrics(metrics Metrics) ClientOption {
+	return func(os *clientOptions) {
+		os.Metrics = metrics
+	}
+}
+
+func (c *clientOptions) metrics() Metrics {
+	if c == nil || c.Metrics == nil {
+		return NopMetrics{}
+	}
+	return c.Metrics
+}
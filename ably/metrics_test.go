@@ -0,0 +1,204 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics is a Metrics that records the measurements it receives.
+type recordingMetrics struct {
+	ably.NopMetrics
+
+	mtx              sync.Mutex
+	connectionStates []ably.ConnectionState
+	channelStates    []ably.ChannelState
+	sent             map[string]int
+	received         map[string]int
+	acks             []error
+	queued           int
+	fallbacks        []string
+	bytesSent        int
+	bytesReceived    int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{sent: map[string]int{}, received: map[string]int{}}
+}
+
+func (m *recordingMetrics) ConnectionStateChanged(change ably.ConnectionStateChange) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.connectionStates = append(m.connectionStates, change.Current)
+}
+
+func (m *recordingMetrics) ChannelStateChanged(channel string, change ably.ChannelStateChange) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.channelStates = append(m.channelStates, change.Current)
+}
+
+func (m *recordingMetrics) MessagesSent(channel string, count int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.sent[channel] += count
+}
+
+func (m *recordingMetrics) MessagesReceived(channel string, count int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.received[channel] += count
+}
+
+func (m *recordingMetrics) MessageAcknowledged(latency time.Duration, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.acks = append(m.acks, err)
+}
+
+func (m *recordingMetrics) QueueLengthChanged(delta int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.queued += delta
+}
+
+func (m *recordingMetrics) RESTFallbackAttempted(host string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.fallbacks = append(m.fallbacks, host)
+}
+
+func (m *recordingMetrics) BytesSent(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.bytesSent += n
+}
+
+func (m *recordingMetrics) BytesReceived(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.bytesReceived += n
+}
+
+func (m *recordingMetrics) do(f func(m *recordingMetrics)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	f(m)
+}
+
+func TestWithMetrics_Realtime(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	metrics := newRecordingMetrics()
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithMetrics(metrics),
+	)
+	assert.NoError(t, err)
+	channel := c.Channels.Get("measured")
+
+	// Published before connecting, the message is queued.
+	published := make(chan error, 1)
+	err = channel.PublishMultipleAsync([]*ably.Message{{Name: "event", Data: "data"}}, func(err error) {
+		published <- err
+	})
+	assert.NoError(t, err)
+	metrics.do(func(m *recordingMetrics) {
+		assert.Equal(t, 1, m.queued)
+	})
+
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	var msg *ably.ProtocolMessage
+	ablytest.Soon.Recv(t, &msg, out, t.Fatalf)
+	assert.Equal(t, ably.ActionMessage, msg.Action)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAck, MsgSerial: msg.MsgSerial, Count: 1}
+	ablytest.Soon.Recv(t, &err, published, t.Fatalf)
+	assert.NoError(t, err)
+
+	attached := make(chan error, 1)
+	go func() {
+		attached <- channel.Attach(context.Background())
+	}()
+	ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+	ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+	assert.NoError(t, err)
+
+	messages := make(chan *ably.Message, 2)
+	unsubscribe, err := channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+	in <- &ably.ProtocolMessage{
+		Action:   ably.ActionMessage,
+		Channel:  channel.Name,
+		Messages: []*ably.Message{{Name: "a", Data: "a"}, {Name: "b", Data: "b"}},
+	}
+	var received *ably.Message
+	ablytest.Soon.Recv(t, &received, messages, t.Fatalf)
+	ablytest.Soon.Recv(t, &received, messages, t.Fatalf)
+
+	metrics.do(func(m *recordingMetrics) {
+		assert.Equal(t, []ably.ConnectionState{ably.ConnectionStateConnecting, ably.ConnectionStateConnected}, m.connectionStates)
+		assert.Equal(t, []ably.ChannelState{ably.ChannelStateAttaching, ably.ChannelStateAttached}, m.channelStates)
+		assert.Equal(t, 0, m.queued)
+		assert.Equal(t, map[string]int{"measured": 1}, m.sent)
+		assert.Equal(t, map[string]int{"measured": 2}, m.received)
+		assert.Equal(t, []error{nil}, m.acks)
+	})
+}
+
+func TestWithMetrics_REST(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "fallback.test" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	metrics := newRecordingMetrics()
+	client, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithTLS(false),
+		ably.WithUseBinaryProtocol(false),
+		ably.WithRESTHost("primary.test"),
+		ably.WithFallbackHosts([]string{"fallback.test"}),
+		ably.WithHTTPClient(httpClient),
+		ably.WithMetrics(metrics),
+	)
+	assert.NoError(t, err)
+
+	err = client.Channels.Get("measured").Publish(context.Background(), "event", "data")
+	assert.NoError(t, err)
+	metrics.do(func(m *recordingMetrics) {
+		assert.Equal(t, []string{"fallback.test"}, m.fallbacks)
+		assert.Greater(t, m.bytesSent, 0)
+		assert.Equal(t, 2, m.bytesReceived)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -44,6 +45,8 @@ var defaultOptions = clientOptions{
 	HTTPOpenTimeout:          4 * time.Second,  //TO3l3
 	ChannelRetryTimeout:      15 * time.Second, // TO3l7
 	FallbackRetryTimeout:     10 * time.Minute,
+	HostFailureThreshold:     3,
+	HostCircuitTimeout:       30 * time.Second,
 	IdempotentRESTPublishing: true, // TO3n
 	Port:                     Port,
 	TLSPort:                  TLSPort,
@@ -301,6 +304,14 @@ type clientOptions struct {
 	// The default is 600 seconds (TO3l10).
 	FallbackRetryTimeout time.Duration
 
+	// HostFailureThreshold is the number of consecutive failures after which REST
+	// stops preferring a host. The default is 3. See WithHostCircuitBreaker.
+	HostFailureThreshold int
+
+	// HostCircuitTimeout is how long REST avoids a host after it has reached
+	// HostFailureThreshold. The default is 30 seconds. See WithHostCircuitBreaker.
+	HostCircuitTimeout time.Duration
+
 	// NoTLS when set to true, the client will use an insecure connection.
 	// The default is false, meaning a TLS connection will be used to connect to Ably (RSC18, TO3d).
 	NoTLS bool
@@ -386,7 +397,26 @@ type clientOptions struct {
 	//Trace when provided this will be used on every request.
 	Trace *httptrace.ClientTrace
 
-	// Now returns the time the library should take as current.
+	// RESTMiddleware wraps the sending of every HTTP request made by REST,
+	// outermost first. See WithRESTMiddleware.
+	RESTMiddleware []func(next RESTHandler) RESTHandler
+
+	// RetryPolicy, if set, makes REST retry failed requests. See WithRetryPolicy.
+	RetryPolicy *RetryPolicy
+
+	// PublishRateLimit, if set, limits the rate at which messages are
+	// published. See WithPublishRateLimit.
+	PublishRateLimit *PublishRateLimit
+
+	// Tracer, if set, traces the client's operations. See WithTracer.
+	Tracer Tracer
+
+	// Metrics, if set, receives measurements of the client's internals. See
+	// WithMetrics.
+	Metrics Metrics
+
+	// Now returns the time the library should take as current, and After
+	// sets its timers. See WithClock.
 	Now   func() time.Time
 	After func(context.Context, time.Duration) <-chan time.Time
 
@@ -402,13 +432,29 @@ type clientOptions struct {
 	// LogHandler controls the log output of the library. This is a function to handle each line of log output.
 	// platform specific (TO3c)
 	LogHandler Logger
+
+	// StructuredLogHandler, if set, receives the log output of the library
+	// instead of LogHandler. See WithStructuredLogHandler.
+	StructuredLogHandler StructuredLogger
+
+	// LogRedactionRules redact secrets from log output. If empty, log output
+	// isn't redacted. See WithLogRedactionRules.
+	LogRedactionRules []LogRedactionRule
+
+	// LogRedactMessageData redacts the data of messages from log output. See
+	// WithLogRedactMessageData.
+	LogRedactMessageData bool
+
+	// SessionRecorder, if set, receives a recording of the Realtime
+	// connection's protocol session. See WithSessionRecorder.
+	SessionRecorder io.Writer
 }
 
 func (opts *clientOptions) validate() error {
 	_, err := opts.getFallbackHosts()
 	if err != nil {
-		logger := opts.LogHandler
-		logger.Printf(LogError, "Error getting fallbackHosts : %v", err.Error())
+		logger := newLogger(opts)
+		logger.printf(LogError, "Error getting fallbackHosts : %v", err.Error())
 		return err
 	}
 	return nil
@@ -455,8 +501,8 @@ func (opts *clientOptions) getRealtimeHost() string {
 		return opts.RealtimeHost
 	}
 	if !empty(opts.RESTHost) {
-		logger := opts.LogHandler
-		logger.Printf(LogWarning, "restHost is set to %s but realtimeHost is not set so setting realtimeHost to %s too. If this is not what you want, please set realtimeHost explicitly.", opts.RESTHost, opts.RealtimeHost)
+		logger := newLogger(opts)
+		logger.printf(LogWarning, "restHost is set to %s but realtimeHost is not set so setting realtimeHost to %s too. If this is not what you want, please set realtimeHost explicitly.", opts.RESTHost, opts.RealtimeHost)
 		return opts.RESTHost
 	}
 	if !opts.isProductionEnvironment() {
@@ -496,7 +542,7 @@ func (opts *clientOptions) realtimeURL() (realtimeUrl string) {
 }
 
 func (opts *clientOptions) getFallbackHosts() ([]string, error) {
-	logger := opts.LogHandler
+	logger := newLogger(opts)
 	_, isDefaultPort := opts.activePort()
 	if opts.FallbackHostsUseDefault {
 		if opts.FallbackHosts != nil {
@@ -506,9 +552,9 @@ func (opts *clientOptions) getFallbackHosts() ([]string, error) {
 			return nil, errors.New("fallbackHostsUseDefault cannot be set when port or tlsPort are set")
 		}
 		if !empty(opts.Environment) {
-			logger.Printf(LogWarning, "Deprecated fallbackHostsUseDefault : There is no longer a need to set this when the environment option is also set since the library can generate the correct fallback hosts using the environment option.")
+			logger.printf(LogWarning, "Deprecated fallbackHostsUseDefault : There is no longer a need to set this when the environment option is also set since the library can generate the correct fallback hosts using the environment option.")
 		}
-		logger.Printf(LogWarning, "Deprecated fallbackHostsUseDefault : using default fallbackhosts")
+		logger.printf(LogWarning, "Deprecated fallbackHostsUseDefault : using default fallbackhosts")
 		return defaultOptions.FallbackHosts, nil
 	}
 	if opts.FallbackHosts == nil && empty(opts.RESTHost) && empty(opts.RealtimeHost) && isDefaultPort {
@@ -541,6 +587,20 @@ func (opts *clientOptions) fallbackRetryTimeout() time.Duration {
 	return defaultOptions.FallbackRetryTimeout
 }
 
+func (opts *clientOptions) hostFailureThreshold() int {
+	if opts.HostFailureThreshold > 0 {
+		return opts.HostFailureThreshold
+	}
+	return defaultOptions.HostFailureThreshold
+}
+
+func (opts *clientOptions) hostCircuitTimeout() time.Duration {
+	if opts.HostCircuitTimeout != 0 {
+		return opts.HostCircuitTimeout
+	}
+	return defaultOptions.HostCircuitTimeout
+}
+
 func (opts *clientOptions) realtimeRequestTimeout() time.Duration {
 	if opts.RealtimeRequestTimeout != 0 {
 		return opts.RealtimeRequestTimeout
@@ -1290,6 +1350,8 @@ func WithFallbackHostsUseDefault(fallbackHostsUseDefault bool) ClientOption {
 // WithDial is used for setting Dial using [ably.ClientOption].
 // Dial specifies the dial function for creating message connections used by Realtime.
 // If Dial is nil, the default websocket connection is used.
+// The connections it returns time out receives with their own timers rather
+// than the client's clock, except for those dialed by [ably.ReplaySession].
 func WithDial(dial func(protocol string, u *url.URL, timeout time.Duration) (conn, error)) ClientOption {
 	return func(os *clientOptions) {
 		os.Dial = dial
@@ -1318,6 +1380,9 @@ func applyOptionsWithDefaults(opts ...ClientOption) *clientOptions {
 		to.LogHandler = &stdLogger{Logger: log.New(os.Stderr, "", log.LstdFlags)}
 	}
 	to.LogHandler = filteredLogger{Logger: to.LogHandler, Level: to.LogLevel}
+	if to.StructuredLogHandler != nil {
+		to.StructuredLogHandler = filteredStructuredLogger{Logger: to.StructuredLogHandler, Level: to.LogLevel}
+	}
 
 	return &to
 }
@@ -0,0 +1,160 @@
+package ably
+
+import (
+	"context"
+)
+
+// Paginated represents a request for a paginated list of items of type T,
+// ready to be performed by its Pages, Items or Collect methods, or, with Go
+// 1.23 or later, its All method. Nothing is requested until one of them is
+// called, and each call performs the request anew.
+//
+// See package-level documentation => [ably] Pagination for more details.
+type Paginated[T any] struct {
+	r paginatedRequest
+
+	// decoder, if set, wraps the slice a page of items is decoded into, for
+	// items that need further decoding, e.g. decrypting message data.
+	decoder func(*[]T) interface{}
+	// rawPages makes Pages skip decoder, leaving items as sent by Ably, as
+	// History's Pages always has.
+	rawPages bool
+}
+
+func newPaginated[T any](r paginatedRequest, decoder func(*[]T) interface{}) Paginated[T] {
+	return Paginated[T]{r: r, decoder: decoder}
+}
+
+func (r Paginated[T]) decodeInto(dst *[]T) interface{} {
+	if r.decoder == nil {
+		return dst
+	}
+	return r.decoder(dst)
+}
+
+// Pages returns an iterator for whole pages of items.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (r Paginated[T]) Pages(ctx context.Context) (*PaginatedPages[T], error) {
+	if r.rawPages {
+		return r.pages(ctx, func(dst *[]T) interface{} { return dst })
+	}
+	return r.pages(ctx, r.decodeInto)
+}
+
+func (r Paginated[T]) pages(ctx context.Context, decodeInto func(*[]T) interface{}) (*PaginatedPages[T], error) {
+	res := PaginatedPages[T]{decodeInto: decodeInto}
+	return &res, res.load(ctx, r.r)
+}
+
+// Items returns a convenience iterator for single items, over an underlying
+// paginated iterator.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (r Paginated[T]) Items(ctx context.Context) (*PaginatedItems[T], error) {
+	var res PaginatedItems[T]
+	var err error
+	res.next, err = res.loadItems(ctx, r.r, func() (interface{}, func() int) {
+		res.items = nil // avoid mutating already returned items
+		return r.decodeInto(&res.items), func() int { return len(res.items) }
+	})
+	return &res, err
+}
+
+// Resume returns an iterator for whole pages of items, starting at the page
+// cursor points to. The cursor must have been returned by the Cursor method
+// of a result of an equivalent request, i.e. one for the same resource, e.g.
+// the history of the same channel; other requests are rejected with an error.
+//
+// The request is authenticated as the ones made by Pages, and its items are
+// decoded as by Items, so e.g. messages of an encrypted channel are
+// decrypted.
+func (r Paginated[T]) Resume(ctx context.Context, cursor string) (*PaginatedPages[T], error) {
+	r.r = r.r.fromCursor(cursor)
+	return r.pages(ctx, r.decodeInto)
+}
+
+// Collect returns up to max items, fetching as many pages as needed. If max
+// is zero or negative, all items are returned.
+//
+// On error, the items fetched so far are returned along with it.
+func (r Paginated[T]) Collect(ctx context.Context, max int) ([]T, error) {
+	items, err := r.Items(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var all []T
+	for (max <= 0 || len(all) < max) && items.Next(ctx) {
+		all = append(all, items.Item())
+	}
+	return all, items.Err()
+}
+
+// A PaginatedPages is an iterator for the pages of items resulting from a
+// [ably.Paginated] request.
+//
+// See package-level documentation => [ably] Pagination for more details.
+type PaginatedPages[T any] struct {
+	PaginatedResult
+	items      []T
+	decodeInto func(*[]T) interface{}
+}
+
+// Next retrieves the next page of results.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedPages[T]) Next(ctx context.Context) bool {
+	p.items = nil // avoid mutating already returned items
+	return p.next(ctx, p.decodeInto(&p.items))
+}
+
+// IsLast returns true if the page is last page.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedPages[T]) IsLast(ctx context.Context) bool {
+	return !p.HasNext(ctx)
+}
+
+// HasNext returns true is there are more pages available.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedPages[T]) HasNext(ctx context.Context) bool {
+	return p.nextLink != ""
+}
+
+// Items returns the current page of results.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedPages[T]) Items() []T {
+	return p.items
+}
+
+// A PaginatedItems is an iterator for the single items resulting from a
+// [ably.Paginated] request, fetching pages under the hood as needed.
+//
+// See package-level documentation => [ably] Pagination for more details.
+type PaginatedItems[T any] struct {
+	PaginatedResult
+	items []T
+	item  T
+	next  func(context.Context) (int, bool)
+}
+
+// Next retrieves the next result.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedItems[T]) Next(ctx context.Context) bool {
+	i, ok := p.next(ctx)
+	if !ok {
+		return false
+	}
+	p.item = p.items[i]
+	return true
+}
+
+// Item returns the current result.
+//
+// See package-level documentation => [ably] Pagination for more details.
+func (p *PaginatedItems[T]) Item() T {
+	return p.item
+}
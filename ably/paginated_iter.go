@@ -0,0 +1,38 @@
+//go:build go1.23
+// +build go1.23
+
+package ably
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over every item, fetching pages as needed. If
+// fetching a page fails, the error is yielded along with the zero value of T,
+// and iteration stops. It requires Go 1.23 or later.
+//
+//	for msg, err := range channel.History().All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(msg.Data)
+//	}
+func (r Paginated[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		items, err := r.Items(ctx)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		for items.Next(ctx) {
+			if !yield(items.Item(), nil) {
+				return
+			}
+		}
+		if err := items.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
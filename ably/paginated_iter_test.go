@@ -0,0 +1,64 @@
+//go:build !integration && go1.23
+// +build !integration,go1.23
+
+package ably_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginated_All(t *testing.T) {
+	var messages []*ably.Message
+	for i := 0; i < 5; i++ {
+		messages = append(messages, &ably.Message{ID: fmt.Sprintf("msg:%d", i), Name: "event", Data: fmt.Sprint(i)})
+	}
+	failing := false
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing && r.URL.Query().Get("start") != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"code": 50000, "statusCode": 500, "message": "boom"}})
+			return
+		}
+		writePage(w, r, messages)
+	}))
+	channel := client.Channels.Get("test")
+	ctx := context.Background()
+
+	t.Run("stops when the loop breaks", func(t *testing.T) {
+		var ids []string
+		for m, err := range channel.History(ably.HistoryWithLimit(2)).All(ctx) {
+			assert.NoError(t, err)
+			ids = append(ids, m.ID)
+			if len(ids) == 3 {
+				break
+			}
+		}
+		assert.Equal(t, []string{"msg:0", "msg:1", "msg:2"}, ids)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		failing = true
+		defer func() { failing = false }()
+
+		var errs []error
+		var got int
+		for _, err := range channel.History(ably.HistoryWithLimit(2)).All(ctx) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			got++
+		}
+		assert.Equal(t, 2, got)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, ably.ErrInternalError, ably.UnwrapErrorCode(errs[0]))
+	})
+}
@@ -2,11 +2,15 @@ package ably
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
 	"reflect"
 	"regexp"
+	"strings"
 )
 
 type Direction string
@@ -22,6 +26,10 @@ type paginatedRequest struct {
 	params  url.Values
 
 	query queryFunc
+
+	// err, if set, is returned when loading the first page, for requests
+	// that can't be performed, e.g. because of an invalid cursor.
+	err error
 }
 
 func (r *REST) newPaginatedRequest(path, rawPath string, params url.Values) paginatedRequest {
@@ -52,7 +60,12 @@ type PaginatedResult struct {
 // load - It loads first page of results. Must be called from the type-specific
 // wrapper Pages method that creates the PaginatedResult object.
 func (p *PaginatedResult) load(ctx context.Context, r paginatedRequest) error {
-	p.basePath = path.Dir(r.path)
+	if r.err != nil {
+		return r.err
+	}
+	// Links are resolved against the escaped path, so that they keep
+	// segments such as channel names escaped.
+	p.basePath = path.Dir(r.escapedPath())
 	p.firstLink = (&url.URL{
 		Path:     r.path,
 		RawPath:  r.rawPath,
@@ -165,6 +178,83 @@ func (p *PaginatedResult) First(ctx context.Context) error {
 	return p.goTo(ctx, p.firstLink)
 }
 
+// Cursor returns an opaque cursor pointing to the page after the current one,
+// or an empty string if there are no more pages.
+//
+// Cursors can be stored, e.g. to checkpoint a long-running read of a channel's
+// history, and later passed to the Resume method of the request that
+// produced them, or to a WithCursor option such as [ably.HistoryWithCursor],
+// to continue reading from that page, even from another process. A cursor
+// holds no credentials; the resumed request is authenticated with the client's
+// own.
+//
+// A cursor always points to a whole page, so when iterating with Items, any
+// items left in the current page are skipped on resuming.
+func (p *PaginatedResult) Cursor() string {
+	if p.nextLink == "" {
+		return ""
+	}
+	b, _ := json.Marshal(paginationCursor{Link: p.nextLink})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// paginationCursor is the decoded form of the cursors returned by
+// PaginatedResult.Cursor.
+type paginationCursor struct {
+	Link string `json:"link"`
+}
+
+// fromCursor returns a request for the page cursor points to, which must have
+// been returned by a result of r or of an equivalent request.
+func (r paginatedRequest) fromCursor(cursor string) paginatedRequest {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	var c paginationCursor
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	var u *url.URL
+	if err == nil {
+		u, err = url.Parse(c.Link)
+	}
+	if err != nil {
+		r.err = newError(ErrBadRequest, fmt.Errorf("invalid pagination cursor: %w", err))
+		return r
+	}
+	if !samePathSegments(u.EscapedPath(), r.escapedPath()) {
+		r.err = newErrorf(ErrBadRequest, "pagination cursor for %q can't be used to resume a request for %q", u.EscapedPath(), r.escapedPath())
+		return r
+	}
+	r.params = u.Query()
+	return r
+}
+
+func (r paginatedRequest) escapedPath() string {
+	return (&url.URL{Path: r.path, RawPath: r.rawPath}).EscapedPath()
+}
+
+// samePathSegments reports whether two escaped paths have the same segments
+// once unescaped. Unlike comparing unescaped paths, it tells an escaped slash
+// within a segment, e.g. of a channel name, from a separator; unlike
+// comparing escaped paths, it doesn't depend on which characters were
+// escaped.
+func samePathSegments(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		x, err := url.PathUnescape(as[i])
+		if err != nil {
+			return false
+		}
+		y, err := url.PathUnescape(bs[i])
+		if err != nil || x != y {
+			return false
+		}
+	}
+	return true
+}
+
 // Err returns the error that caused Next to fail, if there was one.
 func (p *PaginatedResult) Err() error {
 	return p.err
@@ -0,0 +1,194 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginated(t *testing.T) {
+	var messages []*ably.Message
+	for i := 0; i < 5; i++ {
+		messages = append(messages, &ably.Message{ID: fmt.Sprintf("msg:%d", i), Name: "event", Data: fmt.Sprint(i)})
+	}
+	failing := false
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing && r.URL.Query().Get("start") != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"code": 50000, "statusCode": 500, "message": "boom"}})
+			return
+		}
+		writePage(w, r, messages)
+	}))
+	channel := client.Channels.Get("test")
+	ctx := context.Background()
+
+	t.Run("TestPagination", func(t *testing.T) {
+		err := ablytest.TestPagination(messages, channel.History(ably.HistoryWithLimit(2)), 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Collect", func(t *testing.T) {
+		all, err := channel.History(ably.HistoryWithLimit(2)).Collect(ctx, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, messages, all)
+
+		some, err := channel.History(ably.HistoryWithLimit(2)).Collect(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, messages[:3], some)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		failing = true
+		defer func() { failing = false }()
+
+		some, err := channel.History(ably.HistoryWithLimit(2)).Collect(ctx, 0)
+		assert.Equal(t, ably.ErrInternalError, ably.UnwrapErrorCode(err))
+		assert.Equal(t, messages[:2], some)
+	})
+}
+
+func TestHistoryRequest_OnlyItemsDecodeData(t *testing.T) {
+	key, err := ably.Crypto.GenerateRandomKey(128)
+	assert.NoError(t, err)
+	cipher, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: key}),
+	}).GetCipher()
+	assert.NoError(t, err)
+	encrypted, err := ably.MessageWithEncodedData(ably.Message{ID: "msg:0", Data: "secret"}, cipher)
+	assert.NoError(t, err)
+	binary, err := ably.MessageWithEncodedData(ably.Message{ID: "msg:1", Data: []byte{1, 2, 3}}, nil)
+	assert.NoError(t, err)
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writePage(w, r, []ably.Message{encrypted, binary})
+	}))
+	channel := client.Channels.Get("test", ably.ChannelWithCipherKey(key))
+	ctx := context.Background()
+
+	// Pages leaves message data as sent by Ably.
+	pages, err := channel.History().Pages(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pages.Next(ctx))
+	items := pages.Items()
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, encrypted.Data, items[0].Data)
+		assert.Equal(t, encrypted.Encoding, items[0].Encoding)
+		assert.Equal(t, binary.Data, items[1].Data)
+		assert.Equal(t, binary.Encoding, items[1].Encoding)
+	}
+
+	// Items decodes and decrypts it.
+	items, err = channel.History().Collect(ctx, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "secret", items[0].Data)
+		assert.Empty(t, items[0].Encoding)
+		assert.Equal(t, []byte{1, 2, 3}, items[1].Data)
+		assert.Empty(t, items[1].Encoding)
+	}
+}
+
+func TestPaginated_Cursor(t *testing.T) {
+	var messages []*ably.Message
+	var presence []*ably.PresenceMessage
+	for i := 0; i < 5; i++ {
+		messages = append(messages, &ably.Message{ID: fmt.Sprintf("msg:%d", i), Data: fmt.Sprint(i)})
+		presence = append(presence, &ably.PresenceMessage{Message: ably.Message{ID: fmt.Sprintf("pres:%d", i)}, Action: ably.PresenceActionEnter})
+	}
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/channels/test/history", "/channels/other/history":
+			writePage(w, r, messages)
+		case "/channels/test/presence/history":
+			writePage(w, r, presence)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	channel := client.Channels.Get("test")
+	ctx := context.Background()
+
+	pages, err := channel.History(ably.HistoryWithLimit(2)).Pages(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pages.Next(ctx))
+	cursor := pages.Cursor()
+	assert.NotEmpty(t, cursor)
+
+	t.Run("Resume", func(t *testing.T) {
+		resumed, err := channel.History().Resume(ctx, cursor)
+		assert.NoError(t, err)
+		var got []*ably.Message
+		for resumed.Next(ctx) {
+			assert.LessOrEqual(t, len(resumed.Items()), 2, "the limit of the original request should be kept")
+			got = append(got, resumed.Items()...)
+			if !resumed.HasNext(ctx) {
+				assert.Empty(t, resumed.Cursor())
+			}
+		}
+		assert.NoError(t, resumed.Err())
+		assert.Equal(t, messages[2:], got)
+	})
+
+	t.Run("HistoryWithCursor", func(t *testing.T) {
+		got, err := channel.History(ably.HistoryWithCursor(cursor)).Collect(ctx, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, messages[2:], got)
+	})
+
+	t.Run("PresenceHistoryWithCursor", func(t *testing.T) {
+		pages, err := channel.Presence.History(ably.PresenceHistoryWithLimit(3)).Pages(ctx)
+		assert.NoError(t, err)
+		assert.True(t, pages.Next(ctx))
+		got, err := channel.Presence.History(ably.PresenceHistoryWithCursor(pages.Cursor())).Collect(ctx, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, presence[3:], got)
+	})
+
+	t.Run("channel names that need escaping", func(t *testing.T) {
+		const name = "a/b c%:d"
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.EscapedPath() != "/channels/"+url.PathEscape(name)+"/history" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writePage(w, r, messages)
+		}))
+		channel := client.Channels.Get(name)
+		pages, err := channel.History(ably.HistoryWithLimit(2)).Pages(ctx)
+		assert.NoError(t, err)
+		assert.True(t, pages.Next(ctx))
+		got, err := channel.History().Resume(ctx, pages.Cursor())
+		assert.NoError(t, err)
+		var ids []string
+		for got.Next(ctx) {
+			for _, m := range got.Items() {
+				ids = append(ids, m.ID)
+			}
+		}
+		assert.NoError(t, got.Err())
+		assert.Equal(t, []string{"msg:2", "msg:3", "msg:4"}, ids)
+
+		_, err = client.Channels.Get("a").History().Resume(ctx, pages.Cursor())
+		assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+	})
+
+	t.Run("rejects cursors of other requests", func(t *testing.T) {
+		_, err := client.Channels.Get("other").History().Resume(ctx, cursor)
+		assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+		_, err = channel.Presence.History(ably.PresenceHistoryWithCursor(cursor)).Pages(ctx)
+		assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+		_, err = channel.History(ably.HistoryWithCursor("not a cursor")).Items(ctx)
+		assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+	})
+}
@@ -1,5 +1,7 @@
 package ably
 
+import "time"
+
 type channelParams map[string]string
 
 // ChannelMode Describes the possible flags used to configure client capabilities, using [ably.ChannelOption].
@@ -52,8 +54,23 @@ func channelModeFromFlag(flags protoFlag) []ChannelMode {
 // such as encryption, [ably.ChannelMode] and channel parameters.
 // It defines options provided for creating a new channel.
 type protoChannelOptions struct {
-	Cipher CipherParams
-	cipher channelCipher
-	Params channelParams
-	Modes  []ChannelMode
+	Cipher      CipherParams
+	KeyProvider CipherKeyProvider
+	// KeyRefreshInterval is how often KeyProvider is asked for the active
+	// key again. See ChannelWithCipherKeyRefreshInterval.
+	KeyRefreshInterval time.Duration
+	cipher             channelCipher
+	Params             channelParams
+	Modes              []ChannelMode
+	// PublishRateLimit, if set, limits the rate at which messages are
+	// published to the channel. See ChannelWithPublishRateLimit.
+	PublishRateLimit *PublishRateLimit
+	// channel is the name of the channel the options belong to, passed to
+	// KeyProvider.
+	channel string
+	// clientOpts are the options of the client the channel belongs to, for
+	// its clock and timeouts.
+	clientOpts *clientOptions
+	// providerKeyring caches the cipher built from KeyProvider's keys.
+	providerKeyring *providerKeyring
 }
@@ -7,6 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 )
 
 // CipherAlgorithm is a supported algorithm for channel encryption.
@@ -39,12 +42,17 @@ type CipherMode uint
 
 const (
 	CipherCBC CipherMode = 1 + iota
+	// CipherGCM is the authenticated AES-GCM mode. Unlike CBC, decryption fails
+	// if the ciphertext has been modified in transit.
+	CipherGCM
 )
 
 func (c CipherMode) String() string {
 	switch c {
 	case CipherCBC:
 		return "cbs"
+	case CipherGCM:
+		return "gcm"
 	default:
 		return ""
 	}
@@ -66,14 +74,31 @@ type CipherParams struct {
 	// Key is the private key used to encrypt and decrypt payloads (TZ2d).
 	Key []byte
 	// Mode is the cipher mode.
-	// Only CBC is supported and is the default value ((TZ2c).
+	// CBC and GCM are supported; CBC is the default value (TZ2c).
 	Mode CipherMode
+	// KeyID identifies Key when keys are rotated. When set, or when PreviousKeys
+	// is not empty, the key ID is appended to the cipher encoding of every
+	// published message so that receivers can pick the matching key.
+	KeyID string
+	// PreviousKeys holds keys that are no longer used for encryption but are
+	// still needed to decrypt messages published before a key rotation.
+	PreviousKeys []CipherKey
 	// iv is the initialization vector. Used only for comparing resulting
 	// ciphertext with test fixtures; production code should always use a random
 	// unique IV per encrypted message.CipherParamOptions
 	iv []byte
 }
 
+// CipherKey is an encryption key identified by an ID, used to decrypt messages
+// that were encrypted before a key rotation.
+type CipherKey struct {
+	// ID identifies the key in the encoding of messages encrypted with it.
+	// It must not contain '/' or ':'.
+	ID string
+	// Key is the private key.
+	Key []byte
+}
+
 // GetCipher returns a ChannelCipher based on the algorithms set in the
 // ChannelOptions.CipherParams.
 func (c *protoChannelOptions) GetCipher() (channelCipher, error) {
@@ -83,9 +108,20 @@ func (c *protoChannelOptions) GetCipher() (channelCipher, error) {
 	if c.cipher != nil {
 		return c.cipher, nil
 	}
+	if c.KeyProvider != nil {
+		return c.providerCipher()
+	}
+	if c.Cipher.KeyID != "" || len(c.Cipher.PreviousKeys) > 0 {
+		encoder, err := newKeyringCipher(c.Cipher)
+		if err != nil {
+			return nil, err
+		}
+		c.cipher = encoder
+		return encoder, nil
+	}
 	switch c.Cipher.Algorithm {
 	case CipherAES:
-		encoder, err := newCBCCipher(c.Cipher)
+		encoder, err := newCipher(c.Cipher)
 		if err != nil {
 			return nil, err
 		}
@@ -96,6 +132,20 @@ func (c *protoChannelOptions) GetCipher() (channelCipher, error) {
 	}
 }
 
+// hasCipher reports whether a cipher key has been configured.
+func (c *protoChannelOptions) hasCipher() bool {
+	return c != nil && (len(c.Cipher.Key) > 0 || c.KeyProvider != nil)
+}
+
+// configuredCipher returns the channel's cipher, or nil if encryption isn't
+// configured.
+func (c *protoChannelOptions) configuredCipher() (channelCipher, error) {
+	if !c.hasCipher() {
+		return nil, nil
+	}
+	return c.GetCipher()
+}
+
 // channelCipher is an interface for encrypting and decrypting channel messages.
 type channelCipher interface {
 	Encrypt(plainText []byte) ([]byte, error)
@@ -103,7 +153,18 @@ type channelCipher interface {
 	GetAlgorithm() string
 }
 
-var _ channelCipher = (*cbcCipher)(nil)
+// cipherSelector is implemented by ciphers that hold more than one key. It
+// returns the cipher able to decrypt data with the given cipher encoding.
+type cipherSelector interface {
+	cipherForEncoding(encoding string) (channelCipher, error)
+}
+
+var (
+	_ channelCipher  = (*cbcCipher)(nil)
+	_ channelCipher  = (*gcmCipher)(nil)
+	_ channelCipher  = (*keyringCipher)(nil)
+	_ cipherSelector = (*keyringCipher)(nil)
+)
 
 // cbcCipher implements ChannelCipher that uses CBC mode.
 type cbcCipher struct {
@@ -195,6 +256,347 @@ func (c *cbcCipher) GetAlgorithm() string {
 	return c.algorithm
 }
 
+// errCipherAuthFailed is returned when an authenticated cipher rejects a
+// ciphertext, either because it was tampered with or because it was encrypted
+// with a different key.
+var errCipherAuthFailed = newErrorf(ErrInvalidMessageDataOrEncoding, "message authentication failed: data was tampered with or encrypted with a different key")
+
+// gcmCipher implements ChannelCipher that uses the authenticated GCM mode.
+type gcmCipher struct {
+	algorithm string
+	params    CipherParams
+}
+
+// newGCMCipher returns a new gcmCipher that uses opts to initialize.
+func newGCMCipher(opts CipherParams) (*gcmCipher, error) {
+	if opts.Algorithm != CipherAES {
+		return nil, errors.New("unknown cipher algorithm")
+	}
+	if opts.Mode != CipherGCM {
+		return nil, errors.New("unknown cipher mode")
+	}
+	algo := fmt.Sprintf("cipher+%s-%d-gcm", opts.Algorithm, opts.KeyLength)
+	return &gcmCipher{
+		algorithm: algo,
+		params:    opts,
+	}, nil
+}
+
+func (c *gcmCipher) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.params.Key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts plainText using AES-GCM and returns the nonce followed by
+// the sealed ciphertext and its authentication tag.
+func (c *gcmCipher) Encrypt(plainText []byte) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := c.params.iv
+	if nonce == nil {
+		nonce = make([]byte, aead.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(nonce))
+	}
+	out := make([]byte, len(nonce), len(nonce)+len(plainText)+aead.Overhead())
+	copy(out, nonce)
+	return aead.Seal(out, nonce, plainText, nil), nil
+}
+
+// Decrypt verifies and decrypts cipherText using AES-GCM. It returns an error
+// if the authentication tag doesn't match.
+func (c *gcmCipher) Decrypt(cipherText []byte) ([]byte, error) {
+	aead, err := c.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("ciphertext is shorter than the nonce and authentication tag")
+	}
+	nonce, sealed := cipherText[:aead.NonceSize()], cipherText[aead.NonceSize():]
+	out, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errCipherAuthFailed
+	}
+	return out, nil
+}
+
+// GetAlgorithm returns the encoding identifier used by this gcmCipher.
+func (c *gcmCipher) GetAlgorithm() string {
+	return c.algorithm
+}
+
+// cipherKeyIDSeparator separates the algorithm from the key ID in a cipher
+// encoding, e.g. "cipher+aes-256-gcm:2024-01".
+const cipherKeyIDSeparator = ":"
+
+// keyringCipher implements ChannelCipher over a set of keys identified by key
+// IDs. It encrypts with the active key and decrypts with whichever key the
+// message encoding names.
+type keyringCipher struct {
+	active   channelCipher
+	activeID string
+	params   CipherParams
+	// lookup, if set, fetches keys that aren't in keys.
+	lookup func(keyID string) ([]byte, error)
+	// maxKeys, if set, bounds how many keys lookup may add to keys.
+	maxKeys int
+	// now, if set, makes the keys lookup fails to fetch be remembered for
+	// failedCipherKeyTTL, so that they're not looked up again meanwhile.
+	now func() time.Time
+
+	mtx     sync.Mutex
+	keys    map[string][]byte
+	ciphers map[keyringCipherID]channelCipher
+	failed  map[string]failedCipherKey
+}
+
+// failedCipherKey is why a keyringCipher's lookup failed to fetch a key.
+type failedCipherKey struct {
+	err error
+	at  time.Time
+}
+
+// keyringCipherID identifies a cipher of a keyringCipher, as parsed from a
+// message encoding.
+type keyringCipherID struct {
+	mode      CipherMode
+	keyLength int
+	keyID     string
+}
+
+// maxKeyringCiphers bounds how many ciphers a keyringCipher caches, since
+// the encodings they're parsed from come from whoever published messages.
+const maxKeyringCiphers = 32
+
+// newKeyringCipher returns a keyringCipher whose active key is opts.Key,
+// identified by opts.KeyID, and which can also decrypt with opts.PreviousKeys.
+func newKeyringCipher(opts CipherParams) (*keyringCipher, error) {
+	keys := make(map[string][]byte, len(opts.PreviousKeys)+1)
+	for _, k := range opts.PreviousKeys {
+		if err := validateCipherKeyID(k.ID); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate cipher key ID %q", k.ID)
+		}
+		keys[k.ID] = k.Key
+	}
+	if err := validateCipherKeyID(opts.KeyID); err != nil {
+		return nil, err
+	}
+	if _, ok := keys[opts.KeyID]; ok {
+		return nil, fmt.Errorf("cipher key ID %q is used by both the active and a previous key", opts.KeyID)
+	}
+	keys[opts.KeyID] = opts.Key
+	opts.PreviousKeys = nil
+	return newKeyring(opts, keys, nil)
+}
+
+func newKeyring(opts CipherParams, keys map[string][]byte, lookup func(keyID string) ([]byte, error)) (*keyringCipher, error) {
+	active, err := newCipher(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &keyringCipher{
+		active:   active,
+		activeID: opts.KeyID,
+		params:   opts,
+		lookup:   lookup,
+		keys:     keys,
+		ciphers:  make(map[keyringCipherID]channelCipher),
+		failed:   make(map[string]failedCipherKey),
+	}, nil
+}
+
+func validateCipherKeyID(id string) error {
+	if id == "" {
+		return errors.New("cipher key ID must be provided when rotating keys")
+	}
+	if strings.ContainsAny(id, "/"+cipherKeyIDSeparator) {
+		return fmt.Errorf("cipher key ID %q must not contain '/' or %q", id, cipherKeyIDSeparator)
+	}
+	return nil
+}
+
+// newCipher returns a cipher for the mode set in opts.
+func newCipher(opts CipherParams) (channelCipher, error) {
+	switch opts.Mode {
+	case CipherGCM:
+		return newGCMCipher(opts)
+	default:
+		return newCBCCipher(opts)
+	}
+}
+
+// Encrypt encrypts plainText with the active key.
+func (c *keyringCipher) Encrypt(plainText []byte) ([]byte, error) {
+	return c.active.Encrypt(plainText)
+}
+
+// Decrypt decrypts cipherText with the active key. Callers that know the
+// message encoding should use cipherForEncoding instead.
+func (c *keyringCipher) Decrypt(cipherText []byte) ([]byte, error) {
+	return c.active.Decrypt(cipherText)
+}
+
+// GetAlgorithm returns the encoding identifier of the active key, including
+// its key ID.
+func (c *keyringCipher) GetAlgorithm() string {
+	if c.activeID == "" {
+		return c.active.GetAlgorithm()
+	}
+	return c.active.GetAlgorithm() + cipherKeyIDSeparator + c.activeID
+}
+
+// cipherForEncoding returns a cipher for a cipher encoding such as
+// "cipher+aes-128-cbc:old". Encodings without a key ID predate key rotation
+// and are decrypted with the active key. The mode is taken from the encoding,
+// so messages encrypted before a switch from CBC to GCM still decrypt.
+func (c *keyringCipher) cipherForEncoding(encoding string) (channelCipher, error) {
+	id, err := c.parseCipherEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	c.mtx.Lock()
+	cipher, ok := c.ciphers[id]
+	c.mtx.Unlock()
+	if ok {
+		return cipher, nil
+	}
+	key, err := c.key(id.keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(key)*8 != id.keyLength {
+		return nil, fmt.Errorf("cipher encoding %s names a %d-bit key, but key %q is %d-bit", encoding, id.keyLength, id.keyID, len(key)*8)
+	}
+	params := c.params
+	params.Key = key
+	params.KeyLength = len(key) * 8
+	params.KeyID = id.keyID
+	params.Mode = id.mode
+	if id.keyID != c.activeID {
+		params.iv = nil
+	}
+	cipher, err = newCipher(params)
+	if err != nil {
+		return nil, err
+	}
+	c.mtx.Lock()
+	if len(c.ciphers) >= maxKeyringCiphers {
+		for evicted := range c.ciphers {
+			delete(c.ciphers, evicted)
+			break
+		}
+	}
+	c.ciphers[id] = cipher
+	c.mtx.Unlock()
+	return cipher, nil
+}
+
+// parseCipherEncoding parses a cipher encoding such as
+// "cipher+aes-128-cbc:old" into the mode, key length and key ID it names.
+// Encodings without a key ID name the active key.
+func (c *keyringCipher) parseCipherEncoding(encoding string) (keyringCipherID, error) {
+	algo, keyID, _ := strings.Cut(encoding, cipherKeyIDSeparator)
+	if keyID == "" {
+		keyID = c.activeID
+	}
+	var id keyringCipherID
+	switch {
+	case strings.HasSuffix(algo, "-"+CipherGCM.String()):
+		id.mode = CipherGCM
+	case strings.HasSuffix(algo, "-cbc"):
+		id.mode = CipherCBC
+	default:
+		return keyringCipherID{}, fmt.Errorf("unsupported cipher encoding %s", encoding)
+	}
+	if _, err := fmt.Sscanf(algo, "cipher+aes-%d-", &id.keyLength); err != nil {
+		return keyringCipherID{}, fmt.Errorf("unsupported cipher encoding %s", encoding)
+	}
+	id.keyID = keyID
+	return id, nil
+}
+
+// keyFetchNeeded reports whether getting a cipher for encoding would call
+// lookup.
+func (c *keyringCipher) keyFetchNeeded(encoding string) bool {
+	id, err := c.parseCipherEncoding(encoding)
+	if err != nil || c.lookup == nil {
+		return false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.keys[id.keyID]; ok {
+		return false
+	}
+	return c.recentFailure(id.keyID) == nil
+}
+
+// recentFailure returns why lookup failed to fetch the key with id, if it
+// did less than failedCipherKeyTTL ago. c.mtx must be held.
+func (c *keyringCipher) recentFailure(id string) error {
+	failed, ok := c.failed[id]
+	if !ok || c.now == nil || c.now().Sub(failed.at) >= failedCipherKeyTTL {
+		return nil
+	}
+	return failed.err
+}
+
+func (c *keyringCipher) key(id string) ([]byte, error) {
+	c.mtx.Lock()
+	key, ok := c.keys[id]
+	failed := c.recentFailure(id)
+	c.mtx.Unlock()
+	if ok {
+		return key, nil
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	if c.lookup == nil {
+		return nil, fmt.Errorf("no cipher key with ID %q", id)
+	}
+	key, err := c.lookup(id)
+	if err != nil {
+		if c.now != nil {
+			c.mtx.Lock()
+			if len(c.failed) >= maxKeyringCiphers {
+				for evicted := range c.failed {
+					delete(c.failed, evicted)
+					break
+				}
+			}
+			c.failed[id] = failedCipherKey{err: err, at: c.now()}
+			c.mtx.Unlock()
+		}
+		return nil, err
+	}
+	c.mtx.Lock()
+	delete(c.failed, id)
+	if c.maxKeys > 0 && len(c.keys) >= c.maxKeys {
+		for evicted := range c.keys {
+			if evicted != c.activeID {
+				delete(c.keys, evicted)
+				break
+			}
+		}
+	}
+	c.keys[id] = key
+	c.mtx.Unlock()
+	return key, nil
+}
+
 // pkcs7Pad appends padding.
 func pkcs7Pad(data []byte, blocklen int) ([]byte, error) {
 	if blocklen <= 0 {
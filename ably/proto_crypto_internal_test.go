@@ -0,0 +1,50 @@
+//go:build !integration
+// +build !integration
+
+package ably
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyringCipher_CipherCache(t *testing.T) {
+	params := CipherParams{
+		Algorithm: CipherAES,
+		KeyLength: 256,
+		Key:       make([]byte, 32),
+		Mode:      CipherCBC,
+		KeyID:     "v2",
+	}
+	keyring, err := newKeyring(params, map[string][]byte{"v2": params.Key}, func(keyID string) ([]byte, error) {
+		return make([]byte, 16), nil
+	})
+	assert.NoError(t, err)
+
+	// Encodings naming the same mode and key share a cipher.
+	c1, err := keyring.cipherForEncoding("cipher+aes-256-cbc")
+	assert.NoError(t, err)
+	c2, err := keyring.cipherForEncoding("cipher+aes-256-cbc:v2")
+	assert.NoError(t, err)
+	assert.Same(t, c1, c2)
+	assert.Len(t, keyring.ciphers, 1)
+
+	_, err = keyring.cipherForEncoding("cipher+des-256-cbc:v2")
+	assert.Error(t, err)
+	_, err = keyring.cipherForEncoding("cipher+aes-256-ctr:v2")
+	assert.Error(t, err)
+	// The key named must be as long as the encoding says.
+	_, err = keyring.cipherForEncoding("cipher+aes-128-cbc:v2")
+	assert.Error(t, err)
+	_, err = keyring.cipherForEncoding("cipher+aes-256-cbc:old")
+	assert.Error(t, err)
+
+	// However many keys messages name, the cache stays bounded.
+	for i := 0; i < 2*maxKeyringCiphers; i++ {
+		_, err := keyring.cipherForEncoding(fmt.Sprintf("cipher+aes-128-gcm:k%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Len(t, keyring.ciphers, maxKeyringCiphers)
+}
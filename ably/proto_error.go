@@ -26,3 +26,16 @@ func (e *errorInfo) FromMap(ctx map[string]interface{}) {
 		e.Server = v.(string)
 	}
 }
+
+// toProto returns e in the shape of errors sent by Ably, or nil if e is nil.
+func (e *ErrorInfo) toProto() *errorInfo {
+	if e == nil {
+		return nil
+	}
+	return &errorInfo{
+		StatusCode: e.StatusCode,
+		Code:       int(e.Code),
+		HRef:       e.HRef,
+		Message:    e.Message(),
+	}
+}
@@ -175,11 +175,19 @@ func (m Message) withDecodedData(cipher channelCipher) (Message, error) {
 				if cipher == nil {
 					return m, fmt.Errorf("message data is encrypted as %s, but cipher wasn't provided", encoding)
 				}
+				decrypter := cipher
+				if s, ok := cipher.(cipherSelector); ok {
+					c, err := s.cipherForEncoding(encoding)
+					if err != nil {
+						return m, fmt.Errorf("decrypting message data: %w", err)
+					}
+					decrypter = c
+				}
 				d, err := coerceBytes(m.Data)
 				if err != nil {
 					return m, err
 				}
-				d, err = cipher.Decrypt(d)
+				d, err = decrypter.Decrypt(d)
 				if err != nil {
 					return m, fmt.Errorf("decrypting message data: %w", err)
 				}
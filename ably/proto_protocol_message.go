@@ -3,6 +3,8 @@ package ably
 import (
 	"fmt"
 	"time"
+
+	"github.com/ably/ably-go/ably/internal/ablyutil"
 )
 
 // TR3
@@ -197,10 +199,19 @@ type conn interface {
 	// Receive reads ProtocolMessage from the connection.
 	// It is expected to block until whole message is read.
 	//
-	// If the deadline is greater than zero and no message is received before
-	// then, a net.Error with Timeout() == true is returned.
-	Receive(deadline time.Time) (*protocolMessage, error)
+	// If timeout is greater than zero and no message is received within it,
+	// a net.Error with Timeout() == true is returned. Conns measure it with
+	// their own timers; the library's are given the client's clock for that,
+	// see clockConn.
+	Receive(timeout time.Duration) (*protocolMessage, error)
 
 	// Close closes the connection.
 	Close() error
 }
+
+// clockConn is a conn that measures Receive's timeout with the client's
+// clock, which it's given right after being dialed, rather than with the
+// system's.
+type clockConn interface {
+	setClock(now func() time.Time, after ablyutil.TimerFunc)
+}
@@ -0,0 +1,272 @@
+package ably
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ably/ably-go/ably/internal/ablyutil"
+)
+
+// PublishRateLimit configures a client-side limit on the rate at which
+// messages are published, so that publishes are paced to stay within Ably's
+// account and channel limits instead of being rejected with 4291x errors.
+//
+// The limit is a token bucket: up to Burst messages can be published at once,
+// and the bucket refills at Rate messages per second.
+type PublishRateLimit struct {
+	// Rate is the sustained number of messages per second allowed.
+	Rate float64
+	// Burst is the number of messages that can be published at once, after
+	// a period without publishing. The default is Rate, rounded up.
+	Burst int
+	// FailFast makes publishes that would exceed the limit fail immediately
+	// with an [ably.ErrorInfo] with code ErrRateLimitExceeded, instead of
+	// waiting until the limit allows them or the context is done.
+	FailFast bool
+	// Adaptive makes the limit adapt to Ably's: when Ably rejects a publish
+	// because of rate limiting, the rate is halved, down to a tenth of Rate;
+	// then, each successful publish recovers a tenth of Rate.
+	Adaptive bool
+}
+
+// WithPublishRateLimit limits the rate at which messages are published by
+// the client: by RESTChannel.PublishMultiple for REST, and by the connection
+// for Realtime. Limits per channel can be set with
+// [ably.ChannelWithPublishRateLimit]; publishes must be allowed by both.
+func WithPublishRateLimit(limit PublishRateLimit) ClientOption {
+	return func(os *clientOptions) {
+		os.PublishRateLimit = &limit
+	}
+}
+
+// ChannelWithPublishRateLimit limits the rate at which messages are published
+// to the channel. See [ably.WithPublishRateLimit].
+func ChannelWithPublishRateLimit(limit PublishRateLimit) ChannelOption {
+	return func(o *channelOptions) {
+		o.PublishRateLimit = &limit
+	}
+}
+
+// rateLimiter implements PublishRateLimit. A nil *rateLimiter doesn't limit
+// anything.
+type rateLimiter struct {
+	limit PublishRateLimit
+	burst float64
+	now   func() time.Time
+	after func(context.Context, time.Duration) <-chan time.Time
+
+	mtx    sync.Mutex
+	rate   float64
+	tokens float64 // negative when publishes are waiting for future tokens
+	last   time.Time
+}
+
+// minRateFactor is the fraction of the configured rate below which an
+// adaptive limit isn't lowered.
+const minRateFactor = 0.1
+
+func newRateLimiter(limit *PublishRateLimit, opts *clientOptions) *rateLimiter {
+	if limit == nil || limit.Rate <= 0 {
+		return nil
+	}
+	l := &rateLimiter{
+		limit: *limit,
+		burst: float64(limit.Burst),
+		now:   opts.Now,
+		after: opts.After,
+		rate:  limit.Rate,
+	}
+	if l.burst <= 0 {
+		l.burst = math.Ceil(limit.Rate)
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+// refill adds the tokens accrued since the last call. It must be called with
+// mtx held.
+func (l *rateLimiter) refill() {
+	now := l.now()
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+}
+
+// reuse returns l if it implements limit, and a new limiter otherwise, so
+// that setting the same limit again doesn't reset it.
+func (l *rateLimiter) reuse(limit *PublishRateLimit, opts *clientOptions) *rateLimiter {
+	if l != nil && limit != nil && l.limit == *limit {
+		return l
+	}
+	return newRateLimiter(limit, opts)
+}
+
+// reserve takes n tokens from the bucket and returns how long to wait until
+// they're available, or fails if the limit fails fast and they aren't
+// available now. A publish of more messages than fit in the bucket is allowed
+// once the bucket is full.
+func (l *rateLimiter) reserve(n int) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill()
+	need := math.Min(float64(n), l.burst)
+	if l.limit.FailFast && l.tokens < need {
+		return 0, newErrorf(ErrRateLimitExceeded, "client-side publish rate limit of %g messages per second exceeded", l.rate)
+	}
+	// Reserve the tokens now, so that concurrent publishes queue up behind
+	// this one.
+	l.tokens -= float64(n)
+	return time.Duration((need - float64(n) - l.tokens) / l.rate * float64(time.Second)), nil
+}
+
+// refund gives back n tokens taken by reserve for a publish that won't
+// happen.
+func (l *rateLimiter) refund(n int) {
+	if l == nil {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.tokens += float64(n)
+}
+
+// observe adapts the rate to the outcome of a publish.
+func (l *rateLimiter) observe(err error) {
+	if l == nil || !l.limit.Adaptive {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill()
+	switch {
+	case err == nil:
+		l.rate = math.Min(l.rate+l.limit.Rate*minRateFactor, l.limit.Rate)
+	case isRateLimitError(err):
+		l.rate = math.Max(l.rate/2, l.limit.Rate*minRateFactor)
+	}
+}
+
+// isRateLimitError returns whether err is Ably rejecting a request because
+// of rate limiting.
+func isRateLimitError(err error) bool {
+	var e *ErrorInfo
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.Code >= ErrRateLimitExceeded && e.Code < ErrRateLimitExceededFatal+10
+}
+
+// publishLimiters are the limiters a publish must go through, outermost
+// first.
+type publishLimiters []*rateLimiter
+
+// reserve takes n tokens from every limiter, or from none of them if one
+// fails fast, and returns how long to wait until they're all available.
+func (ls publishLimiters) reserve(n int) (time.Duration, error) {
+	var delay time.Duration
+	for i, l := range ls {
+		d, err := l.reserve(n)
+		if err != nil {
+			ls[:i].refund(n)
+			return 0, err
+		}
+		if d > delay {
+			delay = d
+		}
+	}
+	return delay, nil
+}
+
+func (ls publishLimiters) refund(n int) {
+	for _, l := range ls {
+		l.refund(n)
+	}
+}
+
+// after waits for d with the clock of the limiters.
+func (ls publishLimiters) after(ctx context.Context, d time.Duration) <-chan time.Time {
+	for _, l := range ls {
+		if l != nil {
+			return l.after(ctx, d)
+		}
+	}
+	return ablyutil.After(ctx, d)
+}
+
+// wait reserves n tokens from every limiter and waits until they're
+// available. If ctx is done first, the tokens are given back.
+func (ls publishLimiters) wait(ctx context.Context, n int) error {
+	delay, err := ls.reserve(n)
+	if err != nil || delay <= 0 {
+		return err
+	}
+	if _, ok := <-ls.after(ctx, delay); !ok {
+		ls.refund(n)
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (ls publishLimiters) observe(err error) {
+	for _, l := range ls {
+		l.observe(err)
+	}
+}
+
+// publishQueue sends publishes that must wait for a rate limit in the
+// background, in the order they were made, so that asynchronous publishes
+// don't block their caller and synchronous ones don't overtake them.
+type publishQueue struct {
+	mtx sync.Mutex
+	// tail is closed once the last queued publish has been sent, or is nil
+	// if none has been queued.
+	tail chan struct{}
+}
+
+// send calls send right away if there's no delay and no publish is queued,
+// returning its error. Otherwise, it queues send to be called once delay has
+// passed and the publishes queued before have been sent; its error is then
+// passed to onErr.
+func (q *publishQueue) send(delay time.Duration, ls publishLimiters, send func() error, onErr func(error)) error {
+	q.mtx.Lock()
+	prev := q.tail
+	if delay <= 0 && (prev == nil || isClosed(prev)) {
+		q.tail = nil
+		q.mtx.Unlock()
+		return send()
+	}
+	done := make(chan struct{})
+	q.tail = done
+	q.mtx.Unlock()
+
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		if delay > 0 {
+			<-ls.after(context.Background(), delay)
+		}
+		if err := send(); err != nil {
+			onErr(err)
+		}
+	}()
+	return nil
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,281 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishRateLimit_REST(t *testing.T) {
+	ctx := context.Background()
+
+	// newClient returns a client whose publishes get the statuses in
+	// responses, in order, and then succeed.
+	newClient := func(t *testing.T, opts []ably.ClientOption, responses ...int) (*ably.REST, *fakeSleeper, *int32) {
+		var requests int32
+		clock := &fakeSleeper{now: time.Now()}
+		opts = append(opts, ably.WithNow(clock.Now), ably.WithAfter(clock.After))
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(atomic.AddInt32(&requests, 1))
+			if n <= len(responses) && responses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"code": ably.ErrRateLimitExceeded, "statusCode": 429, "message": "slow down"}})
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}), opts...)
+		return client, clock, &requests
+	}
+
+	t.Run("delays publishes over the limit", func(t *testing.T) {
+		client, clock, requests := newClient(t, []ably.ClientOption{
+			ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 10, Burst: 2}),
+		})
+		channel := client.Channels.Get("limited")
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, channel.Publish(ctx, "event", fmt.Sprint(i)))
+		}
+		assert.Equal(t, int32(3), *requests)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond}, clock.sleeps)
+
+		// A batch larger than the bucket waits for it to fill up.
+		err := channel.PublishMultiple(ctx, []*ably.Message{{Data: "1"}, {Data: "2"}, {Data: "3"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, clock.sleeps)
+	})
+
+	t.Run("fails fast", func(t *testing.T) {
+		client, clock, requests := newClient(t, []ably.ClientOption{
+			ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 1, FailFast: true}),
+		})
+		channel := client.Channels.Get("limited")
+		assert.NoError(t, channel.Publish(ctx, "event", "first"))
+		err := channel.Publish(ctx, "event", "second")
+		assert.Equal(t, ably.ErrRateLimitExceeded, ably.UnwrapErrorCode(err), err)
+		assert.Equal(t, int32(1), *requests)
+
+		<-clock.After(ctx, time.Second)
+		assert.NoError(t, channel.Publish(ctx, "event", "third"))
+	})
+
+	t.Run("limits per channel", func(t *testing.T) {
+		client, _, requests := newClient(t, nil)
+		limit := ably.ChannelWithPublishRateLimit(ably.PublishRateLimit{Rate: 1, FailFast: true})
+		limited := client.Channels.Get("limited", limit)
+		assert.NoError(t, limited.Publish(ctx, "event", "first"))
+
+		// Getting the channel again with the same limit doesn't reset it.
+		limited = client.Channels.Get("limited", limit)
+		assert.Error(t, limited.Publish(ctx, "event", "second"))
+
+		unlimited := client.Channels.Get("unlimited")
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, unlimited.Publish(ctx, "event", fmt.Sprint(i)))
+		}
+		assert.Equal(t, int32(4), *requests)
+	})
+
+	t.Run("gives back tokens when a later limit fails", func(t *testing.T) {
+		client, clock, requests := newClient(t, []ably.ClientOption{
+			ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 1, Burst: 2}),
+		})
+		limited := client.Channels.Get("limited", ably.ChannelWithPublishRateLimit(ably.PublishRateLimit{Rate: 1, FailFast: true}))
+		assert.NoError(t, limited.Publish(ctx, "event", "first"))
+		assert.Error(t, limited.Publish(ctx, "event", "second"))
+
+		// The failed publish didn't use up the client's limit.
+		assert.NoError(t, client.Channels.Get("other").Publish(ctx, "event", "third"))
+		assert.Empty(t, clock.sleeps)
+		assert.Equal(t, int32(2), *requests)
+	})
+
+	t.Run("waits respecting context", func(t *testing.T) {
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}), ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 0.001}))
+		channel := client.Channels.Get("limited")
+		assert.NoError(t, channel.Publish(ctx, "event", "first"))
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		err := channel.Publish(ctx, "event", "second")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("adapts to rate limit errors", func(t *testing.T) {
+		client, clock, _ := newClient(t, []ably.ClientOption{
+			ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 10, Burst: 1, Adaptive: true}),
+		}, http.StatusTooManyRequests)
+		channel := client.Channels.Get("limited")
+		assert.Error(t, channel.Publish(ctx, "event", "rejected"))
+		assert.NoError(t, channel.Publish(ctx, "event", "halved"))
+		assert.NoError(t, channel.Publish(ctx, "event", "recovering"))
+		assert.Equal(t, []time.Duration{200 * time.Millisecond, time.Second / 6}, clock.sleeps)
+	})
+}
+
+func TestPublishRateLimit_Realtime(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	clock := &fakeSleeper{now: time.Now()}
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 10, Burst: 2, Adaptive: true}),
+		ably.WithNow(clock.Now),
+		ably.WithAfter(clock.After),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{
+		Action:            ably.ActionConnected,
+		ConnectionID:      "connection-id",
+		ConnectionDetails: &ably.ConnectionDetails{},
+	}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	// Both publishes are rejected; the limit adapts to each, even without
+	// a callback.
+	channel := c.Channels.Get("limited")
+	assert.NoError(t, channel.PublishAsync("event", "unacked", nil))
+	acks := make(chan error, 1)
+	assert.NoError(t, channel.PublishAsync("event", "rejected", func(err error) {
+		acks <- err
+	}))
+	var published *ably.ProtocolMessage
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	ablytest.Instantly.Recv(t, nil, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{
+		Action:    ably.ActionNack,
+		MsgSerial: published.MsgSerial,
+		Count:     2,
+		Error: &ably.ProtoErrorInfo{
+			StatusCode: 429,
+			Code:       int(ably.ErrRateLimitExceeded),
+			Message:    "rate limit exceeded",
+		},
+	}
+	var ack error
+	ablytest.Instantly.Recv(t, &ack, acks, t.Fatalf)
+	assert.Equal(t, ably.ErrRateLimitExceeded, ably.UnwrapErrorCode(ack))
+
+	// The connection's limit has been halved twice, to 2.5 messages per
+	// second.
+	assert.NoError(t, channel.PublishAsync("event", "halved", nil))
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	assert.Equal(t, []time.Duration{400 * time.Millisecond}, clock.sleeps)
+}
+
+func TestPublishRateLimit_RealtimeAsyncDoesntBlock(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	release := make(chan time.Time)
+	waits := make(chan time.Duration, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 10, Burst: 1}),
+		ably.WithAfter(func(ctx context.Context, d time.Duration) <-chan time.Time {
+			waits <- d
+			return release
+		}),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{
+		Action:            ably.ActionConnected,
+		ConnectionID:      "connection-id",
+		ConnectionDetails: &ably.ConnectionDetails{},
+	}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	channel := c.Channels.Get("limited")
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, channel.PublishAsync("event", fmt.Sprint(i), nil))
+	}
+	var published *ably.ProtocolMessage
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	assert.Equal(t, "0", published.Messages[0].Data)
+	ablytest.Instantly.NoRecv(t, nil, out, t.Fatalf)
+
+	// The publishes over the limit are sent in order once it allows them.
+	for i := 1; i < 3; i++ {
+		var wait time.Duration
+		ablytest.Instantly.Recv(t, &wait, waits, t.Fatalf)
+		release <- time.Now()
+		ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+		assert.Equal(t, fmt.Sprint(i), published.Messages[0].Data)
+	}
+}
+
+func TestPublishRateLimit_RealtimeSyncQueuesBehindAsync(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	release := make(chan time.Time)
+	waits := make(chan time.Duration, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithPublishRateLimit(ably.PublishRateLimit{Rate: 10, Burst: 1}),
+		ably.WithAfter(func(ctx context.Context, d time.Duration) <-chan time.Time {
+			waits <- d
+			return release
+		}),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{
+		Action:            ably.ActionConnected,
+		ConnectionID:      "connection-id",
+		ConnectionDetails: &ably.ConnectionDetails{},
+	}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	channel := c.Channels.Get("limited")
+	assert.NoError(t, channel.PublishAsync("event", "0", nil))
+	assert.NoError(t, channel.PublishAsync("event", "1", nil))
+	var published *ably.ProtocolMessage
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	assert.Equal(t, "0", published.Messages[0].Data)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- channel.Publish(context.Background(), "event", "sync")
+	}()
+
+	// The synchronous publish waits for the limit behind the queued one.
+	var wait time.Duration
+	ablytest.Instantly.Recv(t, &wait, waits, t.Fatalf)
+	ablytest.Instantly.NoRecv(t, nil, waits, t.Fatalf)
+	release <- time.Now()
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	assert.Equal(t, "1", published.Messages[0].Data)
+	ablytest.Instantly.Recv(t, &wait, waits, t.Fatalf)
+	ablytest.Instantly.NoRecv(t, nil, out, t.Fatalf)
+	release <- time.Now()
+	ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+	assert.Equal(t, "sync", published.Messages[0].Data)
+
+	in <- &ably.ProtocolMessage{
+		Action:    ably.ActionAck,
+		MsgSerial: published.MsgSerial,
+		Count:     1,
+	}
+	ablytest.Instantly.Recv(t, &err, result, t.Fatalf)
+	assert.NoError(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/ably/ably-go/ably/internal/ablyutil"
 )
@@ -98,6 +99,55 @@ func ChannelWithCipher(params CipherParams) ChannelOption {
 	}
 }
 
+// ChannelWithCipherKeys enables encryption with key rotation. Messages are
+// encrypted with active, and its ID is added to their encoding. Received
+// messages, including history and presence, are decrypted with whichever of
+// active or previous keys their encoding names, so subscribers keep working
+// while publishers move to a new key.
+func ChannelWithCipherKeys(active CipherKey, previous ...CipherKey) ChannelOption {
+	return func(o *channelOptions) {
+		o.Cipher = Crypto.GetDefaultParams(CipherParams{
+			Key:          active.Key,
+			KeyID:        active.ID,
+			PreviousKeys: previous,
+		})
+	}
+}
+
+// ChannelWithCipherKeyProvider enables encryption with keys fetched from
+// provider instead of passed in directly. The provider is asked for the
+// active key when first attaching or publishing, and again every
+// [ably.DefaultCipherKeyRefreshInterval]. Keys for decrypting messages
+// encrypted with other keys are asked for by ID the first time they're seen.
+// Fetched keys are kept by the channel, so providers needn't cache them.
+//
+// Calls to the provider time out after the client's realtime request
+// timeout. If the provider fails while attaching or publishing, a realtime
+// channel moves to FAILED with the provider's error as its reason. Keys for
+// received messages are fetched without holding up the connection, while the
+// channel's later messages wait; if the provider fails, the message is
+// delivered still encrypted, and the key isn't asked for again for a few
+// seconds.
+//
+// The algorithm and mode are taken from [ably.ChannelWithCipher] if also
+// given; they default to AES and CBC.
+func ChannelWithCipherKeyProvider(provider CipherKeyProvider) ChannelOption {
+	return func(o *channelOptions) {
+		o.KeyProvider = provider
+		o.providerKeyring = &providerKeyring{}
+	}
+}
+
+// ChannelWithCipherKeyRefreshInterval sets how often the provider set with
+// [ably.ChannelWithCipherKeyProvider] is asked for the active key, which is
+// how a rotated key gets picked up. Until the provider answers, the previous
+// key is still used.
+func ChannelWithCipherKeyRefreshInterval(d time.Duration) ChannelOption {
+	return func(o *channelOptions) {
+		o.KeyRefreshInterval = d
+	}
+}
+
 // ChannelWithParams sets channel parameters that configure the behavior of the channel (TB2c).
 func ChannelWithParams(key string, value string) ChannelOption {
 	return func(o *channelOptions) {
@@ -224,6 +274,9 @@ type RealtimeChannel struct {
 	// Presence is a [ably.RealtimePresence] object, provides for entering and leaving client presence (RTL9).
 	Presence *RealtimePresence
 
+	// Push is a [ably.PushChannel] object, for subscribing devices to push notifications on the channel (RSH7).
+	Push *PushChannel
+
 	// state is the current [ably.ChannelState] of the channel (RTL2b).
 	state ChannelState
 
@@ -237,6 +290,13 @@ type RealtimeChannel struct {
 	errorEmitter   *eventEmitter
 	queue          *msgQueue
 	options        *channelOptions
+	publishLimiter *rateLimiter
+	publishQueue   publishQueue
+
+	// received holds, in order, the messages waiting for a key from the
+	// channel's key provider, and those received after them.
+	receivedMtx sync.Mutex
+	received    []*Message
 
 	// params are optional channel parameters that configure the behavior of the channel (RTL4k1).
 	params channelParams
@@ -252,6 +312,8 @@ type RealtimeChannel struct {
 }
 
 func newRealtimeChannel(name string, client *Realtime, chOptions *channelOptions) *RealtimeChannel {
+	chOptions.channel = name
+	chOptions.clientOpts = client.opts()
 	c := &RealtimeChannel{
 		ChannelEventEmitter: ChannelEventEmitter{newEventEmitter(client.log())},
 		Name:                name,
@@ -265,7 +327,9 @@ func newRealtimeChannel(name string, client *Realtime, chOptions *channelOptions
 		properties:     ChannelProperties{},
 	}
 	c.Presence = newRealtimePresence(c)
+	c.Push = newPushChannel(name, client.rest)
 	c.queue = newMsgQueue(client.Connection)
+	c.publishLimiter = newRateLimiter(chOptions.PublishRateLimit, client.opts())
 	return c
 }
 
@@ -289,7 +353,9 @@ func (c *RealtimeChannel) onConnStateChange(change ConnectionStateChange) {
 //
 // If the passed context is cancelled before the attach operation finishes, the call returns with an error,
 // but the operation carries on in the background and the channel may eventually be attached anyway.
-func (c *RealtimeChannel) Attach(ctx context.Context) error {
+func (c *RealtimeChannel) Attach(ctx context.Context) (err error) {
+	ctx, span := c.opts().tracer().start(ctx, SpanAttach, attr(attrChannel, c.Name))
+	defer func() { span.end(err) }()
 	res, err := c.attach()
 	if err != nil {
 		return err
@@ -316,6 +382,12 @@ func (c *RealtimeChannel) Attach(ctx context.Context) error {
 }
 
 func (c *RealtimeChannel) attach() (result, error) {
+	if c.protoOptions().KeyProvider != nil {
+		// Fail early rather than attaching to a channel we can't decrypt.
+		if _, err := c.cipher(); err != nil {
+			return nil, err
+		}
+	}
 	return c.mayAttach(true)
 }
 
@@ -399,7 +471,9 @@ func (c *RealtimeChannel) lockAttach(err error) (result, error) {
 // If the context is canceled before the detach operation finishes, the call
 // returns with an error, but the operation carries on in the background and
 // the channel may eventually be detached anyway.
-func (c *RealtimeChannel) Detach(ctx context.Context) error {
+func (c *RealtimeChannel) Detach(ctx context.Context) (err error) {
+	ctx, span := c.opts().tracer().start(ctx, SpanDetach, attr(attrChannel, c.Name))
+	defer func() { span.end(err) }()
 	prevChannelState := c.State()
 	res, err := c.detach()
 	if err != nil {
@@ -630,6 +704,12 @@ func (c *RealtimeChannel) PublishAsync(name string, data interface{}, onAck func
 
 // PublishMultiple publishes all given messages on the channel at once.
 //
+// If a publish rate limit is set, with [ably.WithPublishRateLimit] or
+// [ably.ChannelWithPublishRateLimit], PublishMultiple first waits until the
+// limit allows the messages to be sent, or fails if the limit fails fast.
+// They're sent after any publishes on the channel still waiting for the
+// limit, including those made with PublishMultipleAsync.
+//
 // If the context is cancelled before the attach operation finishes, the call
 // returns an error but the publish will carry on in the background and may
 // eventually be published anyway.
@@ -638,7 +718,7 @@ func (c *RealtimeChannel) PublishMultiple(ctx context.Context, messages []*Messa
 	onAck := func(err error) {
 		listen <- err
 	}
-	if err := c.PublishMultipleAsync(messages, onAck); err != nil {
+	if err := c.publishMultiple(ctx, messages, onAck); err != nil {
 		return err
 	}
 
@@ -652,7 +732,17 @@ func (c *RealtimeChannel) PublishMultiple(ctx context.Context, messages []*Messa
 
 // PublishMultipleAsync is the same as PublishMultiple except it calls onAck instead of blocking
 // (see PublishAsync).
+//
+// If a publish rate limit is set, with [ably.WithPublishRateLimit] or
+// [ably.ChannelWithPublishRateLimit], PublishMultipleAsync doesn't wait for
+// the limit to allow the messages to be sent: they're queued and sent in the
+// background, in order, once it does. An error sending them is then passed
+// to onAck.
 func (c *RealtimeChannel) PublishMultipleAsync(messages []*Message, onAck func(err error)) error {
+	return c.publishMultiple(context.Background(), messages, onAck)
+}
+
+func (c *RealtimeChannel) publishMultiple(ctx context.Context, messages []*Message, onAck func(err error)) error {
 	id := c.client.Auth.clientIDForCheck()
 	for _, v := range messages {
 		if v.ClientID != "" && id != wildcardClientID && v.ClientID != id {
@@ -660,12 +750,63 @@ func (c *RealtimeChannel) PublishMultipleAsync(messages []*Message, onAck func(e
 			return fmt.Errorf("Unable to publish message containing a clientId (%s) that is incompatible with the library clientId (%s)", v.ClientID, id)
 		}
 	}
+	if c.protoOptions().hasCipher() {
+		cipher, err := c.cipher()
+		if err != nil {
+			return err
+		}
+		encoded := make([]*Message, len(messages))
+		for i, v := range messages {
+			m, err := v.withEncodedData(cipher)
+			if err != nil {
+				return fmt.Errorf("encoding data for message #%d: %w", i, err)
+			}
+			encoded[i] = &m
+		}
+		messages = encoded
+	}
+	tracer := c.opts().tracer()
+	ctx, span := tracer.start(ctx, SpanPublish,
+		attr(attrChannel, c.Name),
+		attr(attrMessageCount, len(messages)),
+	)
+	messages = tracer.injectMessages(ctx, messages)
+
+	limiters := publishLimiters{c.client.Connection.publishLimiter, c.publishLimiter}
+	delay, err := limiters.reserve(len(messages))
+	if err != nil {
+		span.end(err)
+		return err
+	}
 	msg := &protocolMessage{
 		Action:   actionMessage,
 		Channel:  c.Name,
 		Messages: messages,
 	}
-	return c.send(msg, onAck)
+	send := func() error {
+		// A PublishMultiple call that has given up while queued isn't sent.
+		if err := ctx.Err(); err != nil {
+			limiters.refund(len(messages))
+			span.end(err)
+			return err
+		}
+		err := c.send(msg, func(err error) {
+			limiters.observe(err)
+			span.end(err)
+			if onAck != nil {
+				onAck(err)
+			}
+		})
+		if err != nil {
+			span.end(err)
+		}
+		return err
+	}
+	return c.publishQueue.send(delay, limiters, send, func(err error) {
+		if onAck != nil {
+			onAck(err)
+		}
+	})
 }
 
 // History retrieves a [ably.HistoryRequest] object, containing an array of historical
@@ -675,7 +816,7 @@ func (c *RealtimeChannel) PublishMultipleAsync(messages []*Message, onAck func(e
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
 func (c *RealtimeChannel) History(o ...HistoryOption) HistoryRequest {
-	return c.client.rest.Channels.Get(c.Name).History(o...)
+	return c.client.rest.Channels.get(c.Name, c.protoOptions()).History(o...)
 }
 
 // HistoryUntilAttach retrieves a [ably.HistoryRequest] object, containing an array of historical
@@ -701,7 +842,7 @@ func (c *RealtimeChannel) HistoryUntilAttach(o ...HistoryOption) (*HistoryReques
 	}
 	o = append(o, untilAttachParam)
 
-	historyRequest := c.client.rest.Channels.Get(c.Name).History(o...)
+	historyRequest := c.client.rest.Channels.get(c.Name, c.protoOptions()).History(o...)
 	return &historyRequest, nil
 }
 
@@ -857,14 +998,81 @@ func (c *RealtimeChannel) notify(msg *protocolMessage) {
 		c.queue.Fail(newErrorFromProto(msg.Error))
 	case actionMessage:
 		if c.State() == ChannelStateAttached {
+			c.opts().metrics().MessagesReceived(c.Name, len(msg.Messages))
 			for _, msg := range msg.Messages {
-				c.messageEmitter.Emit(subscriptionName(msg.Name), (*subscriptionMessage)(msg))
+				c.receiveMessage(msg)
 			}
 		}
 	default:
 	}
 }
 
+// receiveMessage decodes and delivers a message received on the channel.
+// If decoding it needs a key from the channel's key provider, the message,
+// and those received after it, are queued while the key is fetched in the
+// background, so that a slow provider doesn't hold up the connection.
+func (c *RealtimeChannel) receiveMessage(m *Message) {
+	c.receivedMtx.Lock()
+	if len(c.received) == 0 && !c.protoOptions().keyFetchNeeded(m.Encoding) {
+		c.receivedMtx.Unlock()
+		c.deliverMessage(m)
+		return
+	}
+	c.received = append(c.received, m)
+	if len(c.received) == 1 {
+		go c.deliverReceived()
+	}
+	c.receivedMtx.Unlock()
+}
+
+// deliverReceived delivers the queued messages until there are none left.
+func (c *RealtimeChannel) deliverReceived() {
+	c.receivedMtx.Lock()
+	defer c.receivedMtx.Unlock()
+	for len(c.received) > 0 {
+		m := c.received[0]
+		c.receivedMtx.Unlock()
+		c.deliverMessage(m)
+		c.receivedMtx.Lock()
+		c.received = c.received[1:]
+	}
+}
+
+func (c *RealtimeChannel) deliverMessage(m *Message) {
+	c.decodeMessage(m)
+	c.messageEmitter.Emit(subscriptionName(m.Name), (*subscriptionMessage)(m))
+}
+
+// decodeMessage decodes the data of a message received on an encrypted
+// channel. On failure, including when the key provider can't supply the
+// key, the message is delivered with the encodings that couldn't be applied
+// left in Message.Encoding (RSL6b, RTL7e).
+func (c *RealtimeChannel) decodeMessage(m *Message) {
+	if !c.protoOptions().hasCipher() {
+		return
+	}
+	cipher, err := c.protoOptions().GetCipher()
+	if err == nil {
+		var decoded Message
+		decoded, err = m.withDecodedData(cipher)
+		*m = decoded
+	}
+	if err != nil {
+		c.log().Errorf("Couldn't decode message %q on channel %q: %v", m.ID, c.Name, err)
+	}
+}
+
+// cipher returns the channel's cipher. If its key provider fails, the
+// channel moves to FAILED with the provider's error as the reason.
+func (c *RealtimeChannel) cipher() (channelCipher, error) {
+	cipher, err := c.protoOptions().GetCipher()
+	var keyErr *cipherKeyProviderError
+	if errors.As(err, &keyErr) {
+		c.setState(ChannelStateFailed, err, false)
+	}
+	return cipher, err
+}
+
 func (c *RealtimeChannel) lockStartRetryAttachLoop(err error) {
 	// TODO: Move to SUSPENDED; move it to DETACHED for now.
 	c.lockSetState(ChannelStateDetached, err, false)
@@ -914,6 +1122,10 @@ func (c *RealtimeChannel) channelOpts() *channelOptions {
 	return c.options
 }
 
+func (c *RealtimeChannel) protoOptions() *protoChannelOptions {
+	return (*protoChannelOptions)(c.options)
+}
+
 func (c *RealtimeChannel) setParams(params channelParams) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
@@ -961,7 +1173,11 @@ func (c *RealtimeChannel) opts() *clientOptions {
 }
 
 func (c *RealtimeChannel) log() logger {
-	return c.client.log()
+	var attrs []LogAttr
+	if c.client.Connection != nil {
+		attrs = c.client.Connection.logAttrs()
+	}
+	return c.client.log().with(append(attrs, LogAttr{Key: LogKeyChannel, Value: c.Name})...)
 }
 
 func (c *RealtimeChannel) setState(state ChannelState, err error, resumed bool) error {
@@ -1025,6 +1241,10 @@ func (c *RealtimeChannel) lockSetState(state ChannelState, err error, resumed bo
 	} else {
 		change.Event = ChannelEvent(change.Current)
 	}
+	if changed {
+		c.log().with(LogAttr{Key: LogKeyState, Value: state.String()}).Verbosef("channel state changed from %v to %v", previous, state)
+	}
+	c.opts().metrics().ChannelStateChanged(c.Name, change)
 	c.internalEmitter.emitter.Emit(change.Event, change)
 	c.emitter.Emit(change.Event, change)
 	return c.errorReason.unwrapNil()
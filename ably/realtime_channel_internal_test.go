@@ -162,3 +162,19 @@ func TestChannelGet(t *testing.T) {
 		})
 	}
 }
+
+func TestChannelOptionChannelWithCipherKeys(t *testing.T) {
+	active := CipherKey{ID: "v2", Key: make([]byte, 32)}
+	previous := CipherKey{ID: "v1", Key: make([]byte, 16)}
+	result := applyChannelOptions(ChannelWithCipherKeys(active, previous))
+	assert.Equal(t, &channelOptions{
+		Cipher: CipherParams{
+			Algorithm:    CipherAES,
+			KeyLength:    256,
+			Key:          active.Key,
+			Mode:         CipherCBC,
+			KeyID:        "v2",
+			PreviousKeys: []CipherKey{previous},
+		},
+	}, result)
+}
@@ -5,10 +5,16 @@ package ably_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"testing"
 	"time"
 
 	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // When publishing a message to a channel, data can be either a single string or
@@ -48,3 +54,177 @@ func ExampleRealtimeChannel_Publish() {
 		return
 	}
 }
+
+func TestRealtimeChannel_CipherKeyProvider(t *testing.T) {
+	keys := map[string][]byte{
+		"v1": make([]byte, 16),
+		"v2": make([]byte, 32),
+	}
+	keys["v1"][0] = 1
+	keys["v2"][0] = 2
+	var fail error
+	provider := ably.CipherKeyProviderFunc(func(ctx context.Context, channel, keyID string) (ably.CipherKey, error) {
+		assert.Equal(t, "secret", channel)
+		if fail != nil {
+			return ably.CipherKey{}, fail
+		}
+		if keyID == "" {
+			keyID = "v2"
+		}
+		return ably.CipherKey{ID: keyID, Key: keys[keyID]}, nil
+	})
+
+	setup := func(t *testing.T) (in, out chan *ably.ProtocolMessage, channel *ably.RealtimeChannel) {
+		in = make(chan *ably.ProtocolMessage, 1)
+		out = make(chan *ably.ProtocolMessage, 16)
+		c, err := ably.NewRealtime(
+			ably.WithToken("fake:token"),
+			ably.WithAutoConnect(false),
+			ably.WithDial(MessagePipe(in, out)),
+		)
+		assert.NoError(t, err)
+		in <- &ably.ProtocolMessage{
+			Action:            ably.ActionConnected,
+			ConnectionID:      "connection-id",
+			ConnectionDetails: &ably.ConnectionDetails{},
+		}
+		err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+		assert.NoError(t, err)
+		return in, out, c.Channels.Get("secret", ably.ChannelWithCipherKeyProvider(provider))
+	}
+
+	t.Run("encrypts with the active key and decrypts with any key", func(t *testing.T) {
+		fail = nil
+		in, out, channel := setup(t)
+
+		in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+		received := make(chan *ably.Message, 1)
+		_, err := channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+			received <- m
+		})
+		assert.NoError(t, err)
+		var attach *ably.ProtocolMessage
+		ablytest.Instantly.Recv(t, &attach, out, t.Fatalf)
+
+		err = channel.PublishAsync("greeting", "hello", nil)
+		assert.NoError(t, err)
+		var published *ably.ProtocolMessage
+		ablytest.Instantly.Recv(t, &published, out, t.Fatalf)
+		assert.Equal(t, "utf-8/cipher+aes-256-cbc:v2/base64", published.Messages[0].Encoding)
+
+		old, err := (&ably.ProtoChannelOptions{
+			Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: keys["v1"], KeyID: "v1"}),
+		}).GetCipher()
+		assert.NoError(t, err)
+		encrypted, err := ably.MessageWithEncodedData(ably.Message{Name: "greeting", Data: "before rotation"}, old)
+		assert.NoError(t, err)
+		in <- &ably.ProtocolMessage{
+			Action:   ably.ActionMessage,
+			Channel:  channel.Name,
+			Messages: []*ably.Message{&encrypted},
+		}
+		var m *ably.Message
+		ablytest.Instantly.Recv(t, &m, received, t.Fatalf)
+		assert.Equal(t, "before rotation", m.Data)
+		assert.Empty(t, m.Encoding)
+	})
+
+	t.Run("provider errors fail the channel", func(t *testing.T) {
+		fail = errors.New("vault is sealed")
+		_, _, channel := setup(t)
+
+		err := channel.Attach(context.Background())
+		assert.ErrorContains(t, err, "vault is sealed")
+		assert.Equal(t, ably.ChannelStateFailed, channel.State())
+		assert.Equal(t, ably.ErrChannelOperationFailed, channel.ErrorReason().Code)
+		assert.Contains(t, channel.ErrorReason().Error(), `couldn't get the active cipher key for channel "secret"`)
+	})
+}
+
+func TestRealtimeChannel_CipherKeyProviderDoesntBlockConnection(t *testing.T) {
+	keys := map[string][]byte{
+		"v2": make([]byte, 32),
+		"v3": make([]byte, 16),
+		"v9": make([]byte, 16),
+	}
+	release := make(chan struct{})
+	var mtx sync.Mutex
+	calls := map[string]int{}
+	provider := ably.CipherKeyProviderFunc(func(ctx context.Context, channel, keyID string) (ably.CipherKey, error) {
+		mtx.Lock()
+		calls[keyID]++
+		mtx.Unlock()
+		switch keyID {
+		case "":
+			keyID = "v2"
+		case "v3":
+			<-release
+		case "v9":
+			return ably.CipherKey{}, errors.New("no such key")
+		}
+		return ably.CipherKey{ID: keyID, Key: keys[keyID]}, nil
+	})
+	encrypt := func(keyID, data string) *ably.Message {
+		cipher, err := (&ably.ProtoChannelOptions{
+			Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: keys[keyID], KeyID: keyID}),
+		}).GetCipher()
+		assert.NoError(t, err)
+		m, err := ably.MessageWithEncodedData(ably.Message{Data: data}, cipher)
+		assert.NoError(t, err)
+		return &m
+	}
+
+	in := make(chan *ably.ProtocolMessage, 16)
+	out := make(chan *ably.ProtocolMessage, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	subscribe := func(channel *ably.RealtimeChannel) <-chan *ably.Message {
+		in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+		received := make(chan *ably.Message, 4)
+		_, err := channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+			received <- m
+		})
+		assert.NoError(t, err)
+		return received
+	}
+	secret := c.Channels.Get("secret", ably.ChannelWithCipherKeyProvider(provider))
+	secretReceived := subscribe(secret)
+	plain := c.Channels.Get("plain")
+	plainReceived := subscribe(plain)
+
+	// While the provider is asked for a key, the messages received after the
+	// one that needs it wait, but other channels' don't.
+	in <- &ably.ProtocolMessage{Action: ably.ActionMessage, Channel: secret.Name, Messages: []*ably.Message{encrypt("v3", "first")}}
+	in <- &ably.ProtocolMessage{Action: ably.ActionMessage, Channel: secret.Name, Messages: []*ably.Message{encrypt("v2", "second")}}
+	in <- &ably.ProtocolMessage{Action: ably.ActionMessage, Channel: plain.Name, Messages: []*ably.Message{{Data: "plain"}}}
+	var m *ably.Message
+	ablytest.Soon.Recv(t, &m, plainReceived, t.Fatalf)
+	assert.Equal(t, "plain", m.Data)
+	ablytest.Instantly.NoRecv(t, nil, secretReceived, t.Fatalf)
+	close(release)
+	ablytest.Soon.Recv(t, &m, secretReceived, t.Fatalf)
+	assert.Equal(t, "first", m.Data)
+	ablytest.Soon.Recv(t, &m, secretReceived, t.Fatalf)
+	assert.Equal(t, "second", m.Data)
+
+	// A key the provider can't supply leaves the messages that need it
+	// encrypted, without failing the channel, and isn't asked for again
+	// right away.
+	for i := 0; i < 2; i++ {
+		in <- &ably.ProtocolMessage{Action: ably.ActionMessage, Channel: secret.Name, Messages: []*ably.Message{encrypt("v9", "lost")}}
+		ablytest.Soon.Recv(t, &m, secretReceived, t.Fatalf)
+		assert.Contains(t, m.Encoding, "cipher+aes-128-cbc:v9")
+	}
+	assert.Equal(t, ably.ChannelStateAttached, secret.State())
+	mtx.Lock()
+	defer mtx.Unlock()
+	assert.Equal(t, 1, calls["v9"])
+}
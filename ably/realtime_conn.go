@@ -83,6 +83,19 @@ type Connection struct {
 	readLimit                int64
 	isReadLimitSetExternally bool
 	recover                  string
+
+	// publishLimiter limits the rate at which messages are published on the
+	// connection. See WithPublishRateLimit.
+	publishLimiter *rateLimiter
+
+	// logID is the connection ID attached to log records. It has its own
+	// mutex, as the connection logs both with and without mtx held.
+	logMtx sync.Mutex
+	logID  string
+
+	// recorder records the connection's protocol session, if set with
+	// WithSessionRecorder.
+	recorder *sessionRecorder
 }
 
 type connCallbacks struct {
@@ -104,12 +117,15 @@ func newConn(opts *clientOptions, auth *Auth, callbacks connCallbacks, client *R
 		internalEmitter:        ConnectionEventEmitter{newEventEmitter(auth.log())},
 
 		opts:      opts,
-		pending:   newPendingEmitter(auth.log()),
+		pending:   newPendingEmitter(auth.log(), opts),
 		auth:      auth,
 		callbacks: callbacks,
 		client:    client,
 		readLimit: maxMessageSize,
 		recover:   opts.Recover,
+
+		publishLimiter: newRateLimiter(opts.PublishRateLimit, opts),
+		recorder:       newSessionRecorder(opts),
 	}
 	auth.onExplicitAuthorize = c.onClientAuthorize
 	c.queue = newMsgQueue(c)
@@ -127,7 +143,8 @@ func newConn(opts *clientOptions, auth *Auth, callbacks connCallbacks, client *R
 
 func (c *Connection) dial(proto string, u *url.URL) (conn conn, err error) {
 	start := time.Now()
-	c.log().Debugf("Dial protocol=%q url %q ", proto, u.String())
+	log := c.log().with(LogAttr{Key: LogKeyHost, Value: u.Host})
+	log.Debugf("Dial protocol=%q url %q ", proto, u.String())
 	// (RTN23b)
 	query := u.Query()
 	query.Add("heartbeats", "true")
@@ -136,13 +153,24 @@ func (c *Connection) dial(proto string, u *url.URL) (conn conn, err error) {
 	if c.opts.Dial != nil {
 		conn, err = c.opts.Dial(proto, u, timeout)
 	} else {
-		conn, err = dialWebsocket(proto, u, timeout, c.opts.Agents)
+		var ws *websocketConn
+		ws, err = dialWebsocket(proto, u, timeout, c.opts.Agents)
+		if err == nil {
+			ws.metrics = c.opts.metrics()
+			conn = ws
+		}
+	}
+	if cc, ok := conn.(clockConn); ok && err == nil {
+		cc.setClock(c.opts.Now, c.opts.After)
+	}
+	if c.recorder != nil {
+		conn = c.recorder.dial(u, conn, err)
 	}
 	if err != nil {
-		c.log().Debugf("Dial Failed in %v with %v", time.Since(start), err)
+		log.Debugf("Dial Failed in %v with %v", time.Since(start), err)
 		return nil, err
 	}
-	c.log().Debugf("Dial success in %v", time.Since(start))
+	log.Debugf("Dial success in %v", time.Since(start))
 	return conn, err
 }
 
@@ -406,7 +434,7 @@ func (c *Connection) connectWith(arg connArgs) (result, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	c.setConn(verboseConn{conn: conn, logger: c.log()})
+	c.setConn(verboseConn{conn: conn, logger: c.log(), redactData: c.opts.LogRedactMessageData})
 	// Start eventloop
 	go c.eventloop()
 
@@ -634,7 +662,7 @@ func (c *Connection) send(msg *protocolMessage, onAck func(err error)) {
 			// reconnection logic. But in case it isn't, force that by closing the
 			// connection. Otherwise, the message we enqueue here may be in the queue
 			// indefinitely.
-			c.log().Warnf("transport level failure while sending message, %v", err)
+			c.log().with(LogAttr{Key: LogKeyMsgSerial, Value: msg.MsgSerial}).Warnf("transport level failure while sending message, %v", err)
 			c.conn.Close()
 			c.mtx.Unlock()
 			c.queue.Enqueue(msg, onAck)
@@ -642,6 +670,9 @@ func (c *Connection) send(msg *protocolMessage, onAck func(err error)) {
 			if hasMsgSerial {
 				c.advanceSerial()
 			}
+			if msg.Action == actionMessage {
+				c.opts.metrics().MessagesSent(msg.Channel, len(msg.Messages))
+			}
 			if onAck != nil {
 				c.pending.Enqueue(msg, onAck)
 			}
@@ -709,7 +740,23 @@ func (c *Connection) setConn(conn conn) {
 }
 
 func (c *Connection) log() logger {
-	return c.auth.log()
+	return c.auth.log().with(c.logAttrs()...)
+}
+
+// logAttrs returns the attributes attached to the connection's log records.
+func (c *Connection) logAttrs() []LogAttr {
+	c.logMtx.Lock()
+	defer c.logMtx.Unlock()
+	if c.logID == "" {
+		return nil
+	}
+	return []LogAttr{{Key: LogKeyConnectionID, Value: c.logID}}
+}
+
+func (c *Connection) setLogID(id string) {
+	c.logMtx.Lock()
+	c.logID = id
+	c.logMtx.Unlock()
 }
 
 func (c *Connection) resendAcks() {
@@ -741,7 +788,7 @@ func (c *Connection) eventloop() {
 			receiveTimeout += maxIdleInterval // RTN23a
 		}
 		c.connMtx.Lock()
-		msg, err := c.conn.Receive(c.opts.Now().Add(receiveTimeout))
+		msg, err := c.conn.Receive(receiveTimeout)
 		c.connMtx.Unlock()
 		if err != nil {
 			c.mtx.Lock()
@@ -840,6 +887,7 @@ func (c *Connection) eventloop() {
 
 			isNewID := c.id != msg.ConnectionID
 			c.id = msg.ConnectionID
+			c.setLogID(c.id)
 
 			failedResumeOrRecover := isNewID && msg.Error != nil // RTN15c7, RTN16d
 
@@ -959,24 +1007,33 @@ func (c *Connection) lockedReauthorizationFailed(err error) {
 }
 
 type verboseConn struct {
-	conn   conn
-	logger logger
+	conn       conn
+	logger     logger
+	redactData bool
 }
 
 func (vc verboseConn) Send(msg *protocolMessage) error {
-	vc.logger.Verbosef("Realtime Connection: sending %s", msg)
+	vc.logger.Verbosef("Realtime Connection: sending %s", vc.loggable(msg))
 	return vc.conn.Send(msg)
 }
 
-func (vc verboseConn) Receive(deadline time.Time) (*protocolMessage, error) {
-	msg, err := vc.conn.Receive(deadline)
+func (vc verboseConn) Receive(timeout time.Duration) (*protocolMessage, error) {
+	msg, err := vc.conn.Receive(timeout)
 	if err != nil {
 		return nil, err
 	}
-	vc.logger.Verbosef("Realtime Connection: received %s", msg)
+	vc.logger.Verbosef("Realtime Connection: received %s", vc.loggable(msg))
 	return msg, nil
 }
 
+// loggable returns msg as it should be logged.
+func (vc verboseConn) loggable(msg *protocolMessage) *protocolMessage {
+	if vc.redactData && vc.logger.enabled(LogVerbose) {
+		return redactMessageData(msg)
+	}
+	return msg
+}
+
 func (vc verboseConn) Close() error {
 	vc.logger.Verbosef("Realtime Connection: closed")
 	return vc.conn.Close()
@@ -996,6 +1053,7 @@ func (c *Connection) lockSetState(state ConnectionState, err error, retryIn time
 	if state == ConnectionStateClosing || state == ConnectionStateClosed ||
 		state == ConnectionStateSuspended || state == ConnectionStateFailed {
 		c.key, c.id = "", "" //(RTN8c, RTN9c)
+		c.setLogID("")
 	}
 
 	previous := c.state
@@ -1013,6 +1071,10 @@ func (c *Connection) lockSetState(state ConnectionState, err error, retryIn time
 	} else {
 		change.Event = ConnectionEvent(change.Current)
 	}
+	if changed {
+		c.log().with(LogAttr{Key: LogKeyState, Value: state.String()}).Verbosef("connection state changed from %v to %v", previous, state)
+	}
+	c.opts.metrics().ConnectionStateChanged(change)
 	c.internalEmitter.emitter.Emit(change.Event, change)
 	c.emitter.Emit(change.Event, change)
 	return c.errorReason.unwrapNil()
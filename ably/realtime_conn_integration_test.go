@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ably/internal/ablyutil"
 	"github.com/ably/ably-go/ablytest"
 
 	"github.com/stretchr/testify/assert"
@@ -117,7 +118,7 @@ func TestRealtimeConn_ReceiveTimeout(t *testing.T) {
 	in <- connected
 
 	app, client := ablytest.NewRealtime(
-		ably.WithDial(MessagePipe(in, out, MessagePipeWithNowFunc(time.Now))),
+		ably.WithDial(MessagePipe(in, out, MessagePipeWithAfterFunc(ablyutil.After))),
 		ably.WithRealtimeRequestTimeout(10*time.Millisecond),
 		ably.WithAutoConnect(false),
 	)
@@ -275,12 +276,12 @@ func TestRealtimeConn_ReconnectFromSuspendedState(t *testing.T) {
 		}
 		return connMock{
 			SendFunc: ws.Send,
-			ReceiveFunc: func(deadline time.Time) (*ably.ProtocolMessage, error) {
+			ReceiveFunc: func(timeout time.Duration) (*ably.ProtocolMessage, error) {
 				err := <-msgReceiveErr
 				if err != nil {
 					return nil, err
 				}
-				msg, err := ws.Receive(deadline)
+				msg, err := ws.Receive(timeout)
 				if msg.Action == ably.ActionConnected {
 					msg.ConnectionDetails.ConnectionStateTTL = ably.DurationFromMsecs(500 * time.Millisecond)
 				}
@@ -401,7 +401,6 @@ func TestRealtimeConn_RTN12_Connection_Close(t *testing.T) {
 					ConnectionDetails: &connDetails,
 				}
 				return MessagePipe(in, out,
-					MessagePipeWithNowFunc(now),
 					MessagePipeWithAfterFunc(after),
 				)(p, u, timeout)
 			}))
@@ -508,7 +507,6 @@ func TestRealtimeConn_RTN12_Connection_Close(t *testing.T) {
 					ConnectionDetails: &connDetails,
 				}
 				return MessagePipe(in, out,
-					MessagePipeWithNowFunc(now),
 					MessagePipeWithAfterFunc(after),
 				)(p, u, timeout)
 			}))
@@ -598,7 +596,6 @@ func TestRealtimeConn_RTN12_Connection_Close(t *testing.T) {
 					ConnectionDetails: &connDetails,
 				}
 				return MessagePipe(in, out,
-					MessagePipeWithNowFunc(now),
 					MessagePipeWithAfterFunc(after),
 				)(p, u, timeout)
 			}))
@@ -790,7 +787,7 @@ type protoConnWithFakeEOF struct {
 	onMessage func(msg *ably.ProtocolMessage)
 }
 
-func (c protoConnWithFakeEOF) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
+func (c protoConnWithFakeEOF) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
 	type result struct {
 		msg *ably.ProtocolMessage
 		err error
@@ -799,7 +796,7 @@ func (c protoConnWithFakeEOF) Receive(deadline time.Time) (*ably.ProtocolMessage
 	received := make(chan result, 1)
 
 	go func() {
-		msg, err := c.Conn.Receive(deadline)
+		msg, err := c.Conn.Receive(timeout)
 		received <- result{msg: msg, err: err}
 	}()
 
@@ -1831,7 +1828,6 @@ func TestRealtimeConn_RTN23(t *testing.T) {
 			}
 			dials <- u
 			return MessagePipe(in, nil,
-				MessagePipeWithNowFunc(now),
 				MessagePipeWithAfterFunc(after),
 			)(p, u, timeout)
 		}))
@@ -1904,7 +1900,6 @@ func TestRealtimeConn_RTN14c_ConnectedTimeout(t *testing.T) {
 		ably.WithNow(now),
 		ably.WithAfter(after),
 		ably.WithDial(MessagePipe(in, out,
-			MessagePipeWithNowFunc(now),
 			MessagePipeWithAfterFunc(after),
 		)),
 	)
@@ -2063,7 +2058,7 @@ func (noopConn) Send(*ably.ProtocolMessage) error {
 	return nil
 }
 
-func (n *noopConn) Receive(deadline time.Time) (*ably.ProtocolMessage, error) {
+func (n *noopConn) Receive(timeout time.Duration) (*ably.ProtocolMessage, error) {
 	n.ch <- struct{}{}
 	return &ably.ProtocolMessage{}, nil
 }
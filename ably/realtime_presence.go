@@ -289,6 +289,9 @@ func (pres *RealtimePresence) processProtoSyncMessage(msg *protocolMessage) {
 }
 
 func (pres *RealtimePresence) processProtoPresenceMessage(msg *protocolMessage) {
+	for _, presenceMember := range msg.Presence {
+		pres.channel.decodeMessage(&presenceMember.Message)
+	}
 	pres.mtx.Lock()
 	// RTP17 - Update internal presence map
 	for _, presenceMember := range msg.Presence {
@@ -380,7 +383,9 @@ func (o *presenceGetOptions) applyWithDefaults(options ...PresenceGetOption) {
 // If the context is cancelled before the operation finishes, the call
 // returns with an error, but the operation carries on in the background and
 // the channel may eventually be attached anyway (RTP11).
-func (pres *RealtimePresence) GetWithOptions(ctx context.Context, options ...PresenceGetOption) ([]*PresenceMessage, error) {
+func (pres *RealtimePresence) GetWithOptions(ctx context.Context, options ...PresenceGetOption) (_ []*PresenceMessage, err error) {
+	ctx, span := pres.channel.opts().tracer().start(ctx, SpanPresenceGet, attr(attrChannel, pres.channel.Name))
+	defer func() { span.end(err) }()
 	var opts presenceGetOptions
 	opts.applyWithDefaults(options...)
 
@@ -526,7 +531,9 @@ func (pres *RealtimePresence) Leave(ctx context.Context, data interface{}) error
 //
 // If the context is cancelled before the operation finishes, the call returns with an error,
 // but the operation carries on in the background and presence state may eventually be updated anyway.
-func (pres *RealtimePresence) EnterClient(ctx context.Context, clientID string, data interface{}) error {
+func (pres *RealtimePresence) EnterClient(ctx context.Context, clientID string, data interface{}) (err error) {
+	ctx, span := pres.startSpan(ctx, PresenceActionEnter, clientID)
+	defer func() { span.end(err) }()
 	pres.mtx.Lock()
 	pres.data = data
 	pres.state = PresenceActionEnter
@@ -576,7 +583,9 @@ func nonnil(a, b interface{}) interface{} {
 //
 // If the context is cancelled before the operation finishes, the call returns with an error,
 // but the operation carries on in the background and presence data may eventually be updated anyway.
-func (pres *RealtimePresence) UpdateClient(ctx context.Context, clientID string, data interface{}) error {
+func (pres *RealtimePresence) UpdateClient(ctx context.Context, clientID string, data interface{}) (err error) {
+	ctx, span := pres.startSpan(ctx, PresenceActionUpdate, clientID)
+	defer func() { span.end(err) }()
 	pres.mtx.Lock()
 	if pres.state != PresenceActionEnter {
 		oldData := pres.data
@@ -604,7 +613,9 @@ func (pres *RealtimePresence) UpdateClient(ctx context.Context, clientID string,
 //
 // If the context is cancelled before the operation finishes, the call returns with an error,
 // but the operation carries on in the background and presence data may eventually be updated anyway.
-func (pres *RealtimePresence) LeaveClient(ctx context.Context, clientID string, data interface{}) error {
+func (pres *RealtimePresence) LeaveClient(ctx context.Context, clientID string, data interface{}) (err error) {
+	ctx, span := pres.startSpan(ctx, PresenceActionLeave, clientID)
+	defer func() { span.end(err) }()
 	pres.mtx.Lock()
 	if pres.data == nil {
 		pres.data = data
@@ -623,6 +634,14 @@ func (pres *RealtimePresence) LeaveClient(ctx context.Context, clientID string,
 	return res.Wait(ctx)
 }
 
+func (pres *RealtimePresence) startSpan(ctx context.Context, action PresenceAction, clientID string) (context.Context, span) {
+	return pres.channel.opts().tracer().start(ctx, SpanPresence,
+		attr(attrChannel, pres.channel.Name),
+		attr(attrPresenceAction, action.String()),
+		attr(attrClientID, clientID),
+	)
+}
+
 func (pres *RealtimePresence) auth() *Auth {
 	return pres.channel.client.Auth
 }
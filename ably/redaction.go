@@ -0,0 +1,141 @@
+package ably
+
+import (
+	"regexp"
+)
+
+// A LogRedactionRule redacts secrets from log output: the parts of log
+// records matching Pattern are replaced with Replacement, which can refer to
+// submatches as in regexp.Regexp.ReplaceAllString.
+type LogRedactionRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// logRedacted replaces redacted secrets in log output.
+const logRedacted = "[REDACTED]"
+
+var defaultLogRedactionRules = []LogRedactionRule{
+	// Credentials in URL query parameters, e.g. of the Realtime connection.
+	{
+		Pattern:     regexp.MustCompile(`\b(access_token|accessToken|key|resume|recover)=[^&\s"']+`),
+		Replacement: "${1}=" + logRedacted,
+	},
+	// API key secrets, keeping the key name.
+	{
+		Pattern:     regexp.MustCompile(`\b([A-Za-z0-9_-]+\.[A-Za-z0-9_-]+):[A-Za-z0-9_/+=-]{8,}`),
+		Replacement: "${1}:" + logRedacted,
+	},
+	// Quoted fields, as formatted by %#v or JSON.
+	{
+		Pattern:     regexp.MustCompile(`(?i)\b((?:connectionKey|recoveryKey|accessToken|token|key|mac)"?\s*[:=]\s*)"(?:[^"\\]|\\.)*"`),
+		Replacement: "${1}\"" + logRedacted + "\"",
+	},
+	// Unquoted fields, as formatted by %+v.
+	{
+		Pattern:     regexp.MustCompile(`(?i)(^|[\s{(,])(connectionKey|recoveryKey|accessToken|token|key|mac):[^\s"{}(),][^\s{}(),]*`),
+		Replacement: "${1}${2}:" + logRedacted,
+	},
+	// HTTP Authorization header values.
+	{
+		Pattern:     regexp.MustCompile(`\b(Basic|Bearer) [A-Za-z0-9+/=._-]+`),
+		Replacement: "${1} " + logRedacted,
+	},
+}
+
+// DefaultLogRedactionRules returns rules that redact access tokens, API key
+// secrets, connection keys and recovery keys from log output, whether in
+// URLs, formatted structs or JSON. They're not applied unless set with
+// [ably.WithLogRedactionRules].
+func DefaultLogRedactionRules() []LogRedactionRule {
+	return append([]LogRedactionRule(nil), defaultLogRedactionRules...)
+}
+
+// WithLogRedactionRules sets the rules with which log output is redacted. By
+// default, it isn't. To redact the usual secrets, pass
+// [ably.DefaultLogRedactionRules], with any rules of your own appended.
+//
+// Redaction applies to the formatted message of log records and to their
+// string attributes, whether they're written by LogHandler or
+// StructuredLogHandler. As the message must be formatted to be redacted, a
+// LogHandler then gets it with no arguments, as a format string with any %
+// escaped as %%, instead of the library's format string and arguments.
+func WithLogRedactionRules(rules ...LogRedactionRule) ClientOption {
+	return func(os *clientOptions) {
+		os.LogRedactionRules = append([]LogRedactionRule{}, rules...)
+	}
+}
+
+// WithLogRedactMessageData sets whether the data of messages and presence
+// messages is redacted from log output, such as protocol messages logged at
+// LogVerbose. It isn't by default.
+func WithLogRedactMessageData(redact bool) ClientOption {
+	return func(os *clientOptions) {
+		os.LogRedactMessageData = redact
+	}
+}
+
+// redactor applies LogRedactionRules. A nil *redactor doesn't redact
+// anything.
+type redactor struct {
+	rules []LogRedactionRule
+}
+
+func newRedactor(opts *clientOptions) *redactor {
+	if len(opts.LogRedactionRules) == 0 {
+		return nil
+	}
+	return &redactor{rules: opts.LogRedactionRules}
+}
+
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, rule := range r.rules {
+		if rule.Pattern != nil {
+			s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+		}
+	}
+	return s
+}
+
+func (r *redactor) redactAttrs(attrs []LogAttr) []LogAttr {
+	if r == nil || len(attrs) == 0 {
+		return attrs
+	}
+	redacted := make([]LogAttr, len(attrs))
+	for i, a := range attrs {
+		if s, ok := a.Value.(string); ok {
+			a.Value = r.redact(s)
+		}
+		redacted[i] = a
+	}
+	return redacted
+}
+
+// redactMessageData returns a copy of msg without the data of its messages
+// and presence messages, for logging.
+func redactMessageData(msg *protocolMessage) *protocolMessage {
+	if len(msg.Messages) == 0 && len(msg.Presence) == 0 {
+		return msg
+	}
+	redacted := *msg
+	redacted.Messages = make([]*Message, len(msg.Messages))
+	for i, m := range msg.Messages {
+		copied := *m
+		if copied.Data != nil {
+			copied.Data = logRedacted
+		}
+		redacted.Messages[i] = &copied
+	}
+	redacted.Presence = make([]*PresenceMessage, len(msg.Presence))
+	for i, m := range msg.Presence {
+		copied := *m
+		if copied.Data != nil {
+			copied.Data = logRedacted
+		}
+		redacted.Presence[i] = &copied
+	}
+	return &redacted
+}
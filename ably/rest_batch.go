@@ -0,0 +1,324 @@
+package ably
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// BatchPublishSpec describes a set of messages to be published to a set of
+// channels by [ably.REST.BatchPublish] (BSP1).
+type BatchPublishSpec struct {
+	// Channels are the names of the channels to publish Messages to (BSP2a).
+	Channels []string
+	// Messages are published to every channel in Channels (BSP2b).
+	Messages []*Message
+}
+
+// BatchPublishResult is the outcome of publishing a [ably.BatchPublishSpec]'s
+// messages to one of its channels (BPR1, BPF1).
+type BatchPublishResult struct {
+	// Channel is the name of the channel the messages were published to (BPR2a, BPF2a).
+	Channel string `json:"channel" codec:"channel"`
+	// MessageID is the ID prefix of the published messages. The ID of each
+	// message is the prefix followed by its index, e.g. "prefix:0" (BPR2b).
+	MessageID string `json:"messageId,omitempty" codec:"messageId,omitempty"`
+	// Error describes why publishing to the channel failed, or is nil on success (BPF2b).
+	Error *ErrorInfo `json:"error,omitempty" codec:"error,omitempty"`
+}
+
+// batchPublishResultWire is BatchPublishResult as sent by Ably, with its
+// error in the wire format of errors.
+type batchPublishResultWire struct {
+	Channel   string     `json:"channel" codec:"channel"`
+	MessageID string     `json:"messageId,omitempty" codec:"messageId,omitempty"`
+	Error     *errorInfo `json:"error,omitempty" codec:"error,omitempty"`
+}
+
+func (r BatchPublishResult) toWire() batchPublishResultWire {
+	return batchPublishResultWire{
+		Channel:   r.Channel,
+		MessageID: r.MessageID,
+		Error:     r.Error.toProto(),
+	}
+}
+
+func (r *BatchPublishResult) fromWire(w batchPublishResultWire) {
+	*r = BatchPublishResult{
+		Channel:   w.Channel,
+		MessageID: w.MessageID,
+		Error:     newErrorFromProto(w.Error),
+	}
+}
+
+func (r BatchPublishResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toWire())
+}
+
+func (r *BatchPublishResult) UnmarshalJSON(js []byte) error {
+	var w batchPublishResultWire
+	if err := json.Unmarshal(js, &w); err != nil {
+		return err
+	}
+	r.fromWire(w)
+	return nil
+}
+
+func (r BatchPublishResult) CodecEncodeSelf(encoder *codec.Encoder) {
+	encoder.MustEncode(r.toWire())
+}
+
+func (r *BatchPublishResult) CodecDecodeSelf(decoder *codec.Decoder) {
+	var w batchPublishResultWire
+	decoder.MustDecode(&w)
+	r.fromWire(w)
+}
+
+// BatchPublishSpecResult holds the per-channel results of publishing one
+// [ably.BatchPublishSpec] (BAR1).
+type BatchPublishSpecResult struct {
+	// SuccessCount is the number of channels the messages were published to (BAR2a).
+	SuccessCount int `json:"successCount" codec:"successCount"`
+	// FailureCount is the number of channels the messages failed to be published to (BAR2b).
+	FailureCount int `json:"failureCount" codec:"failureCount"`
+	// Results has one entry per channel in the spec (BAR2c).
+	Results []BatchPublishResult `json:"results" codec:"results"`
+}
+
+// batchPublishSpec is the wire representation of a BatchPublishSpec.
+type batchPublishSpec struct {
+	Channels []string   `json:"channels" codec:"channels"`
+	Messages []*Message `json:"messages" codec:"messages"`
+}
+
+// BatchPublish publishes messages to many channels in a single request to
+// the batch endpoint (RSC22).
+//
+// Messages are encoded as by [ably.RESTChannel.PublishMultiple]: a channel
+// previously configured with encryption via [ably.RESTChannels.Get] gets its
+// messages encrypted with its own cipher, and messages are given idempotent
+// IDs if [ably.WithIdempotentRESTPublishing] is enabled. The given messages
+// are not modified.
+//
+// The returned slice has one result per spec, in order, each with its
+// channels' results in the order of the spec's channels. Publishing to a
+// channel may fail while others succeed; such failures are reported in the
+// results and not as the returned error, which is only set if the request as
+// a whole failed.
+func (c *REST) BatchPublish(ctx context.Context, specs []BatchPublishSpec) ([]BatchPublishSpecResult, error) {
+	var wire []batchPublishSpec
+	// specIndex maps each wire spec to the index of the spec it came from.
+	var specIndex []int
+	for i, spec := range specs {
+		if len(spec.Channels) == 0 {
+			return nil, newError(ErrBadRequest, fmt.Errorf("batch publish spec #%d has no channels", i))
+		}
+		if len(spec.Messages) == 0 {
+			return nil, newError(ErrBadRequest, fmt.Errorf("batch publish spec #%d has no messages", i))
+		}
+		messages := make([]*Message, len(spec.Messages))
+		for j, m := range spec.Messages {
+			if m == nil {
+				return nil, newError(ErrBadRequest, fmt.Errorf("batch publish spec #%d has a nil message #%d", i, j))
+			}
+			copied := *m
+			messages[j] = &copied
+		}
+		if c.opts.idempotentRESTPublishing() {
+			if err := assignIdempotentIDs(messages); err != nil {
+				return nil, err
+			}
+		}
+
+		// Channels without a cipher share a single wire spec; each encrypted
+		// channel needs its own, since its messages encode differently.
+		var plain []string
+		for _, name := range spec.Channels {
+			cipher, err := c.Channels.configuredCipher(name)
+			if err != nil {
+				return nil, err
+			}
+			if cipher == nil {
+				plain = append(plain, name)
+				continue
+			}
+			encoded, err := encodeBatchMessages(messages, cipher)
+			if err != nil {
+				return nil, fmt.Errorf("batch publish spec #%d, channel %q: %w", i, name, err)
+			}
+			wire = append(wire, batchPublishSpec{Channels: []string{name}, Messages: encoded})
+			specIndex = append(specIndex, i)
+		}
+		if len(plain) > 0 {
+			encoded, err := encodeBatchMessages(messages, nil)
+			if err != nil {
+				return nil, fmt.Errorf("batch publish spec #%d: %w", i, err)
+			}
+			wire = append(wire, batchPublishSpec{Channels: plain, Messages: encoded})
+			specIndex = append(specIndex, i)
+		}
+	}
+	if len(wire) == 0 {
+		return nil, nil
+	}
+
+	var wireResults []BatchPublishSpecResult
+	idempotent := true
+	for _, spec := range wire {
+		idempotent = idempotent && haveIDs(spec.Messages)
+	}
+	r := &request{Method: "POST", Path: "/messages", In: wire, Out: &wireResults, Idempotent: idempotent}
+	if _, err := c.do(ctx, r); err != nil {
+		return nil, err
+	}
+	if len(wireResults) != len(wire) {
+		return nil, newError(ErrInternalError, fmt.Errorf("batch publish: expected %d results, got %d", len(wire), len(wireResults)))
+	}
+	results := make([]BatchPublishSpecResult, len(specs))
+	for i, r := range wireResults {
+		res := &results[specIndex[i]]
+		res.SuccessCount += r.SuccessCount
+		res.FailureCount += r.FailureCount
+		res.Results = append(res.Results, r.Results...)
+	}
+	// Encrypted channels were split off into their own wire specs; put the
+	// merged results back in the order of each spec's channels.
+	for i, spec := range specs {
+		position := make(map[string]int, len(spec.Channels))
+		for j := len(spec.Channels) - 1; j >= 0; j-- {
+			position[spec.Channels[j]] = j
+		}
+		res := results[i].Results
+		sort.SliceStable(res, func(a, b int) bool {
+			return position[res[a].Channel] < position[res[b].Channel]
+		})
+	}
+	return results, nil
+}
+
+func encodeBatchMessages(messages []*Message, cipher channelCipher) ([]*Message, error) {
+	encoded := make([]*Message, len(messages))
+	for i, m := range messages {
+		e, err := m.withEncodedData(cipher)
+		if err != nil {
+			return nil, fmt.Errorf("encoding data for message #%d: %w", i, err)
+		}
+		encoded[i] = &e
+	}
+	return encoded, nil
+}
+
+// configuredCipher returns the cipher of the named channel if it has been
+// created with encryption enabled, or nil otherwise.
+func (c *RESTChannels) configuredCipher(name string) (channelCipher, error) {
+	return c.lookup(name).options.configuredCipher()
+}
+
+// BatchPresenceResult holds the presence of one of the channels queried by
+// [ably.REST.BatchPresence] (BGR1, BGF1).
+type BatchPresenceResult struct {
+	// Channel is the name of the channel (BGR2a, BGF2a).
+	Channel string
+	// Presence are the members present on the channel, with their data
+	// decoded (BGR2b).
+	Presence []*PresenceMessage
+	// Error describes why the channel's presence couldn't be retrieved, or is
+	// nil on success (BGF2b).
+	Error *ErrorInfo
+}
+
+// batchPresenceMaxChannelsLength bounds the length of the channels query
+// parameter of a single batch presence request, keeping URLs well within the
+// limits of servers and proxies.
+const batchPresenceMaxChannelsLength = 4000
+
+type batchPresenceResponse struct {
+	Results []struct {
+		Channel  string     `json:"channel" codec:"channel"`
+		Presence raw        `json:"presence" codec:"presence"`
+		Error    *errorInfo `json:"error" codec:"error"`
+	} `json:"results" codec:"results"`
+}
+
+// BatchPresence retrieves the members present on each of the given channels
+// (RSC24).
+//
+// Presence data is decoded, and decrypted for channels previously configured
+// with encryption via [ably.RESTChannels.Get]. Long channel lists are split
+// across as many requests as needed. The returned error is only set if a
+// request as a whole failed; failures for single channels are reported in
+// their result.
+func (c *REST) BatchPresence(ctx context.Context, channels []string) ([]BatchPresenceResult, error) {
+	var results []BatchPresenceResult
+	for _, chunk := range chunkChannelNames(channels, batchPresenceMaxChannelsLength) {
+		params := url.Values{"channels": {strings.Join(chunk, ",")}}
+		resp, err := c.get(ctx, "/presence?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		typ, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var res batchPresenceResponse
+		if err := decodeResp(resp, &res); err != nil {
+			return nil, err
+		}
+		for _, r := range res.Results {
+			result := BatchPresenceResult{Channel: r.Channel, Error: newErrorFromProto(r.Error)}
+			if r.Error == nil && len(r.Presence) > 0 {
+				decoder := c.Channels.lookup(r.Channel).fullPresenceDecoder(&result.Presence)
+				if err := decode(typ, bytes.NewReader(r.Presence), decoder); err != nil {
+					return nil, fmt.Errorf("decoding presence for channel %q: %w", r.Channel, err)
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// chunkChannelNames splits channels into groups whose comma-separated,
+// query-escaped form is at most maxLength long. A single name longer than
+// maxLength gets a group of its own.
+func chunkChannelNames(channels []string, maxLength int) [][]string {
+	var chunks [][]string
+	var chunk []string
+	length := 0
+	for _, name := range channels {
+		n := len(url.QueryEscape(name))
+		if len(chunk) > 0 && length+len(",")+n > maxLength {
+			chunks = append(chunks, chunk)
+			chunk, length = nil, 0
+		}
+		if len(chunk) > 0 {
+			length += len(",")
+		}
+		chunk = append(chunk, name)
+		length += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// lookup returns the named channel if it exists, or else a new channel with
+// default options that isn't added to c.
+func (c *RESTChannels) lookup(name string) *RESTChannel {
+	c.mu.RLock()
+	ch, ok := c.chans[name]
+	c.mu.RUnlock()
+	if ok {
+		return ch
+	}
+	return newRESTChannel(name, c.client)
+}
@@ -0,0 +1,160 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREST_BatchPublish(t *testing.T) {
+	type wireSpec struct {
+		Channels []string        `json:"channels"`
+		Messages []*ably.Message `json:"messages"`
+	}
+	var received []wireSpec
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/messages", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		var results []ably.BatchPublishSpecResult
+		for _, spec := range received {
+			var res ably.BatchPublishSpecResult
+			for _, ch := range spec.Channels {
+				if ch == "forbidden" {
+					res.FailureCount++
+					res.Results = append(res.Results, ably.BatchPublishResult{
+						Channel: ch,
+						Error:   &ably.ErrorInfo{Code: ably.ErrForbidden, StatusCode: 403},
+					})
+					continue
+				}
+				res.SuccessCount++
+				res.Results = append(res.Results, ably.BatchPublishResult{Channel: ch, MessageID: "id-" + ch})
+			}
+			results = append(results, res)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(results)
+	}), ably.WithIdempotentRESTPublishing(true))
+
+	key, err := ably.Crypto.GenerateRandomKey(128)
+	assert.NoError(t, err)
+	client.Channels.Get("secret", ably.ChannelWithCipherKey(key))
+
+	messages := []*ably.Message{{Name: "a", Data: "hello"}, {Name: "b", Data: []byte{1, 2}}}
+	results, err := client.BatchPublish(context.Background(), []ably.BatchPublishSpec{
+		{Channels: []string{"one", "secret", "two"}, Messages: messages},
+		{Channels: []string{"forbidden"}, Messages: []*ably.Message{{Name: "c"}}},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, received, 3)
+	assert.Equal(t, []string{"secret"}, received[0].Channels)
+	assert.Equal(t, "utf-8/cipher+aes-128-cbc/base64", received[0].Messages[0].Encoding)
+	assert.Equal(t, []string{"one", "two"}, received[1].Channels)
+	assert.Equal(t, "hello", received[1].Messages[0].Data)
+	assert.Equal(t, "base64", received[1].Messages[1].Encoding)
+	assert.NotEmpty(t, received[1].Messages[0].ID)
+	assert.Equal(t, received[0].Messages[0].ID, received[1].Messages[0].ID,
+		"expected the same idempotent IDs on every channel")
+	assert.Empty(t, messages[0].ID, "expected the given messages to be left untouched")
+	assert.Equal(t, "hello", messages[0].Data)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, 3, results[0].SuccessCount)
+	assert.Equal(t, 0, results[0].FailureCount)
+	// Results follow the spec's channels, even though the encrypted channel
+	// was published in a wire spec of its own.
+	assert.Equal(t, []ably.BatchPublishResult{
+		{Channel: "one", MessageID: "id-one"},
+		{Channel: "secret", MessageID: "id-secret"},
+		{Channel: "two", MessageID: "id-two"},
+	}, results[0].Results)
+	assert.Equal(t, 1, results[1].FailureCount)
+	assert.Equal(t, ably.ErrForbidden, results[1].Results[0].Error.Code)
+
+	_, err = client.BatchPublish(context.Background(), []ably.BatchPublishSpec{{Messages: messages}})
+	assert.Error(t, err, "expected an error for a spec without channels")
+}
+
+func TestREST_BatchPresence(t *testing.T) {
+	key, err := ably.Crypto.GenerateRandomKey(128)
+	assert.NoError(t, err)
+	cipher, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: key}),
+	}).GetCipher()
+	assert.NoError(t, err)
+	encrypted, err := ably.MessageWithEncodedData(ably.Message{ClientID: "carol", Data: "secret"}, cipher)
+	assert.NoError(t, err)
+
+	var requests int
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/presence", r.URL.Path)
+		type result struct {
+			Channel  string                  `json:"channel"`
+			Presence []*ably.PresenceMessage `json:"presence,omitempty"`
+			Error    *ably.ErrorInfo         `json:"error,omitempty"`
+		}
+		var res struct {
+			Results []result `json:"results"`
+		}
+		for _, ch := range strings.Split(r.URL.Query().Get("channels"), ",") {
+			switch ch {
+			case "forbidden":
+				res.Results = append(res.Results, result{Channel: ch, Error: &ably.ErrorInfo{Code: ably.ErrForbidden, StatusCode: 403}})
+			case "secret":
+				res.Results = append(res.Results, result{Channel: ch, Presence: []*ably.PresenceMessage{
+					{Action: ably.PresenceActionPresent, Message: encrypted},
+				}})
+			default:
+				res.Results = append(res.Results, result{Channel: ch, Presence: []*ably.PresenceMessage{
+					{Action: ably.PresenceActionPresent, Message: ably.Message{ClientID: "alice", Data: `{"a":1}`, Encoding: "json"}},
+				}})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	client.Channels.Get("secret", ably.ChannelWithCipherKey(key))
+
+	results, err := client.BatchPresence(context.Background(), []string{"public", "secret", "forbidden"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "public", results[0].Channel)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, results[0].Presence[0].Data)
+	assert.Equal(t, "secret", results[1].Channel)
+	assert.Equal(t, "secret", results[1].Presence[0].Data)
+	assert.Empty(t, results[1].Presence[0].Encoding)
+	assert.Equal(t, "forbidden", results[2].Channel)
+	assert.Nil(t, results[2].Presence)
+	assert.Equal(t, ably.ErrForbidden, results[2].Error.Code)
+	assert.False(t, client.Channels.Exists("public"), "expected no channel to be created")
+
+	t.Run("splits long channel lists across requests", func(t *testing.T) {
+		requests = 0
+		var channels []string
+		for i := 0; i < 500; i++ {
+			channels = append(channels, fmt.Sprintf("a-fairly-long-channel-name-%03d", i))
+		}
+		results, err := client.BatchPresence(context.Background(), channels)
+		assert.NoError(t, err)
+		assert.Greater(t, requests, 1)
+		assert.Len(t, results, len(channels))
+		for i, r := range results {
+			assert.Equal(t, channels[i], r.Channel)
+		}
+	})
+}
@@ -21,9 +21,13 @@ type RESTChannel struct {
 	// Presence is a [ably.RESTPresence] object (RSL3).
 	Presence *RESTPresence
 
-	client  *REST
-	baseURL string
-	options *protoChannelOptions
+	// Push is a [ably.PushChannel] object, for subscribing devices to push notifications on the channel (RSH7).
+	Push *PushChannel
+
+	client         *REST
+	baseURL        string
+	options        *protoChannelOptions
+	publishLimiter *rateLimiter
 }
 
 func newRESTChannel(name string, client *REST) *RESTChannel {
@@ -36,9 +40,17 @@ func newRESTChannel(name string, client *REST) *RESTChannel {
 		client:  client,
 		channel: c,
 	}
+	c.Push = newPushChannel(name, client)
 	return c
 }
 
+func (c *RESTChannel) setOptions(opts *protoChannelOptions) {
+	c.options = opts
+	if opts != nil {
+		c.publishLimiter = c.publishLimiter.reuse(opts.PublishRateLimit, c.client.opts)
+	}
+}
+
 // pathName is channel's name path escaped
 func (c *RESTChannel) pathName() string {
 	return url.PathEscape(c.Name)
@@ -87,48 +99,35 @@ func PublishMultipleWithParams(params map[string]string) PublishMultipleOption {
 }
 
 // PublishMultiple publishes multiple messages in a batch. Returns error if there is a problem publishing message (RSL1).
+//
+// If a publish rate limit is set, with [ably.WithPublishRateLimit] or
+// [ably.ChannelWithPublishRateLimit], PublishMultiple waits until the limit
+// allows the messages to be published, or fails if the limit fails fast.
 func (c *RESTChannel) PublishMultiple(ctx context.Context, messages []*Message, options ...PublishMultipleOption) error {
 	var publishOpts publishMultipleOptions
 	for _, o := range options {
 		o(&publishOpts)
 	}
+	cipher, err := c.options.configuredCipher()
+	if err != nil {
+		return err
+	}
 	for i, m := range messages {
-		cipher, _ := c.options.GetCipher()
 		var err error
 		*m, err = (*m).withEncodedData(cipher)
 		if err != nil {
 			return fmt.Errorf("encoding data for message #%d: %w", i, err)
 		}
 	}
-	useIdempotent := c.client.opts.idempotentRESTPublishing()
-	if useIdempotent {
-		switch len(messages) {
-		case 1:
-			// spec RSL1k2 we preserve the id if we have one message and it contains the
-			// id.
-			if messages[0].ID == "" {
-				base, err := ablyutil.BaseID()
-				if err != nil {
-					return err
-				}
-				messages[0].ID = fmt.Sprintf("%s:%d", base, 0)
-			}
-		default:
-			empty := true
-			for _, v := range messages {
-				if v.ID != "" {
-					empty = false
-				}
-			}
-			if empty { // spec RSL1k3,RSL1k1
-				base, err := ablyutil.BaseID()
-				if err != nil {
-					return err
-				}
-				for k, v := range messages {
-					v.ID = fmt.Sprintf("%s:%d", base, k)
-				}
-			}
+	return c.publishEncoded(ctx, messages, publishOpts)
+}
+
+// publishEncoded publishes messages whose data has already been encoded for
+// the channel.
+func (c *RESTChannel) publishEncoded(ctx context.Context, messages []*Message, publishOpts publishMultipleOptions) error {
+	if c.client.opts.idempotentRESTPublishing() {
+		if err := assignIdempotentIDs(messages); err != nil {
+			return err
 		}
 	}
 	var query string
@@ -146,13 +145,70 @@ func (c *RESTChannel) PublishMultiple(ctx context.Context, messages []*Message,
 		}
 	}
 
-	res, err := c.client.post(ctx, c.baseURL+"/messages"+query, messages, nil)
+	messages = c.client.opts.tracer().injectMessages(ctx, messages)
+
+	limiters := publishLimiters{c.client.publishLimiter, c.publishLimiter}
+	if err := limiters.wait(ctx, len(messages)); err != nil {
+		return err
+	}
+	res, err := c.client.do(ctx, &request{
+		Method:     "POST",
+		Path:       c.baseURL + "/messages" + query,
+		In:         messages,
+		Idempotent: haveIDs(messages),
+	})
+	limiters.observe(err)
 	if err != nil {
 		return err
 	}
 	return res.Body.Close()
 }
 
+// haveIDs returns whether every message has an ID, so that Ably discards
+// duplicates if they are published again.
+func haveIDs(messages []*Message) bool {
+	for _, m := range messages {
+		if m.ID == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// assignIdempotentIDs gives messages library-generated IDs so that the server
+// can discard duplicates when a publish is retried (RSL1k).
+func assignIdempotentIDs(messages []*Message) error {
+	switch len(messages) {
+	case 1:
+		// spec RSL1k2 we preserve the id if we have one message and it contains the
+		// id.
+		if messages[0].ID == "" {
+			base, err := ablyutil.BaseID()
+			if err != nil {
+				return err
+			}
+			messages[0].ID = fmt.Sprintf("%s:%d", base, 0)
+		}
+	default:
+		empty := true
+		for _, v := range messages {
+			if v.ID != "" {
+				empty = false
+			}
+		}
+		if empty { // spec RSL1k3,RSL1k1
+			base, err := ablyutil.BaseID()
+			if err != nil {
+				return err
+			}
+			for k, v := range messages {
+				v.ID = fmt.Sprintf("%s:%d", base, k)
+			}
+		}
+	}
+	return nil
+}
+
 // PublishMultipleWithOptions is the same as PublishMultiple.
 //
 // Deprecated: Use PublishMultiple instead.
@@ -240,11 +296,15 @@ func (c *RESTChannel) Status(ctx context.Context) (*ChannelDetails, error) {
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
 func (c *RESTChannel) History(o ...HistoryOption) HistoryRequest {
-	params := (&historyOptions{}).apply(o...)
-	return HistoryRequest{
-		r:       c.client.newPaginatedRequest("/channels/"+c.Name+"/history", "/channels/"+c.pathName()+"/history", params),
-		channel: c,
+	var opts historyOptions
+	params := opts.apply(o...)
+	r := c.client.newPaginatedRequest("/channels/"+c.Name+"/history", "/channels/"+c.pathName()+"/history", params)
+	if opts.cursor != "" {
+		r = r.fromCursor(opts.cursor)
 	}
+	paginated := newPaginated(r, c.fullMessagesDecoder)
+	paginated.rawPages = true
+	return HistoryRequest{Paginated: paginated}
 }
 
 // A HistoryOption configures a call to RESTChannel.History or RealtimeChannel.History.
@@ -283,8 +343,23 @@ func HistoryWithDirection(d Direction) HistoryOption {
 	}
 }
 
+// HistoryWithCursor resumes reading the history from the page cursor points
+// to, as returned by the Cursor method of a previous result for the same
+// channel. Other options are ignored, as the cursor already holds the
+// parameters of the original request.
+func HistoryWithCursor(cursor string) HistoryOption {
+	return func(o *historyOptions) {
+		o.cursor = cursor
+	}
+}
+
 type historyOptions struct {
 	params url.Values
+	cursor string
+
+	// Only used by ExportHistory.
+	exportProgress func(HistoryExportProgress)
+	rawExport      bool
 }
 
 func (o *historyOptions) apply(opts ...HistoryOption) url.Values {
@@ -297,71 +372,25 @@ func (o *historyOptions) apply(opts ...HistoryOption) url.Values {
 
 // HistoryRequest represents a request prepared by the RESTChannel.History or
 // RealtimeChannel.History method, ready to be performed by its Pages or Items methods.
-type HistoryRequest struct {
-	r       paginatedRequest
-	channel *RESTChannel
-}
-
-// Pages returns an iterator for whole pages of History.
-//
-// See package-level documentation => [ably] Pagination for details about history pagination.
-func (r HistoryRequest) Pages(ctx context.Context) (*MessagesPaginatedResult, error) {
-	var res MessagesPaginatedResult
-	return &res, res.load(ctx, r.r)
-}
-
-// A MessagesPaginatedResult is an iterator for the result of a History request.
 //
-// See package-level documentation => [ably] Pagination for details about history pagination.
-type MessagesPaginatedResult struct {
-	PaginatedResult
-	items []*Message
-}
-
-// Next retrieves the next page of results.
-//
-// See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedResult) Next(ctx context.Context) bool {
-	p.items = nil // avoid mutating already returned items
-	return p.next(ctx, &p.items)
-}
-
-// IsLast returns true if the page is last page.
-//
-// See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedResult) IsLast(ctx context.Context) bool {
-	return !p.HasNext(ctx)
-}
-
-// HasNext returns true is there are more pages available.
+// Message data is decoded, and decrypted if the channel has a cipher, by
+// Items, Collect and All. Pages leaves it as sent by Ably.
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedResult) HasNext(ctx context.Context) bool {
-	return p.nextLink != ""
+type HistoryRequest struct {
+	Paginated[*Message]
 }
 
-// Items returns the current page of results.
+// A MessagesPaginatedResult is an iterator for the result of a History request.
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedResult) Items() []*Message {
-	return p.items
-}
+type MessagesPaginatedResult = PaginatedPages[*Message]
 
-// Items returns a convenience iterator for single History, over an underlying
-// paginated iterator.
+// A MessagesPaginatedItems is an iterator for single messages of a History
+// request, over an underlying paginated iterator.
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
-func (r HistoryRequest) Items(ctx context.Context) (*MessagesPaginatedItems, error) {
-	var res MessagesPaginatedItems
-	var err error
-	res.next, err = res.loadItems(ctx, r.r, func() (interface{}, func() int) {
-		res.items = nil // avoid mutating already returned Items
-		return r.channel.fullMessagesDecoder(&res.items), func() int {
-			return len(res.items)
-		}
-	})
-	return &res, err
-}
+type MessagesPaginatedItems = PaginatedItems[*Message]
 
 // fullMessagesDecoder wraps a destination slice of messages in a decoder value
 // that decodes both the message itself from the transport-level encoding and
@@ -405,37 +434,11 @@ func (t *fullMessagesDecoder) decodeMessagesData() {
 		*m, err = m.withDecodedData(cipher)
 		if err != nil {
 			// RSL6b
-			t.c.log().Errorf("Couldn't fully decode message data from channel %q: %w", t.c.Name, err)
+			t.c.log().Errorf("Couldn't fully decode message data from channel %q: %v", t.c.Name, err)
 		}
 	}
 }
 
-type MessagesPaginatedItems struct {
-	PaginatedResult
-	items []*Message
-	item  *Message
-	next  func(context.Context) (int, bool)
-}
-
-// Next retrieves the next result.
-//
-// See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedItems) Next(ctx context.Context) bool {
-	i, ok := p.next(ctx)
-	if !ok {
-		return false
-	}
-	p.item = p.items[i]
-	return true
-}
-
-// Item returns the current result.
-//
-// See package-level documentation => [ably] Pagination for details about history pagination.
-func (p *MessagesPaginatedItems) Item() *Message {
-	return p.item
-}
-
 func (c *RESTChannel) log() logger {
-	return c.client.log
+	return c.client.log.with(LogAttr{Key: LogKeyChannel, Value: c.Name})
 }
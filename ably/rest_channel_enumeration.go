@@ -0,0 +1,130 @@
+package ably
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/ugorji/go/codec"
+)
+
+// A ChannelListOption configures a call to [ably.RESTChannels.List].
+type ChannelListOption func(*channelListOptions)
+
+// ChannelsWithPrefix restricts the listed channels to those whose name starts
+// with prefix.
+func ChannelsWithPrefix(prefix string) ChannelListOption {
+	return func(o *channelListOptions) {
+		o.params.Set("prefix", prefix)
+	}
+}
+
+// ChannelsWithLimit sets an upper limit on the number of channels returned
+// per page. The default is 100, and the maximum is 1000.
+func ChannelsWithLimit(limit int) ChannelListOption {
+	return func(o *channelListOptions) {
+		o.params.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+// ChannelsWithDetails makes the listed [ably.ChannelDetails] include the
+// status and occupancy of each channel. Without it, only their ChannelId is
+// set.
+func ChannelsWithDetails() ChannelListOption {
+	return func(o *channelListOptions) {
+		o.details = true
+	}
+}
+
+type channelListOptions struct {
+	params  url.Values
+	details bool
+}
+
+func (o *channelListOptions) apply(opts ...ChannelListOption) url.Values {
+	o.params = make(url.Values)
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.details {
+		o.params.Set("by", "value")
+	} else {
+		o.params.Set("by", "id")
+	}
+	return o.params
+}
+
+// List prepares a request for the channels that are currently active in the
+// app, that is, that have at least one connected client or have had messages
+// published to them recently (RSN6).
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+func (c *RESTChannels) List(o ...ChannelListOption) ChannelsRequest {
+	var opts channelListOptions
+	params := opts.apply(o...)
+	var decoder func(*[]*ChannelDetails) interface{}
+	if !opts.details {
+		decoder = channelNamesDecoder
+	}
+	return ChannelsRequest{newPaginated(c.client.newPaginatedRequest("/channels", "", params), decoder)}
+}
+
+// ChannelsRequest represents a request prepared by the RESTChannels.List
+// method, ready to be performed by its Pages or Items methods.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type ChannelsRequest struct {
+	Paginated[*ChannelDetails]
+}
+
+// A ChannelsPaginatedResult is an iterator for the result of a
+// RESTChannels.List request.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type ChannelsPaginatedResult = PaginatedPages[*ChannelDetails]
+
+// A ChannelsPaginatedItems is an iterator for single ChannelDetails, over an
+// underlying paginated iterator.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type ChannelsPaginatedItems = PaginatedItems[*ChannelDetails]
+
+// channelNamesDecoder wraps a destination slice of channel details in a
+// decoder value that decodes a list of channel names into it.
+func channelNamesDecoder(dst *[]*ChannelDetails) interface{} {
+	return &channelNames{dst: dst}
+}
+
+type channelNames struct {
+	dst *[]*ChannelDetails
+}
+
+func (t *channelNames) UnmarshalJSON(b []byte) error {
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return err
+	}
+	t.set(names)
+	return nil
+}
+
+func (t *channelNames) CodecEncodeSelf(*codec.Encoder) {
+	panic("channelNames cannot be used as encoder")
+}
+
+func (t *channelNames) CodecDecodeSelf(decoder *codec.Decoder) {
+	var names []string
+	decoder.MustDecode(&names)
+	t.set(names)
+}
+
+var _ interface {
+	json.Unmarshaler
+	codec.Selfer
+} = (*channelNames)(nil)
+
+func (t *channelNames) set(names []string) {
+	for _, name := range names {
+		*t.dst = append(*t.dst, &ChannelDetails{ChannelId: name})
+	}
+}
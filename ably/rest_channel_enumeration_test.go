@@ -0,0 +1,75 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTChannels_List(t *testing.T) {
+	var queries []string
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/channels", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case q.Get("by") == "value":
+			w.Write([]byte(`[{"channelId":"chat:a","status":{"isActive":true,"occupancy":{"metrics":{"connections":2,"subscribers":1}}}}]`))
+		case q.Get("cursor") == "":
+			w.Header().Set("Link", `<./channels?by=id&cursor=next>; rel="next"`)
+			w.Write([]byte(`["chat:a","chat:b"]`))
+		default:
+			w.Write([]byte(`["chat:c"]`))
+		}
+	}))
+	ctx := context.Background()
+
+	t.Run("names", func(t *testing.T) {
+		queries = nil
+		res, err := client.Channels.List(ably.ChannelsWithPrefix("chat:"), ably.ChannelsWithLimit(2)).Pages(ctx)
+		assert.NoError(t, err)
+		var names []string
+		for res.Next(ctx) {
+			for _, ch := range res.Items() {
+				assert.Equal(t, ably.ChannelStatus{}, ch.Status)
+				names = append(names, ch.ChannelId)
+			}
+		}
+		assert.NoError(t, res.Err())
+		assert.Equal(t, []string{"chat:a", "chat:b", "chat:c"}, names)
+		assert.Equal(t, "by=id&limit=2&prefix=chat%3A", queries[0])
+		assert.Len(t, queries, 2)
+	})
+
+	t.Run("items", func(t *testing.T) {
+		items, err := client.Channels.List().Items(ctx)
+		assert.NoError(t, err)
+		var names []string
+		for items.Next(ctx) {
+			names = append(names, items.Item().ChannelId)
+		}
+		assert.NoError(t, items.Err())
+		assert.Equal(t, []string{"chat:a", "chat:b", "chat:c"}, names)
+	})
+
+	t.Run("details", func(t *testing.T) {
+		res, err := client.Channels.List(ably.ChannelsWithDetails()).Pages(ctx)
+		assert.NoError(t, err)
+		assert.True(t, res.Next(ctx))
+		assert.True(t, res.IsLast(ctx))
+		items := res.Items()
+		assert.Len(t, items, 1)
+		assert.Equal(t, "chat:a", items[0].ChannelId)
+		assert.True(t, items[0].Status.IsActive)
+		assert.Equal(t, 2, items[0].Status.Occupancy.Metrics.Connections)
+		assert.Equal(t, 1, items[0].Status.Occupancy.Metrics.Subscribers)
+	})
+}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/rand"
 	"mime"
 	"net"
 	"net/http"
@@ -102,17 +101,21 @@ func (c *RESTChannels) Get(name string, options ...ChannelOption) *RESTChannel {
 }
 
 func (c *RESTChannels) get(name string, opts *protoChannelOptions) *RESTChannel {
+	if opts != nil {
+		opts.channel = name
+		opts.clientOpts = c.client.opts
+	}
 	c.mu.RLock()
 	v, ok := c.chans[name]
 	c.mu.RUnlock()
 	if ok {
 		if opts != nil {
-			v.options = opts
+			v.setOptions(opts)
 		}
 		return v
 	}
 	v = newRESTChannel(name, c.client)
-	v.options = opts
+	v.setOptions(opts)
 	c.mu.Lock()
 	c.chans[name] = v
 	c.mu.Unlock()
@@ -134,9 +137,13 @@ type REST struct {
 	//Channels is a [ably.RESTChannels] object (RSN1).
 	Channels *RESTChannels
 
-	opts      *clientOptions
-	hostCache *hostCache
-	log       logger
+	// Push is a [ably.Push] object (RSH1).
+	Push *Push
+
+	opts           *clientOptions
+	hosts          *hostTracker
+	publishLimiter *rateLimiter
+	log            logger
 }
 
 // NewREST construct a RestClient object using an [ably.ClientOption] object to configure
@@ -148,7 +155,7 @@ func NewREST(options ...ClientOption) (*REST, error) {
 	if err := c.opts.validate(); err != nil {
 		return nil, err
 	}
-	c.log = logger{l: c.opts.LogHandler}
+	c.log = newLogger(c.opts)
 	auth, err := newAuth(c)
 	if err != nil {
 		return nil, err
@@ -158,9 +165,9 @@ func NewREST(options ...ClientOption) (*REST, error) {
 		chans:  make(map[string]*RESTChannel),
 		client: c,
 	}
-	c.hostCache = &hostCache{
-		duration: c.opts.fallbackRetryTimeout(),
-	}
+	c.Push = newPush(c)
+	c.hosts = newHostTracker(c.opts)
+	c.publishLimiter = newRateLimiter(c.opts.PublishRateLimit, c.opts)
 	return c, nil
 }
 
@@ -192,7 +199,7 @@ func (c *REST) Time(ctx context.Context) (time.Time, error) {
 // See package-level documentation => [ably] Pagination for handling stats pagination.
 func (c *REST) Stats(o ...StatsOption) StatsRequest {
 	params := (&statsOptions{}).apply(o...)
-	return StatsRequest{r: c.newPaginatedRequest("/stats", "", params)}
+	return StatsRequest{newPaginated[*Stats](c.newPaginatedRequest("/stats", "", params), nil)}
 }
 
 // A StatsOption configures a call to REST.Stats or Realtime.Stats.
@@ -260,94 +267,22 @@ func (o *statsOptions) apply(opts ...StatsOption) url.Values {
 
 // StatsRequest represents a request prepared by the REST.Stats or
 // Realtime.Stats method, ready to be performed by its Pages or Items methods.
-type StatsRequest struct {
-	r paginatedRequest
-}
-
-// Pages returns an iterator for whole pages of Stats.
 //
 // See package-level documentation => [ably] Pagination for handling stats pagination.
-func (r StatsRequest) Pages(ctx context.Context) (*StatsPaginatedResult, error) {
-	var res StatsPaginatedResult
-	return &res, res.load(ctx, r.r)
+type StatsRequest struct {
+	Paginated[*Stats]
 }
 
 // A StatsPaginatedResult is an iterator for the result of a Stats request.
 //
 // See package-level documentation => [ably] Pagination for handling stats pagination.
-type StatsPaginatedResult struct {
-	PaginatedResult
-	items []*Stats
-}
-
-// Next retrieves the next page of results.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedResult) Next(ctx context.Context) bool {
-	p.items = nil // avoid mutating already returned items
-	return p.next(ctx, &p.items)
-}
+type StatsPaginatedResult = PaginatedPages[*Stats]
 
-// IsLast returns true if the page is last page.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedResult) IsLast(ctx context.Context) bool {
-	return !p.HasNext(ctx)
-}
-
-// HasNext returns true is there are more pages available.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedResult) HasNext(ctx context.Context) bool {
-	return p.nextLink != ""
-}
-
-// Items returns the current page of results.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedResult) Items() []*Stats {
-	return p.items
-}
-
-// Items returns a convenience iterator for single Stats, over an underlying
+// A StatsPaginatedItems is an iterator for single Stats, over an underlying
 // paginated iterator.
 //
 // See package-level documentation => [ably] Pagination for handling stats pagination.
-func (r StatsRequest) Items(ctx context.Context) (*StatsPaginatedItems, error) {
-	var res StatsPaginatedItems
-	var err error
-	res.next, err = res.loadItems(ctx, r.r, func() (interface{}, func() int) {
-		res.items = nil // avoid mutating already returned items
-		return &res.items, func() int { return len(res.items) }
-	})
-	return &res, err
-}
-
-type StatsPaginatedItems struct {
-	PaginatedResult
-	items []*Stats
-	item  *Stats
-	next  func(context.Context) (int, bool)
-}
-
-// Next retrieves the next result.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedItems) Next(ctx context.Context) bool {
-	i, ok := p.next(ctx)
-	if !ok {
-		return false
-	}
-	p.item = p.items[i]
-	return true
-}
-
-// Item returns the current result.
-//
-// See package-level documentation => [ably] Pagination for handling stats pagination.
-func (p *StatsPaginatedItems) Item() *Stats {
-	return p.item
-}
+type StatsPaginatedItems = PaginatedItems[*Stats]
 
 // request contains fields necessary to compose http request that will be sent ably endpoints.
 type request struct {
@@ -362,6 +297,15 @@ type request struct {
 	// NoRenew when set true, token is not refreshed when request fails with token expired response
 	NoRenew bool
 	header  http.Header
+
+	// Idempotent when set true, makes a POST request safe to retry even if
+	// Ably may have processed it already, e.g. a publish of messages with IDs.
+	Idempotent bool
+
+	// attempts is the number of times the request has been sent so far.
+	attempts int
+	// lastResponse is the response to the last attempt, if Ably responded.
+	lastResponse *http.Response
 }
 
 // Request makes a REST request with given http method (GET, POST) and url path. This is provided as a convenience for
@@ -558,32 +502,16 @@ func (p *HTTPPaginatedResponse) Items(dst interface{}) error {
 //
 // See package-level documentation => [ably] Pagination for more details.
 func (r RESTRequest) Items(ctx context.Context) (*RESTPaginatedItems, error) {
-	var res RESTPaginatedItems
-	var err error
-	res.next, err = res.loadItems(ctx, r.r, func() (interface{}, func() int) {
-		res.items = nil
-		return &res.items, func() int { return len(res.items) }
-	})
-	return &res, err
-}
-
-type RESTPaginatedItems struct {
-	PaginatedResult
-	items []raw
-	item  raw
-	next  func(context.Context) (int, bool)
+	items, err := newPaginated[raw](r.r, nil).Items(ctx)
+	return &RESTPaginatedItems{PaginatedItems: items}, err
 }
 
-// Next retrieves the next result.
+// A RESTPaginatedItems is an iterator for single items of the response of a
+// REST request, over an underlying paginated iterator.
 //
 // See the "Paginated results" section in the package-level documentation.
-func (p *RESTPaginatedItems) Next(ctx context.Context) bool {
-	i, ok := p.next(ctx)
-	if !ok {
-		return false
-	}
-	p.item = p.items[i]
-	return true
+type RESTPaginatedItems struct {
+	*PaginatedItems[raw]
 }
 
 // IsLast returns true if the page is last page.
@@ -608,7 +536,7 @@ func (p *RESTPaginatedItems) Item(dst interface{}) error {
 	if err != nil {
 		return err
 	}
-	return decode(typ, bytes.NewReader(p.item), dst)
+	return decode(typ, bytes.NewReader(p.PaginatedItems.Item()), dst)
 }
 
 func (c *REST) get(ctx context.Context, path string, out interface{}) (*http.Response, error) {
@@ -630,91 +558,95 @@ func (c *REST) post(ctx context.Context, path string, in, out interface{}) (*htt
 	return c.do(ctx, r)
 }
 
+func (c *REST) delete(ctx context.Context, path string, params url.Values) error {
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	_, err := c.do(ctx, &request{
+		Method: "DELETE",
+		Path:   path,
+	})
+	return err
+}
+
 func (c *REST) do(ctx context.Context, r *request) (*http.Response, error) {
 	return c.doWithHandle(ctx, r, c.handleResponse)
 }
 
-func (c *REST) doWithHandle(ctx context.Context, r *request, handle func(*http.Response, interface{}) (*http.Response, error)) (*http.Response, error) {
+func (c *REST) doWithHandle(ctx context.Context, r *request, handle func(*http.Response, interface{}) (*http.Response, error)) (resp *http.Response, err error) {
+	ctx, span := c.opts.tracer().start(ctx, SpanRESTRequest,
+		attr(attrHTTPMethod, r.Method),
+		attr(attrURLPath, r.Path),
+	)
+	defer func() {
+		if resp != nil {
+			span.setAttributes(attr(attrHTTPStatusCode, resp.StatusCode))
+		}
+		span.end(err)
+	}()
+	if c.opts.RetryPolicy != nil {
+		return c.doWithRetries(ctx, r, handle)
+	}
+	return c.doWithFallbacks(ctx, r, handle)
+}
+
+func (c *REST) doWithFallbacks(ctx context.Context, r *request, handle func(*http.Response, interface{}) (*http.Response, error)) (*http.Response, error) {
 	req, err := c.newHTTPRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	if h := c.hostCache.get(); h != "" {
-		req.URL.Host = h // RSC15f
+	primary := req.URL.Host
+	hosts := c.hostOrder()
+	if h := hosts[0]; h != primary {
+		setFallbackHost(req, h) // RSC15f
 		c.log.Verbosef("RestClient: setting URL.Host=%q", h)
 	}
 	if c.opts.Trace != nil {
 		req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.opts.Trace))
 		c.log.Verbose("RestClient: enabling httptrace")
 	}
-	resp, err := c.opts.httpclient().Do(req)
-	serverResp := resp
-	if err == nil {
-		resp, err = handle(resp, r.Out)
-	} else {
-		c.log.Error("RestClient: failed sending a request ", err)
-	}
+	resp, err := c.sendTracked(r, req, hosts[0] != primary, handle)
 	if err != nil {
+		serverResp := resp
 		c.log.Error("RestClient: error handling response: ", err)
 		if canFallBack(err, serverResp) {
-			fallbacks, _ := c.opts.getFallbackHosts()
+			fallbacks := hosts[1:]
 			c.log.Infof("RestClient: trying to fallback with hosts=%v", fallbacks)
 			if len(fallbacks) > 0 {
-				left := fallbacks
-				iteration := 0
 				maxLimit := c.opts.HTTPMaxRetryCount
 				if maxLimit == 0 {
 					maxLimit = defaultOptions.HTTPMaxRetryCount
 				}
 				c.log.Infof("RestClient: maximum fallback retry limit=%d", maxLimit)
 
-				for {
-					if len(left) == 0 {
-						c.log.Errorf("RestClient: exhausted fallback hosts", err)
-						return nil, err
-					}
-					var h string
-					if len(left) == 1 {
-						h = left[0]
-					} else {
-						h = left[rand.Intn(len(left)-1)]
-					}
-					var n []string
-					for _, v := range left {
-						if v != h {
-							n = append(n, v)
-						}
-					}
-					left = n
+				for iteration, h := range fallbacks {
 					req, err := c.newHTTPRequest(ctx, r)
 					if err != nil {
 						return nil, err
 					}
-					c.log.Infof("RestClient:  chose fallback host=%q ", h)
-					req.URL.Host = h
-					req.Host = ""
-					req.Header.Set(hostHeader, h)
-					resp, err := c.opts.httpclient().Do(req)
-					serverResp := resp
-					if err == nil {
-						resp, err = handle(resp, r.Out)
-					} else {
-						c.log.Error("RestClient: failed sending a request to a fallback host", err)
+					c.log.with(LogAttr{Key: LogKeyHost, Value: h}).Infof("RestClient:  chose fallback host=%q ", h)
+					if h != primary {
+						setFallbackHost(req, h)
+						c.opts.metrics().RESTFallbackAttempted(h)
 					}
+					resp, err := c.sendTracked(r, req, h != primary, handle)
 					if err != nil {
+						serverResp := resp
 						c.log.Error("RestClient: error handling response: ", err)
 						if iteration == maxLimit-1 {
 							return nil, err
 						}
 						if canFallBack(err, serverResp) {
-							iteration++
 							continue
 						}
 						return nil, err
 					}
-					c.hostCache.put(h)
+					if h != primary {
+						c.hosts.prefer(h)
+					}
 					return resp, nil
 				}
+				c.log.Errorf("RestClient: exhausted fallback hosts: %v", err)
 			}
 			return nil, err
 		}
@@ -727,7 +659,7 @@ func (c *REST) doWithHandle(ctx context.Context, r *request, handle func(*http.R
 					return nil, err
 				}
 				r.NoRenew = true
-				return c.do(ctx, r)
+				return c.doWithFallbacks(ctx, r, c.handleResponse)
 			}
 		}
 		return nil, err
@@ -735,6 +667,28 @@ func (c *REST) doWithHandle(ctx context.Context, r *request, handle func(*http.R
 	return resp, nil
 }
 
+func setFallbackHost(req *http.Request, host string) {
+	req.URL.Host = host
+	req.Host = ""
+	req.Header.Set(hostHeader, host)
+}
+
+// sendTracked sends req as send does, recording the outcome in the health of
+// the host it's sent to.
+func (c *REST) sendTracked(r *request, req *http.Request, fallback bool, handle func(*http.Response, interface{}) (*http.Response, error)) (*http.Response, error) {
+	host := req.URL.Host
+	c.hosts.attempt(host)
+	start := c.opts.Now()
+	resp, err := c.send(r, req, fallback, handle)
+	if err != nil && req.Context().Err() != nil {
+		// The request was abandoned; that says nothing about the host.
+		c.hosts.abandon(host)
+		return resp, err
+	}
+	c.hosts.record(host, c.opts.Now().Sub(start), err != nil && canFallBack(err, resp))
+	return resp, err
+}
+
 func canFallBack(err error, res *http.Response) bool {
 	return isStatusCodeBetween500_504(res) || // RSC15l3
 		isCloudFrontError(res) || //RSC15l4
@@ -868,29 +822,3 @@ func decodeResp(resp *http.Response, out interface{}) error {
 
 	return decode(typ, bytes.NewReader(b), out)
 }
-
-// hostCache caches a successful fallback host for 10 minutes.
-// Only used by REST client while making requests RSC15f
-type hostCache struct {
-	duration time.Duration
-
-	sync.RWMutex
-	deadline time.Time
-	host     string
-}
-
-func (c *hostCache) put(host string) {
-	c.Lock()
-	defer c.Unlock()
-	c.host = host
-	c.deadline = time.Now().Add(c.duration)
-}
-
-func (c *hostCache) get() string {
-	c.RLock()
-	defer c.RUnlock()
-	if ablyutil.Empty(c.host) || time.Until(c.deadline) <= 0 {
-		return ""
-	}
-	return c.host
-}
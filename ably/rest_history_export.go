@@ -0,0 +1,140 @@
+package ably
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ably/ably-go/ably/internal/ablyutil"
+)
+
+// HistoryExportFormat is the format of the records written by
+// [ably.RESTChannel.ExportHistory].
+type HistoryExportFormat string
+
+const (
+	// HistoryExportNDJSON writes each message as a JSON object on its own line.
+	HistoryExportNDJSON HistoryExportFormat = "ndjson"
+	// HistoryExportMsgpack writes each message as a MessagePack map, one right
+	// after the other.
+	HistoryExportMsgpack HistoryExportFormat = "msgpack"
+)
+
+// HistoryExportProgress reports how far a call to
+// [ably.RESTChannel.ExportHistory] has got. It's passed to the callback set
+// with [ably.HistoryWithExportProgress] after each page of messages is
+// written.
+type HistoryExportProgress struct {
+	// Pages is the number of pages written so far.
+	Pages int
+	// Messages is the number of messages written so far.
+	Messages int
+	// Bytes is the number of bytes written so far.
+	Bytes int64
+	// LastTimestamp is the timestamp of the last message written, as
+	// milliseconds since the Unix epoch.
+	LastTimestamp int64
+	// Cursor points to the next page of messages, or is empty once the export
+	// is done. An interrupted export can be continued by passing it to
+	// [ably.HistoryWithCursor].
+	Cursor string
+}
+
+// HistoryWithExportProgress sets a function that [ably.RESTChannel.ExportHistory]
+// calls after writing each page of messages. It's ignored by History.
+func HistoryWithExportProgress(progress func(HistoryExportProgress)) HistoryOption {
+	return func(o *historyOptions) {
+		o.exportProgress = progress
+	}
+}
+
+// HistoryWithRawExport makes [ably.RESTChannel.ExportHistory] write messages
+// as they are received from Ably, without decoding or decrypting their data;
+// the Encoding field of each record says how to do so. It's ignored by
+// History.
+func HistoryWithRawExport() HistoryOption {
+	return func(o *historyOptions) {
+		o.rawExport = true
+	}
+}
+
+// ExportHistory writes the channel's message history to w, one record per
+// message in the given format. It takes the same options as History, so e.g.
+// [ably.HistoryWithStart] and [ably.HistoryWithEnd] restrict the export to a
+// time window, plus [ably.HistoryWithExportProgress] and
+// [ably.HistoryWithRawExport].
+//
+// Messages are written oldest first, so that [ably.RESTChannel.ImportHistory]
+// publishes them in their original order, unless another direction is set
+// with [ably.HistoryWithDirection].
+//
+// Pages are fetched and written one at a time, so memory use is bounded by
+// the page size, regardless of the length of the history.
+//
+// By default, message data is decoded and decrypted as by History. As JSON
+// can't hold binary data, NDJSON records of messages with binary data have it
+// base64-encoded, with an encoding of "base64".
+func (c *RESTChannel) ExportHistory(ctx context.Context, w io.Writer, format HistoryExportFormat, o ...HistoryOption) error {
+	var opts historyOptions
+	opts.apply(o...)
+
+	var encodeRecord func(*Message) ([]byte, error)
+	switch format {
+	case HistoryExportNDJSON:
+		encodeRecord = func(m *Message) ([]byte, error) {
+			if d, ok := m.Data.([]byte); ok {
+				copied := *m
+				copied.Data = base64.StdEncoding.EncodeToString(d)
+				copied.Encoding = mergeEncoding(copied.Encoding, encBase64)
+				m = &copied
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			err := enc.Encode(m)
+			return buf.Bytes(), err
+		}
+	case HistoryExportMsgpack:
+		encodeRecord = func(m *Message) ([]byte, error) {
+			return ablyutil.MarshalMsgpack(m)
+		}
+	default:
+		return newErrorf(ErrBadRequest, "unknown history export format %q", format)
+	}
+
+	// Options are applied in order, so the caller's direction, if any,
+	// overrides this default.
+	request := c.History(append([]HistoryOption{HistoryWithDirection(Forwards)}, o...)...)
+	if opts.rawExport {
+		request.decoder = nil
+	}
+	pages, err := request.pages(ctx, request.decodeInto)
+	if err != nil {
+		return err
+	}
+	var progress HistoryExportProgress
+	for pages.Next(ctx) {
+		for _, m := range pages.Items() {
+			record, err := encodeRecord(m)
+			if err != nil {
+				return fmt.Errorf("encoding message %q: %w", m.ID, err)
+			}
+			n, err := w.Write(record)
+			progress.Bytes += int64(n)
+			if err != nil {
+				return err
+			}
+			progress.Messages++
+			progress.LastTimestamp = m.Timestamp
+		}
+		progress.Pages++
+		progress.Cursor = pages.Cursor()
+		if opts.exportProgress != nil {
+			opts.exportProgress(progress)
+		}
+	}
+	return pages.Err()
+}
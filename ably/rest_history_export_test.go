@@ -0,0 +1,175 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+func TestRESTChannel_ExportHistory(t *testing.T) {
+	wire := []*ably.Message{
+		{ID: "msg:0", Name: "text", Data: "hello", Timestamp: 1000},
+		{ID: "msg:1", Name: "binary", Data: "AQID", Encoding: "base64", Timestamp: 2000},
+		{ID: "msg:2", Name: "object", Data: `{"a":1}`, Encoding: "json", Timestamp: 3000,
+			Extras: map[string]interface{}{"headers": map[string]interface{}{"k": "v"}}},
+	}
+	var queries []string
+	client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/channels/compliance/history", r.URL.Path)
+		queries = append(queries, r.URL.RawQuery)
+		// Paginate by offset, as the start param of history is a timestamp.
+		q := r.URL.Query()
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			limit = 100
+		}
+		end := offset + limit
+		if end >= len(wire) {
+			end = len(wire)
+		} else {
+			q.Set("offset", strconv.Itoa(end))
+			w.Header().Set("Link", `<./history?`+q.Encode()+`>; rel="next"`)
+		}
+		writeJSON(w, wire[offset:end])
+	}))
+	channel := client.Channels.Get("compliance")
+	ctx := context.Background()
+
+	t.Run("NDJSON", func(t *testing.T) {
+		queries = nil
+		var progress []ably.HistoryExportProgress
+		var buf bytes.Buffer
+		err := channel.ExportHistory(ctx, &buf, ably.HistoryExportNDJSON,
+			ably.HistoryWithStart(time.UnixMilli(500)),
+			ably.HistoryWithEnd(time.UnixMilli(5000)),
+			ably.HistoryWithLimit(2),
+			ably.HistoryWithExportProgress(func(p ably.HistoryExportProgress) {
+				progress = append(progress, p)
+			}),
+		)
+		assert.NoError(t, err)
+		written := int64(buf.Len())
+		assert.Contains(t, queries[0], "start=500")
+		assert.Contains(t, queries[0], "end=5000")
+		// Oldest first, so that ImportHistory keeps the original order.
+		assert.Contains(t, queries[0], "direction=forwards")
+
+		var records []map[string]interface{}
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var record map[string]interface{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records = append(records, record)
+		}
+		assert.Len(t, records, 3)
+		assert.Equal(t, "hello", records[0]["data"])
+		assert.Equal(t, "AQID", records[1]["data"])
+		assert.Equal(t, "base64", records[1]["encoding"])
+		assert.Equal(t, map[string]interface{}{"a": float64(1)}, records[2]["data"])
+		assert.Nil(t, records[2]["encoding"])
+		assert.Equal(t, map[string]interface{}{"headers": map[string]interface{}{"k": "v"}}, records[2]["extras"])
+
+		assert.Len(t, progress, 2)
+		assert.Equal(t, 1, progress[0].Pages)
+		assert.Equal(t, 2, progress[0].Messages)
+		assert.Equal(t, int64(2000), progress[0].LastTimestamp)
+		assert.NotEmpty(t, progress[0].Cursor)
+		assert.Equal(t, ably.HistoryExportProgress{
+			Pages:         2,
+			Messages:      3,
+			Bytes:         written,
+			LastTimestamp: 3000,
+		}, progress[1])
+
+		// An interrupted export can be continued from a progress cursor.
+		var rest bytes.Buffer
+		err = channel.ExportHistory(ctx, &rest, ably.HistoryExportNDJSON, ably.HistoryWithCursor(progress[0].Cursor))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, bytes.Count(rest.Bytes(), []byte("\n")))
+		assert.Contains(t, rest.String(), `"id":"msg:2"`)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := channel.ExportHistory(ctx, &buf, ably.HistoryExportNDJSON, ably.HistoryWithRawExport())
+		assert.NoError(t, err)
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		assert.Len(t, lines, 3)
+		var record ably.Message
+		assert.NoError(t, json.Unmarshal(lines[2], &record))
+		assert.Equal(t, `{"a":1}`, record.Data)
+		assert.Equal(t, "json", record.Encoding)
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := channel.ExportHistory(ctx, &buf, ably.HistoryExportMsgpack)
+		assert.NoError(t, err)
+
+		// Binary data is written as msgpack bin.
+		assert.True(t, bytes.Contains(buf.Bytes(), []byte{0xc4, 3, 1, 2, 3}))
+
+		var handle codec.MsgpackHandle
+		handle.RawToString = true
+		handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+		dec := codec.NewDecoder(&buf, &handle)
+		var records []map[string]interface{}
+		for {
+			var record map[string]interface{}
+			err := dec.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			records = append(records, record)
+		}
+		assert.Len(t, records, 3)
+		assert.Equal(t, "hello", records[0]["data"])
+		assert.Equal(t, "\x01\x02\x03", records[1]["data"])
+		assert.Equal(t, "msg:2", records[2]["id"])
+	})
+
+	t.Run("direction", func(t *testing.T) {
+		queries = nil
+		err := channel.ExportHistory(ctx, io.Discard, ably.HistoryExportNDJSON,
+			ably.HistoryWithDirection(ably.Backwards))
+		assert.NoError(t, err)
+		assert.Contains(t, queries[0], "direction=backwards")
+		assert.NotContains(t, queries[0], "direction=forwards")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		err := channel.ExportHistory(ctx, io.Discard, "csv")
+		assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		err := channel.ExportHistory(ctx, failingWriter{}, ably.HistoryExportNDJSON)
+		assert.ErrorIs(t, err, errWriteFailed)
+	})
+}
+
+var errWriteFailed = errors.New("disk full")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWriteFailed
+}
@@ -0,0 +1,241 @@
+package ably
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ably/ably-go/ably/internal/ablyutil"
+)
+
+// A HistoryImportOption configures a call to [ably.RESTChannel.ImportHistory].
+type HistoryImportOption func(*historyImportOptions)
+
+// HistoryImportWithBatchSize sets the maximum number of messages published
+// per request. The default is 100.
+func HistoryImportWithBatchSize(size int) HistoryImportOption {
+	return func(o *historyImportOptions) {
+		o.batchSize = size
+	}
+}
+
+// HistoryImportWithRateLimit limits the rate at which messages are published,
+// in messages per second. By default there's no limit.
+func HistoryImportWithRateLimit(messagesPerSecond float64) HistoryImportOption {
+	return func(o *historyImportOptions) {
+		o.rate = messagesPerSecond
+	}
+}
+
+// HistoryImportWithReplaySpeed replays the messages keeping the original gaps
+// between their timestamps, scaled by speed: 1 replays them in real time, 2
+// twice as fast, and so on. By default, messages are published as fast as
+// possible.
+//
+// Records must be in chronological order, as [ably.RESTChannel.ExportHistory]
+// writes them by default; a message older than the one before it is published
+// without delay.
+func HistoryImportWithReplaySpeed(speed float64) HistoryImportOption {
+	return func(o *historyImportOptions) {
+		o.replaySpeed = speed
+	}
+}
+
+// HistoryImportWithoutIDs publishes the messages without their original IDs,
+// so that Ably doesn't discard them as duplicates of the original ones, e.g.
+// when replaying into the same app within the idempotency window.
+func HistoryImportWithoutIDs() HistoryImportOption {
+	return func(o *historyImportOptions) {
+		o.withoutIDs = true
+	}
+}
+
+// HistoryImportWithProgress sets a function that is called after each batch
+// of messages is published.
+func HistoryImportWithProgress(progress func(HistoryImportProgress)) HistoryImportOption {
+	return func(o *historyImportOptions) {
+		o.progress = progress
+	}
+}
+
+type historyImportOptions struct {
+	batchSize   int
+	rate        float64
+	replaySpeed float64
+	withoutIDs  bool
+	progress    func(HistoryImportProgress)
+}
+
+// HistoryImportProgress reports how far a call to
+// [ably.RESTChannel.ImportHistory] has got.
+type HistoryImportProgress struct {
+	// Batches is the number of requests made so far.
+	Batches int
+	// Messages is the number of messages published so far.
+	Messages int
+	// LastTimestamp is the original timestamp of the last message published,
+	// as milliseconds since the Unix epoch.
+	LastTimestamp int64
+}
+
+// ImportHistory republishes to the channel the messages read from r, in the
+// format written by [ably.RESTChannel.ExportHistory], e.g. to migrate a
+// channel to another app or reproduce an incident. Messages are published in
+// batches with PublishMultiple.
+//
+// The name, data, extras and ID of the messages are kept, so importing the
+// same records twice within Ably's idempotency window publishes them once;
+// see [ably.HistoryImportWithoutIDs]. Other fields, such as the client ID
+// and timestamp, are set by Ably on publishing.
+//
+// Message data is decoded and then encoded again for the channel, so that
+// it's encrypted if the channel has a cipher. Data that's still encrypted, as
+// in an export made with [ably.HistoryWithRawExport], or that otherwise can't
+// be decoded, is published as it was read, with its original encoding: it's
+// neither decrypted with the channel's key nor encrypted again.
+func (c *RESTChannel) ImportHistory(ctx context.Context, r io.Reader, format HistoryExportFormat, o ...HistoryImportOption) error {
+	opts := historyImportOptions{batchSize: 100}
+	for _, opt := range o {
+		opt(&opts)
+	}
+	if opts.batchSize <= 0 {
+		return newErrorf(ErrBadRequest, "invalid history import batch size %d", opts.batchSize)
+	}
+
+	var decodeRecord func(*Message) error
+	switch format {
+	case HistoryExportNDJSON:
+		dec := json.NewDecoder(r)
+		decodeRecord = func(m *Message) error {
+			return dec.Decode(m)
+		}
+	case HistoryExportMsgpack:
+		dec := ablyutil.NewMsgpackDecoder(r)
+		decodeRecord = func(m *Message) error {
+			return dec.Decode(m)
+		}
+	default:
+		return newErrorf(ErrBadRequest, "unknown history import format %q", format)
+	}
+
+	cipher, err := c.options.configuredCipher()
+	if err != nil {
+		return err
+	}
+
+	var progress HistoryImportProgress
+	var batch []*Message
+	var nextPublish time.Time // when the rate limit allows the next batch
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if opts.rate > 0 {
+			if err := c.sleepUntil(ctx, nextPublish); err != nil {
+				return err
+			}
+			if now := c.client.opts.Now(); nextPublish.Before(now) {
+				nextPublish = now
+			}
+			nextPublish = nextPublish.Add(time.Duration(float64(len(batch)) / opts.rate * float64(time.Second)))
+		}
+		lastTimestamp := batch[len(batch)-1].Timestamp
+		for _, m := range batch {
+			m.Timestamp = 0
+		}
+		if err := c.publishEncoded(ctx, batch, publishMultipleOptions{}); err != nil {
+			return fmt.Errorf("publishing messages #%d to #%d: %w", progress.Messages, progress.Messages+len(batch)-1, err)
+		}
+		progress.Batches++
+		progress.Messages += len(batch)
+		progress.LastTimestamp = lastTimestamp
+		batch = nil
+		if opts.progress != nil {
+			opts.progress(progress)
+		}
+		return nil
+	}
+
+	var replayStart time.Time
+	var firstTimestamp int64
+	for i := 0; ; i++ {
+		var record Message
+		err := decodeRecord(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading record #%d: %w", i, err)
+		}
+
+		if opts.replaySpeed > 0 {
+			if i == 0 {
+				replayStart, firstTimestamp = c.client.opts.Now(), record.Timestamp
+			}
+			offset := time.Duration(float64(record.Timestamp-firstTimestamp) * float64(time.Millisecond) / opts.replaySpeed)
+			if due := replayStart.Add(offset); due.After(c.client.opts.Now()) {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := c.sleepUntil(ctx, due); err != nil {
+					return err
+				}
+			}
+		}
+
+		m, err := importedMessage(record, cipher, opts.withoutIDs)
+		if err != nil {
+			return fmt.Errorf("encoding record #%d: %w", i, err)
+		}
+		batch = append(batch, m)
+		if len(batch) == opts.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// importedMessage returns the message to publish for a record read by
+// ImportHistory, with its data encoded for the channel. Its Timestamp is kept
+// only for scheduling; it's cleared before publishing.
+func importedMessage(record Message, cipher channelCipher, withoutIDs bool) (*Message, error) {
+	// Encrypted data fails to decode without a cipher, so it's published as
+	// it was read. Decrypting it with the channel's cipher could yield
+	// garbage, since CBC can't tell a wrong key, and encrypting it again
+	// would make it unreadable.
+	if decoded, err := record.withDecodedData(nil); err == nil {
+		record, err = decoded.withEncodedData(cipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m := &Message{
+		ID:        record.ID,
+		Name:      record.Name,
+		Data:      record.Data,
+		Encoding:  record.Encoding,
+		Extras:    record.Extras,
+		Timestamp: record.Timestamp,
+	}
+	if withoutIDs {
+		m.ID = ""
+	}
+	return m, nil
+}
+
+// sleepUntil blocks until t, or until ctx is done.
+func (c *RESTChannel) sleepUntil(ctx context.Context, t time.Time) error {
+	d := t.Sub(c.client.opts.Now())
+	if d <= 0 {
+		return nil
+	}
+	if _, ok := <-c.client.opts.After(ctx, d); !ok {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// publishRecorder records the messages published to a channel over REST.
+type publishRecorder struct {
+	mtx     sync.Mutex
+	batches [][]map[string]interface{}
+}
+
+func (p *publishRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var batch []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.mtx.Lock()
+	p.batches = append(p.batches, batch)
+	p.mtx.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+// fakeSleeper is a clock whose timers fire immediately, moving the clock
+// forward by their duration.
+type fakeSleeper struct {
+	mtx    sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeSleeper) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *fakeSleeper) After(ctx context.Context, d time.Duration) <-chan time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestRESTChannel_ImportHistory(t *testing.T) {
+	const records = `{"id":"orig:0","name":"a","data":"zero","timestamp":1000,"clientId":"someone"}
+{"id":"orig:1","name":"b","data":{"n":1},"timestamp":1100,"extras":{"headers":{"k":"v"}}}
+{"id":"orig:2","name":"c","data":"AQID","encoding":"base64","timestamp":1100}
+{"id":"orig:3","name":"d","data":"three","timestamp":1500}
+{"id":"orig:4","name":"e","data":"four","timestamp":3000}
+`
+	newClient := func(t *testing.T) (*ably.RESTChannel, *publishRecorder, *fakeSleeper) {
+		recorder := &publishRecorder{}
+		clock := &fakeSleeper{now: time.Unix(0, 0)}
+		client := newHTTPTestREST(t, recorder, ably.WithNow(clock.Now), ably.WithAfter(clock.After))
+		return client.Channels.Get("replay"), recorder, clock
+	}
+	ctx := context.Background()
+
+	t.Run("batches and preserves messages", func(t *testing.T) {
+		channel, recorder, clock := newClient(t)
+		var progress []ably.HistoryImportProgress
+		err := channel.ImportHistory(ctx, strings.NewReader(records), ably.HistoryExportNDJSON,
+			ably.HistoryImportWithBatchSize(2),
+			ably.HistoryImportWithProgress(func(p ably.HistoryImportProgress) {
+				progress = append(progress, p)
+			}),
+		)
+		assert.NoError(t, err)
+		assert.Empty(t, clock.sleeps)
+
+		assert.Len(t, recorder.batches, 3)
+		first := recorder.batches[0]
+		assert.Equal(t, map[string]interface{}{"id": "orig:0", "name": "a", "data": "zero"}, first[0])
+		assert.Equal(t, map[string]interface{}{
+			"id":       "orig:1",
+			"name":     "b",
+			"data":     `{"n":1}`,
+			"encoding": "json",
+			"extras":   map[string]interface{}{"headers": map[string]interface{}{"k": "v"}},
+		}, first[1])
+		assert.Equal(t, "AQID", recorder.batches[1][0]["data"])
+		assert.Equal(t, "base64", recorder.batches[1][0]["encoding"])
+		assert.Equal(t, "orig:4", recorder.batches[2][0]["id"])
+
+		assert.Equal(t, []ably.HistoryImportProgress{
+			{Batches: 1, Messages: 2, LastTimestamp: 1100},
+			{Batches: 2, Messages: 4, LastTimestamp: 1500},
+			{Batches: 3, Messages: 5, LastTimestamp: 3000},
+		}, progress)
+	})
+
+	t.Run("time-scaled replay", func(t *testing.T) {
+		channel, recorder, clock := newClient(t)
+		err := channel.ImportHistory(ctx, strings.NewReader(records), ably.HistoryExportNDJSON,
+			ably.HistoryImportWithReplaySpeed(2),
+		)
+		assert.NoError(t, err)
+		// Messages with the same timestamp are published together; gaps are
+		// halved.
+		assert.Equal(t, []time.Duration{50 * time.Millisecond, 200 * time.Millisecond, 750 * time.Millisecond}, clock.sleeps)
+		var sizes []int
+		for _, b := range recorder.batches {
+			sizes = append(sizes, len(b))
+		}
+		assert.Equal(t, []int{1, 2, 1, 1}, sizes)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		channel, recorder, clock := newClient(t)
+		err := channel.ImportHistory(ctx, strings.NewReader(records), ably.HistoryExportNDJSON,
+			ably.HistoryImportWithBatchSize(2),
+			ably.HistoryImportWithRateLimit(4),
+		)
+		assert.NoError(t, err)
+		assert.Len(t, recorder.batches, 3)
+		assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, clock.sleeps)
+	})
+
+	t.Run("without IDs", func(t *testing.T) {
+		channel, recorder, _ := newClient(t)
+		err := channel.ImportHistory(ctx, strings.NewReader(records), ably.HistoryExportNDJSON, ably.HistoryImportWithoutIDs())
+		assert.NoError(t, err)
+		for _, m := range recorder.batches[0] {
+			assert.NotContains(t, m["id"], "orig:")
+		}
+	})
+
+	t.Run("round trip through msgpack export", func(t *testing.T) {
+		source := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []*ably.Message{
+				{ID: "orig:0", Name: "a", Data: "zero", Timestamp: 1000},
+				{ID: "orig:1", Name: "b", Data: `{"n":1}`, Encoding: "json", Timestamp: 2000},
+			})
+		}))
+		var export bytes.Buffer
+		assert.NoError(t, source.Channels.Get("source").ExportHistory(ctx, &export, ably.HistoryExportMsgpack))
+
+		channel, recorder, _ := newClient(t)
+		assert.NoError(t, channel.ImportHistory(ctx, &export, ably.HistoryExportMsgpack))
+		assert.Equal(t, [][]map[string]interface{}{{
+			{"id": "orig:0", "name": "a", "data": "zero"},
+			{"id": "orig:1", "name": "b", "data": `{"n":1}`, "encoding": "json"},
+		}}, recorder.batches)
+	})
+
+	t.Run("raw encrypted export", func(t *testing.T) {
+		sourceKey, err := ably.Crypto.GenerateRandomKey(128)
+		assert.NoError(t, err)
+		sourceCipher, err := (&ably.ProtoChannelOptions{
+			Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: sourceKey}),
+		}).GetCipher()
+		assert.NoError(t, err)
+		encrypted, err := ably.MessageWithEncodedData(ably.Message{ID: "orig:0", Name: "a", Data: "secret", Timestamp: 1000}, sourceCipher)
+		assert.NoError(t, err)
+		source := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []*ably.Message{
+				&encrypted,
+				{ID: "orig:1", Name: "b", Data: "plain", Timestamp: 2000},
+			})
+		}))
+		var export bytes.Buffer
+		err = source.Channels.Get("source").ExportHistory(ctx, &export, ably.HistoryExportNDJSON, ably.HistoryWithRawExport())
+		assert.NoError(t, err)
+
+		// The destination channel has a key of its own, so it can't decrypt
+		// the exported data.
+		destKey, err := ably.Crypto.GenerateRandomKey(128)
+		assert.NoError(t, err)
+		recorder := &publishRecorder{}
+		client := newHTTPTestREST(t, recorder)
+		channel := client.Channels.Get("replay", ably.ChannelWithCipherKey(destKey))
+		assert.NoError(t, channel.ImportHistory(ctx, &export, ably.HistoryExportNDJSON))
+
+		assert.Len(t, recorder.batches, 1)
+		batch := recorder.batches[0]
+		assert.Equal(t, encrypted.Encoding, batch[0]["encoding"], "expected undecodable data not to be encoded again")
+		assert.Equal(t, encrypted.Data, batch[0]["data"])
+		decrypted, err := ably.MessageWithDecodedData(ably.Message{
+			Data:     batch[0]["data"],
+			Encoding: batch[0]["encoding"].(string),
+		}, sourceCipher)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", decrypted.Data)
+
+		// Data that could be decoded is encrypted for the channel.
+		assert.Equal(t, "utf-8/cipher+aes-128-cbc/base64", batch[1]["encoding"])
+	})
+
+	t.Run("invalid records", func(t *testing.T) {
+		channel, _, _ := newClient(t)
+		err := channel.ImportHistory(ctx, strings.NewReader(`{"id":"x"}`+"\nnot json\n"), ably.HistoryExportNDJSON)
+		assert.ErrorContains(t, err, "reading record #1")
+	})
+}
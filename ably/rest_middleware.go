@@ -0,0 +1,142 @@
+package ably
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// RESTAttempt describes an attempt at sending a request to the Ably REST API.
+// A single call to a REST method may take several attempts: the request is
+// retried against fallback hosts when the primary host fails (RSC15), and
+// after renewing an expired token (RSC10).
+type RESTAttempt struct {
+	// Method is the HTTP method, e.g. "POST".
+	Method string
+	// Path is the path of the endpoint, including any query string, e.g.
+	// "/channels/foo/messages".
+	Path string
+	// Body is the encoded request body, or nil if it has none. It must not be
+	// modified.
+	Body []byte
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+	// Host is the host the request is sent to.
+	Host string
+	// Fallback is true if Host is a fallback host.
+	Fallback bool
+	// Header is the HTTP header of the request, including its authorization.
+	// Middleware may add to it, e.g. to sign the request. Each attempt has its
+	// own copy, so changes don't carry over to later attempts.
+	Header http.Header
+
+	req *http.Request
+}
+
+// RESTHandler sends an attempt at a REST request, returning the response from
+// Ably once it's been checked and, if the request expects a result, decoded.
+//
+// On error, the response is returned along with it if Ably responded, e.g.
+// with a 5xx status code; its body has already been consumed by then. The
+// error is an [ably.ErrorInfo] if Ably responded with an error.
+//
+// A handler may be called more than once for the same attempt, e.g. by a
+// middleware implementing its own retries.
+type RESTHandler func(ctx context.Context, attempt *RESTAttempt) (*http.Response, error)
+
+// WithRESTMiddleware adds a middleware wrapping the sending of each attempt
+// at a REST request, by returning a handler that is called instead of the
+// next one. This allows e.g. auditing, collecting metrics or signing requests,
+// with full knowledge of the request, while the client still takes care of
+// authentication and falling back to other hosts.
+//
+// The option can be passed several times; the first middleware passed is the
+// outermost.
+//
+//	ably.WithRESTMiddleware(func(next ably.RESTHandler) ably.RESTHandler {
+//		return func(ctx context.Context, a *ably.RESTAttempt) (*http.Response, error) {
+//			start := time.Now()
+//			resp, err := next(ctx, a)
+//			log.Printf("%s %s via %s (attempt %d): %v in %v", a.Method, a.Path, a.Host, a.Attempt, err, time.Since(start))
+//			return resp, err
+//		}
+//	})
+func WithRESTMiddleware(middleware func(next RESTHandler) RESTHandler) ClientOption {
+	return func(os *clientOptions) {
+		os.RESTMiddleware = append(os.RESTMiddleware, middleware)
+	}
+}
+
+// send makes an attempt at performing r by sending req through the
+// configured middleware.
+func (c *REST) send(r *request, req *http.Request, fallback bool, handle func(*http.Response, interface{}) (*http.Response, error)) (*http.Response, error) {
+	r.attempts++
+	attempt := &RESTAttempt{
+		Method:   r.Method,
+		Path:     r.Path,
+		Attempt:  r.attempts,
+		Host:     req.URL.Host,
+		Fallback: fallback,
+		Header:   req.Header.Clone(),
+		req:      req,
+	}
+	handler := func(ctx context.Context, a *RESTAttempt) (*http.Response, error) {
+		req := a.req.Clone(ctx)
+		req.Header = a.Header
+		if a.Body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(a.Body))
+		}
+		resp, err := c.opts.httpclient().Do(req)
+		if err != nil {
+			log := c.log.with(LogAttr{Key: LogKeyHost, Value: a.Host})
+			if a.Fallback {
+				log.Error("RestClient: failed sending a request to a fallback host", err)
+			} else {
+				log.Error("RestClient: failed sending a request ", err)
+			}
+			return nil, err
+		}
+		if req.ContentLength > 0 {
+			c.opts.metrics().BytesSent(int(req.ContentLength))
+		}
+		if resp.ContentLength > 0 {
+			c.opts.metrics().BytesReceived(int(resp.ContentLength))
+		}
+		handled, err := handle(resp, r.Out)
+		if err != nil {
+			return resp, err
+		}
+		return handled, nil
+	}
+
+	if len(c.opts.RESTMiddleware) > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, newError(ErrInternalError, err)
+		}
+		attempt.Body, err = io.ReadAll(body)
+		if err != nil {
+			return nil, newError(ErrInternalError, err)
+		}
+	}
+	for i := len(c.opts.RESTMiddleware) - 1; i >= 0; i-- {
+		handler = c.opts.RESTMiddleware[i](handler)
+	}
+	ctx, span := c.opts.tracer().start(req.Context(), SpanRESTAttempt,
+		attr(attrHTTPMethod, r.Method),
+		attr(attrURLPath, r.Path),
+		attr(attrServerAddress, attempt.Host),
+		attr(attrAttempt, attempt.Attempt),
+		attr(attrFallback, fallback),
+	)
+	resp, err := handler(ctx, attempt)
+	if resp != nil {
+		span.setAttributes(attr(attrHTTPStatusCode, resp.StatusCode))
+	}
+	span.end(err)
+	if err != nil {
+		r.lastResponse = resp
+	}
+	return resp, err
+}
@@ -0,0 +1,157 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRESTMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sees every attempt, including fallbacks", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.Host, "localhost:") {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+		serverURL, _ := url.Parse(server.URL)
+		port, _ := strconv.Atoi(serverURL.Port())
+
+		type seen struct {
+			attempt ably.RESTAttempt
+			status  int
+			err     error
+		}
+		var attempts []seen
+		client, err := ably.NewREST(
+			ably.WithToken("fake:token"),
+			ably.WithTLS(false),
+			ably.WithUseBinaryProtocol(false),
+			ably.WithRESTHost("localhost"),
+			ably.WithPort(port),
+			ably.WithFallbackHosts([]string{net.JoinHostPort("127.0.0.1", serverURL.Port())}),
+			ably.WithRESTMiddleware(func(next ably.RESTHandler) ably.RESTHandler {
+				return func(ctx context.Context, a *ably.RESTAttempt) (*http.Response, error) {
+					a.Header.Add("X-Attempt", strconv.Itoa(a.Attempt))
+					resp, err := next(ctx, a)
+					s := seen{attempt: *a, err: err}
+					if resp != nil {
+						s.status = resp.StatusCode
+					}
+					attempts = append(attempts, s)
+					return resp, err
+				}
+			}),
+		)
+		assert.NoError(t, err)
+
+		err = client.Channels.Get("audited").Publish(ctx, "event", "data")
+		assert.NoError(t, err)
+
+		assert.Len(t, attempts, 2)
+		first, second := attempts[0], attempts[1]
+		assert.Equal(t, "POST", first.attempt.Method)
+		assert.Equal(t, "/channels/audited/messages", first.attempt.Path)
+		assert.Contains(t, string(first.attempt.Body), `"name":"event"`)
+		assert.Equal(t, 1, first.attempt.Attempt)
+		assert.Equal(t, "localhost:"+serverURL.Port(), first.attempt.Host)
+		assert.False(t, first.attempt.Fallback)
+		assert.Equal(t, http.StatusServiceUnavailable, first.status)
+		assert.Error(t, first.err)
+
+		assert.Equal(t, 2, second.attempt.Attempt)
+		assert.Equal(t, serverURL.Host, second.attempt.Host)
+		assert.True(t, second.attempt.Fallback)
+		assert.Equal(t, first.attempt.Body, second.attempt.Body)
+		// Headers set for an attempt don't leak into the next one.
+		assert.Equal(t, []string{"1"}, first.attempt.Header.Values("X-Attempt"))
+		assert.Equal(t, []string{"2"}, second.attempt.Header.Values("X-Attempt"))
+		assert.Equal(t, http.StatusCreated, second.status)
+		assert.NoError(t, second.err)
+	})
+
+	t.Run("can sign requests", func(t *testing.T) {
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Signature") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}), ably.WithRESTMiddleware(func(next ably.RESTHandler) ably.RESTHandler {
+			return func(ctx context.Context, a *ably.RESTAttempt) (*http.Response, error) {
+				sum := sha256.Sum256(a.Body)
+				a.Header.Set("X-Signature", hex.EncodeToString(sum[:]))
+				return next(ctx, a)
+			}
+		}))
+
+		err := client.Channels.Get("signed").Publish(ctx, "event", "data")
+		assert.NoError(t, err)
+	})
+
+	t.Run("can retry", func(t *testing.T) {
+		var requests int32
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Contains(t, string(body), `"name":"event"`)
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}), ably.WithRESTMiddleware(func(next ably.RESTHandler) ably.RESTHandler {
+			return func(ctx context.Context, a *ably.RESTAttempt) (*http.Response, error) {
+				resp, err := next(ctx, a)
+				if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+					return next(ctx, a)
+				}
+				return resp, err
+			}
+		}))
+
+		err := client.Channels.Get("retried").Publish(ctx, "event", "data")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("middleware order", func(t *testing.T) {
+		var order []string
+		named := func(name string) func(ably.RESTHandler) ably.RESTHandler {
+			return func(next ably.RESTHandler) ably.RESTHandler {
+				return func(ctx context.Context, a *ably.RESTAttempt) (*http.Response, error) {
+					order = append(order, name)
+					return next(ctx, a)
+				}
+			}
+		}
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []int64{1700000000000})
+		}), ably.WithRESTMiddleware(named("outer")), ably.WithRESTMiddleware(named("inner")))
+
+		_, err := client.Time(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"outer", "inner"}, order)
+	})
+}
@@ -1,7 +1,6 @@
 package ably
 
 import (
-	"context"
 	"encoding/json"
 	"net/url"
 	"strconv"
@@ -24,8 +23,10 @@ type RESTPresence struct {
 func (c *RESTPresence) Get(o ...GetPresenceOption) PresenceRequest {
 	params := (&getPresenceOptions{}).apply(o...)
 	return PresenceRequest{
-		r:       c.client.newPaginatedRequest("/channels/"+c.channel.Name+"/presence", "/channels/"+c.channel.pathName()+"/presence", params),
-		channel: c.channel,
+		Paginated: newPaginated(
+			c.client.newPaginatedRequest("/channels/"+c.channel.Name+"/presence", "/channels/"+c.channel.pathName()+"/presence", params),
+			c.channel.fullPresenceDecoder,
+		),
 	}
 }
 
@@ -68,7 +69,7 @@ func (o *getPresenceOptions) apply(opts ...GetPresenceOption) url.Values {
 }
 
 func (p *RESTPresence) log() logger {
-	return p.client.log
+	return p.channel.log()
 }
 
 // History retrieves a [ably.PaginatedResult] object, containing an array of historical [ably.PresenceMessage]
@@ -78,10 +79,14 @@ func (p *RESTPresence) log() logger {
 //
 // See package-level documentation => [ably] Pagination for details about history pagination.
 func (c *RESTPresence) History(o ...PresenceHistoryOption) PresenceRequest {
-	params := (&presenceHistoryOptions{}).apply(o...)
+	var opts presenceHistoryOptions
+	params := opts.apply(o...)
+	r := c.client.newPaginatedRequest("/channels/"+c.channel.Name+"/presence/history", "/channels/"+c.channel.pathName()+"/presence/history", params)
+	if opts.cursor != "" {
+		r = r.fromCursor(opts.cursor)
+	}
 	return PresenceRequest{
-		r:       c.client.newPaginatedRequest("/channels/"+c.channel.Name+"/presence/history", "/channels/"+c.channel.pathName()+"/presence/history", params),
-		channel: c.channel,
+		Paginated: newPaginated(r, c.channel.fullPresenceDecoder),
 	}
 }
 
@@ -122,8 +127,19 @@ func PresenceHistoryWithDirection(d Direction) PresenceHistoryOption {
 	}
 }
 
+// PresenceHistoryWithCursor resumes reading the presence history from the page
+// cursor points to, as returned by the Cursor method of a previous result for
+// the same channel. Other options are ignored, as the cursor already holds
+// the parameters of the original request.
+func PresenceHistoryWithCursor(cursor string) PresenceHistoryOption {
+	return func(o *presenceHistoryOptions) {
+		o.cursor = cursor
+	}
+}
+
 type presenceHistoryOptions struct {
 	params url.Values
+	cursor string
 }
 
 func (o *presenceHistoryOptions) apply(opts ...PresenceHistoryOption) url.Values {
@@ -136,68 +152,22 @@ func (o *presenceHistoryOptions) apply(opts ...PresenceHistoryOption) url.Values
 
 // PresenceRequest represents a request prepared by the RESTPresence.History or
 // RealtimePresence.History method, ready to be performed by its Pages or Items methods.
-type PresenceRequest struct {
-	r       paginatedRequest
-	channel *RESTChannel
-}
-
-// Pages returns an iterator for whole pages of presence messages.
 //
 // See package-level documentation => [ably] Pagination for more details.
-func (r PresenceRequest) Pages(ctx context.Context) (*PresencePaginatedResult, error) {
-	res := PresencePaginatedResult{decoder: r.channel.fullPresenceDecoder}
-	return &res, res.load(ctx, r.r)
+type PresenceRequest struct {
+	Paginated[*PresenceMessage]
 }
 
 // A PresencePaginatedResult is an iterator for the result of a PresenceHistory request.
 //
 // See package-level documentation => [ably] Pagination for more details.
-type PresencePaginatedResult struct {
-	PaginatedResult
-	items   []*PresenceMessage
-	decoder func(*[]*PresenceMessage) interface{}
-}
-
-// Next retrieves the next page of results.
-//
-// See package-level documentation => [ably] Pagination for more details.
-func (p *PresencePaginatedResult) Next(ctx context.Context) bool {
-	p.items = nil // avoid mutating already returned items
-	return p.next(ctx, p.decoder(&p.items))
-}
-
-// IsLast returns true if the page is last page.
-//
-// See package-level documentation => [ably] Pagination for more details.
-func (p *PresencePaginatedResult) IsLast(ctx context.Context) bool {
-	return !p.HasNext(ctx)
-}
-
-func (p *PresencePaginatedResult) HasNext(ctx context.Context) bool {
-	return p.nextLink != ""
-}
-
-// Items returns the current page of results.
-//
-// See package-level documentation => [ably] Pagination for more details.
-func (p *PresencePaginatedResult) Items() []*PresenceMessage {
-	return p.items
-}
+type PresencePaginatedResult = PaginatedPages[*PresenceMessage]
 
-// Items returns a convenience iterator for single PresenceHistory, over an underlying
-// paginated iterator.
+// A PresencePaginatedItems is an iterator for single presence messages, over
+// an underlying paginated iterator.
 //
 // See package-level documentation => [ably] Pagination for more details.
-func (r PresenceRequest) Items(ctx context.Context) (*PresencePaginatedItems, error) {
-	var res PresencePaginatedItems
-	var err error
-	res.next, err = res.loadItems(ctx, r.r, func() (interface{}, func() int) {
-		return r.channel.fullPresenceDecoder(&res.items), func() int {
-			return len(res.items)
-		}
-	})
-	return &res, err
-}
+type PresencePaginatedItems = PaginatedItems[*PresenceMessage]
 
 // fullPresenceDecoder wraps a destination slice of messages in a decoder value
 // that decodes both the message itself from the transport-level encoding and
@@ -241,33 +211,7 @@ func (t *fullPresenceDecoder) decodeMessagesData() {
 		m.Message, err = m.Message.withDecodedData(cipher)
 		if err != nil {
 			// RSL6b
-			t.c.log().Errorf("Couldn't fully decode presence message data from channel %q: %w", t.c.Name, err)
+			t.c.log().Errorf("Couldn't fully decode presence message data from channel %q: %v", t.c.Name, err)
 		}
 	}
 }
-
-type PresencePaginatedItems struct {
-	PaginatedResult
-	items []*PresenceMessage
-	item  *PresenceMessage
-	next  func(context.Context) (int, bool)
-}
-
-// Next retrieves the next result.
-//
-// See package-level documentation => [ably] Pagination for more details.
-func (p *PresencePaginatedItems) Next(ctx context.Context) bool {
-	i, ok := p.next(ctx)
-	if !ok {
-		return false
-	}
-	p.item = p.items[i]
-	return true
-}
-
-// Item returns the current result.
-//
-// See package-level documentation => [ably] Pagination for more details.
-func (p *PresencePaginatedItems) Item() *PresenceMessage {
-	return p.item
-}
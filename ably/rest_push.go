@@ -0,0 +1,503 @@
+package ably
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/ugorji/go/codec"
+)
+
+// DevicePlatform is the platform of a device registered for push notifications (PCD6).
+type DevicePlatform string
+
+const (
+	DevicePlatformAndroid DevicePlatform = "android"
+	DevicePlatformIOS     DevicePlatform = "ios"
+	DevicePlatformBrowser DevicePlatform = "browser"
+)
+
+// DeviceFormFactor is the form factor of a device registered for push notifications (PCD4).
+type DeviceFormFactor string
+
+const (
+	DeviceFormFactorPhone    DeviceFormFactor = "phone"
+	DeviceFormFactorTablet   DeviceFormFactor = "tablet"
+	DeviceFormFactorDesktop  DeviceFormFactor = "desktop"
+	DeviceFormFactorTV       DeviceFormFactor = "tv"
+	DeviceFormFactorWatch    DeviceFormFactor = "watch"
+	DeviceFormFactorCar      DeviceFormFactor = "car"
+	DeviceFormFactorEmbedded DeviceFormFactor = "embedded"
+	DeviceFormFactorOther    DeviceFormFactor = "other"
+)
+
+// DevicePushState is the state of a device's push registration (PCP3).
+type DevicePushState string
+
+const (
+	DevicePushStateActive  DevicePushState = "ACTIVE"
+	DevicePushStateFailing DevicePushState = "FAILING"
+	DevicePushStateFailed  DevicePushState = "FAILED"
+)
+
+// DeviceDetails contains the properties of a device registered for receiving
+// push notifications (PCD1).
+type DeviceDetails struct {
+	// ID is a unique identifier for the device generated by the device itself (PCD2).
+	ID string `json:"id" codec:"id"`
+	// ClientID is an optional trusted client identifier for the device (PCD3).
+	ClientID string `json:"clientId,omitempty" codec:"clientId,omitempty"`
+	// FormFactor is the device's form factor (PCD4).
+	FormFactor DeviceFormFactor `json:"formFactor" codec:"formFactor"`
+	// Metadata is a JSON object of key-value pairs that contains metadata for the device (PCD5).
+	Metadata map[string]interface{} `json:"metadata,omitempty" codec:"metadata,omitempty"`
+	// Platform is the device's platform (PCD6).
+	Platform DevicePlatform `json:"platform" codec:"platform"`
+	// Push holds the push registration details of the device (PCD7).
+	Push DevicePushDetails `json:"push" codec:"push"`
+	// DeviceSecret is a unique device secret generated by the Ably SDK (PCD8).
+	DeviceSecret string `json:"deviceSecret,omitempty" codec:"deviceSecret,omitempty"`
+}
+
+// DevicePushDetails contains the push registration details of a device (PCP1).
+type DevicePushDetails struct {
+	// Recipient is a JSON object of key-value pairs that contains the push
+	// transport and address, e.g. {"transportType": "fcm", "registrationToken": "..."} (PCP2).
+	Recipient map[string]interface{} `json:"recipient" codec:"recipient"`
+	// State is the current state of the push registration (PCP3).
+	State DevicePushState `json:"state,omitempty" codec:"state,omitempty"`
+	// ErrorReason describes the last error that occurred when pushing to the device, if any (PCP4).
+	ErrorReason *ErrorInfo `json:"errorReason,omitempty" codec:"errorReason,omitempty"`
+}
+
+// devicePushDetailsWire is DevicePushDetails as sent by Ably, with its error
+// reason in the wire format of errors.
+type devicePushDetailsWire struct {
+	Recipient   map[string]interface{} `json:"recipient" codec:"recipient"`
+	State       DevicePushState        `json:"state,omitempty" codec:"state,omitempty"`
+	ErrorReason *errorInfo             `json:"errorReason,omitempty" codec:"errorReason,omitempty"`
+}
+
+func (r DevicePushDetails) toWire() devicePushDetailsWire {
+	return devicePushDetailsWire{
+		Recipient:   r.Recipient,
+		State:       r.State,
+		ErrorReason: r.ErrorReason.toProto(),
+	}
+}
+
+func (r *DevicePushDetails) fromWire(w devicePushDetailsWire) {
+	*r = DevicePushDetails{
+		Recipient:   w.Recipient,
+		State:       w.State,
+		ErrorReason: newErrorFromProto(w.ErrorReason),
+	}
+}
+
+func (r DevicePushDetails) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toWire())
+}
+
+func (r *DevicePushDetails) UnmarshalJSON(js []byte) error {
+	var w devicePushDetailsWire
+	if err := json.Unmarshal(js, &w); err != nil {
+		return err
+	}
+	r.fromWire(w)
+	return nil
+}
+
+func (r DevicePushDetails) CodecEncodeSelf(encoder *codec.Encoder) {
+	encoder.MustEncode(r.toWire())
+}
+
+func (r *DevicePushDetails) CodecDecodeSelf(decoder *codec.Decoder) {
+	var w devicePushDetailsWire
+	decoder.MustDecode(&w)
+	r.fromWire(w)
+}
+
+// PushChannelSubscription is a subscription of a device, or of all devices
+// associated with a client ID, to the push notifications published on a
+// channel (PCS1).
+type PushChannelSubscription struct {
+	// Channel is the channel the push notification subscription is for (PCS4).
+	Channel string `json:"channel" codec:"channel"`
+	// DeviceID is the device the subscription is for. Mutually exclusive with ClientID (PCS2).
+	DeviceID string `json:"deviceId,omitempty" codec:"deviceId,omitempty"`
+	// ClientID is the client whose devices the subscription is for. Mutually exclusive with DeviceID (PCS3).
+	ClientID string `json:"clientId,omitempty" codec:"clientId,omitempty"`
+}
+
+// Push enables a device to be registered and deregistered from receiving push
+// notifications (RSH1).
+type Push struct {
+	// Admin is a [ably.PushAdmin] object (RSH1).
+	Admin *PushAdmin
+}
+
+// PushAdmin enables the management of device registrations and push
+// notification subscriptions, and the publishing of push notifications
+// directly to devices. It requires the push-admin capability (RSH1).
+type PushAdmin struct {
+	// DeviceRegistrations is a [ably.PushDeviceRegistrations] object (RSH1b).
+	DeviceRegistrations *PushDeviceRegistrations
+	// ChannelSubscriptions is a [ably.PushChannelSubscriptions] object (RSH1c).
+	ChannelSubscriptions *PushChannelSubscriptions
+
+	client *REST
+}
+
+func newPush(client *REST) *Push {
+	return &Push{
+		Admin: &PushAdmin{
+			DeviceRegistrations:  &PushDeviceRegistrations{client: client},
+			ChannelSubscriptions: &PushChannelSubscriptions{client: client},
+			client:               client,
+		},
+	}
+}
+
+// Publish sends a push notification directly to a device or a group of
+// devices sharing the same client ID (RSH1a).
+//
+// recipient identifies the target, e.g. {"clientId": "bob"}, {"deviceId": "..."}
+// or a transport-specific address such as
+// {"transportType": "apns", "deviceToken": "..."}. payload is the push
+// payload, typically with "notification" and "data" fields.
+func (a *PushAdmin) Publish(ctx context.Context, recipient map[string]interface{}, payload map[string]interface{}) error {
+	if len(recipient) == 0 {
+		return newError(ErrBadRequest, errors.New("push recipient must be provided"))
+	}
+	if len(payload) == 0 {
+		return newError(ErrBadRequest, errors.New("push payload must be provided"))
+	}
+	body := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		body[k] = v
+	}
+	body["recipient"] = recipient
+	_, err := a.client.post(ctx, "/push/publish", body, nil)
+	return err
+}
+
+// A PushListOption configures a call to list or remove device registrations
+// or channel subscriptions.
+type PushListOption func(*pushListOptions)
+
+// PushWithDeviceID filters results by device ID.
+func PushWithDeviceID(deviceID string) PushListOption {
+	return func(o *pushListOptions) {
+		o.params.Set("deviceId", deviceID)
+	}
+}
+
+// PushWithClientID filters results by client ID.
+func PushWithClientID(clientID string) PushListOption {
+	return func(o *pushListOptions) {
+		o.params.Set("clientId", clientID)
+	}
+}
+
+// PushWithChannel filters channel subscriptions by channel name.
+func PushWithChannel(channel string) PushListOption {
+	return func(o *pushListOptions) {
+		o.params.Set("channel", channel)
+	}
+}
+
+// PushWithLimit sets an upper limit on the number of results per page. The
+// default is 100 and the maximum is 1000.
+func PushWithLimit(limit int) PushListOption {
+	return func(o *pushListOptions) {
+		o.params.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+type pushListOptions struct {
+	params url.Values
+}
+
+func (o *pushListOptions) apply(opts ...PushListOption) url.Values {
+	o.params = make(url.Values)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o.params
+}
+
+// PushDeviceRegistrations enables the management of push notification
+// registrations with Ably (RSH1b).
+type PushDeviceRegistrations struct {
+	client *REST
+}
+
+var errDeviceIDRequired = errors.New("device ID must be provided")
+
+func deviceRegistrationPath(deviceID string) string {
+	return "/push/deviceRegistrations/" + url.PathEscape(deviceID)
+}
+
+// Save registers a new device or updates an existing one, and returns the
+// registration as stored by Ably (RSH1b3).
+func (r *PushDeviceRegistrations) Save(ctx context.Context, device *DeviceDetails) (*DeviceDetails, error) {
+	if device == nil || device.ID == "" {
+		return nil, newError(ErrBadRequest, errDeviceIDRequired)
+	}
+	var saved DeviceDetails
+	_, err := r.client.do(ctx, &request{
+		Method: "PUT",
+		Path:   deviceRegistrationPath(device.ID),
+		In:     device,
+		Out:    &saved,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Get retrieves the registration of the device with the given ID (RSH1b1).
+func (r *PushDeviceRegistrations) Get(ctx context.Context, deviceID string) (*DeviceDetails, error) {
+	if deviceID == "" {
+		return nil, newError(ErrBadRequest, errDeviceIDRequired)
+	}
+	var device DeviceDetails
+	if _, err := r.client.get(ctx, deviceRegistrationPath(deviceID), &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// List retrieves the registered devices matching the given filters (RSH1b2).
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+func (r *PushDeviceRegistrations) List(o ...PushListOption) DeviceDetailsRequest {
+	params := (&pushListOptions{}).apply(o...)
+	return DeviceDetailsRequest{newPaginated[*DeviceDetails](r.client.newPaginatedRequest("/push/deviceRegistrations", "", params), nil)}
+}
+
+// Remove deregisters the device with the given ID. It succeeds even if no
+// such device is registered (RSH1b4).
+func (r *PushDeviceRegistrations) Remove(ctx context.Context, deviceID string) error {
+	if deviceID == "" {
+		return newError(ErrBadRequest, errDeviceIDRequired)
+	}
+	return r.client.delete(ctx, deviceRegistrationPath(deviceID), nil)
+}
+
+// RemoveWhere deregisters all devices matching the given device ID or client
+// ID filter (RSH1b5).
+func (r *PushDeviceRegistrations) RemoveWhere(ctx context.Context, o ...PushListOption) error {
+	params := (&pushListOptions{}).apply(o...)
+	if params.Get("deviceId") == "" && params.Get("clientId") == "" {
+		return newError(ErrBadRequest, errors.New("a device ID or client ID filter must be provided"))
+	}
+	return r.client.delete(ctx, "/push/deviceRegistrations", params)
+}
+
+// PushChannelSubscriptions enables devices to be subscribed to push
+// notifications published on channels (RSH1c).
+type PushChannelSubscriptions struct {
+	client *REST
+}
+
+// Save subscribes a device, or all devices associated with a client ID, to
+// push notifications published on a channel, and returns the subscription as
+// stored by Ably (RSH1c3).
+func (s *PushChannelSubscriptions) Save(ctx context.Context, sub *PushChannelSubscription) (*PushChannelSubscription, error) {
+	if err := validatePushChannelSubscription(sub); err != nil {
+		return nil, err
+	}
+	var saved PushChannelSubscription
+	if _, err := s.client.post(ctx, "/push/channelSubscriptions", sub, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// List retrieves the channel subscriptions matching the given filters (RSH1c1).
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+func (s *PushChannelSubscriptions) List(o ...PushListOption) PushChannelSubscriptionsRequest {
+	params := (&pushListOptions{}).apply(o...)
+	return PushChannelSubscriptionsRequest{newPaginated[*PushChannelSubscription](s.client.newPaginatedRequest("/push/channelSubscriptions", "", params), nil)}
+}
+
+// ListChannels retrieves the names of the channels with at least one push
+// subscription. Only PushWithLimit applies (RSH1c2).
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+func (s *PushChannelSubscriptions) ListChannels(o ...PushListOption) PushChannelsRequest {
+	params := (&pushListOptions{}).apply(o...)
+	return PushChannelsRequest{newPaginated[string](s.client.newPaginatedRequest("/push/channels", "", params), nil)}
+}
+
+// Remove unsubscribes a device, or all devices associated with a client ID,
+// from push notifications on a channel (RSH1c4).
+func (s *PushChannelSubscriptions) Remove(ctx context.Context, sub *PushChannelSubscription) error {
+	if err := validatePushChannelSubscription(sub); err != nil {
+		return err
+	}
+	return s.client.delete(ctx, "/push/channelSubscriptions", sub.params())
+}
+
+// RemoveWhere unsubscribes all devices matching the given channel, device ID
+// or client ID filters (RSH1c5).
+func (s *PushChannelSubscriptions) RemoveWhere(ctx context.Context, o ...PushListOption) error {
+	params := (&pushListOptions{}).apply(o...)
+	if len(params) == 0 {
+		return newError(ErrBadRequest, errors.New("at least one filter must be provided"))
+	}
+	return s.client.delete(ctx, "/push/channelSubscriptions", params)
+}
+
+// params returns the query parameters that identify the subscription.
+func (sub *PushChannelSubscription) params() url.Values {
+	params := url.Values{"channel": {sub.Channel}}
+	if sub.DeviceID != "" {
+		params.Set("deviceId", sub.DeviceID)
+	} else {
+		params.Set("clientId", sub.ClientID)
+	}
+	return params
+}
+
+func validatePushChannelSubscription(sub *PushChannelSubscription) error {
+	switch {
+	case sub == nil || sub.Channel == "":
+		return newError(ErrBadRequest, errors.New("channel must be provided"))
+	case sub.DeviceID == "" && sub.ClientID == "":
+		return newError(ErrBadRequest, errors.New("either a device ID or a client ID must be provided"))
+	case sub.DeviceID != "" && sub.ClientID != "":
+		return newError(ErrBadRequest, errors.New("device ID and client ID are mutually exclusive"))
+	}
+	return nil
+}
+
+// PushChannel manages push notification subscriptions on a single channel,
+// for use by a server that registers devices on their behalf (RSH7).
+type PushChannel struct {
+	channel string
+	client  *REST
+}
+
+func newPushChannel(channel string, client *REST) *PushChannel {
+	return &PushChannel{channel: channel, client: client}
+}
+
+// SubscribeDevice subscribes the device with the given ID to push
+// notifications published on the channel (RSH7a).
+func (p *PushChannel) SubscribeDevice(ctx context.Context, deviceID string) error {
+	return p.subscribe(ctx, &PushChannelSubscription{Channel: p.channel, DeviceID: deviceID})
+}
+
+// SubscribeClient subscribes all devices associated with the given client ID
+// to push notifications published on the channel (RSH7b).
+func (p *PushChannel) SubscribeClient(ctx context.Context, clientID string) error {
+	return p.subscribe(ctx, &PushChannelSubscription{Channel: p.channel, ClientID: clientID})
+}
+
+// UnsubscribeDevice unsubscribes the device with the given ID from push
+// notifications published on the channel (RSH7c).
+func (p *PushChannel) UnsubscribeDevice(ctx context.Context, deviceID string) error {
+	return p.unsubscribe(ctx, &PushChannelSubscription{Channel: p.channel, DeviceID: deviceID})
+}
+
+// UnsubscribeClient unsubscribes all devices associated with the given client
+// ID from push notifications published on the channel (RSH7d).
+func (p *PushChannel) UnsubscribeClient(ctx context.Context, clientID string) error {
+	return p.unsubscribe(ctx, &PushChannelSubscription{Channel: p.channel, ClientID: clientID})
+}
+
+// ListSubscriptions retrieves the push subscriptions on the channel,
+// optionally filtered by device or client ID (RSH7e).
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+func (p *PushChannel) ListSubscriptions(o ...PushListOption) PushChannelSubscriptionsRequest {
+	// Don't append to o, which may share its array with the caller's.
+	opts := append(make([]PushListOption, 0, len(o)+1), o...)
+	opts = append(opts, PushWithChannel(p.channel))
+	params := (&pushListOptions{}).apply(opts...)
+	return PushChannelSubscriptionsRequest{newPaginated[*PushChannelSubscription](p.client.newPaginatedRequest("/push/channelSubscriptions", "", params), nil)}
+}
+
+func (p *PushChannel) subscribe(ctx context.Context, sub *PushChannelSubscription) error {
+	if err := validatePushChannelSubscription(sub); err != nil {
+		return err
+	}
+	_, err := p.client.post(ctx, "/push/channelSubscriptions", sub, nil)
+	return err
+}
+
+func (p *PushChannel) unsubscribe(ctx context.Context, sub *PushChannelSubscription) error {
+	if err := validatePushChannelSubscription(sub); err != nil {
+		return err
+	}
+	return p.client.delete(ctx, "/push/channelSubscriptions", sub.params())
+}
+
+// DeviceDetailsRequest represents a request prepared by the
+// PushDeviceRegistrations.List method, ready to be performed by its Pages or
+// Items methods.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type DeviceDetailsRequest struct {
+	Paginated[*DeviceDetails]
+}
+
+// A DeviceDetailsPaginatedResult is an iterator for the result of a
+// PushDeviceRegistrations.List request.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type DeviceDetailsPaginatedResult = PaginatedPages[*DeviceDetails]
+
+// A DeviceDetailsPaginatedItems is an iterator for single DeviceDetails, over
+// an underlying paginated iterator.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type DeviceDetailsPaginatedItems = PaginatedItems[*DeviceDetails]
+
+// PushChannelSubscriptionsRequest represents a request prepared by the
+// PushChannelSubscriptions.List or PushChannel.ListSubscriptions methods,
+// ready to be performed by its Pages or Items methods.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelSubscriptionsRequest struct {
+	Paginated[*PushChannelSubscription]
+}
+
+// A PushChannelSubscriptionsPaginatedResult is an iterator for the result of
+// a PushChannelSubscriptions.List request.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelSubscriptionsPaginatedResult = PaginatedPages[*PushChannelSubscription]
+
+// A PushChannelSubscriptionsPaginatedItems is an iterator for single
+// PushChannelSubscriptions, over an underlying paginated iterator.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelSubscriptionsPaginatedItems = PaginatedItems[*PushChannelSubscription]
+
+// PushChannelsRequest represents a request prepared by the
+// PushChannelSubscriptions.ListChannels method, ready to be performed by its
+// Pages or Items methods.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelsRequest struct {
+	Paginated[string]
+}
+
+// A PushChannelsPaginatedResult is an iterator for the result of a
+// PushChannelSubscriptions.ListChannels request.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelsPaginatedResult = PaginatedPages[string]
+
+// A PushChannelsPaginatedItems is an iterator for single channel names, over
+// an underlying paginated iterator.
+//
+// See package-level documentation => [ably] Pagination for handling pagination.
+type PushChannelsPaginatedItems = PaginatedItems[string]
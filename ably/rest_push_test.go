@@ -0,0 +1,331 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pushServer is an in-memory stand-in for the push admin REST endpoints.
+type pushServer struct {
+	mtx       sync.Mutex
+	devices   []*ably.DeviceDetails
+	subs      []*ably.PushChannelSubscription
+	published []map[string]interface{}
+}
+
+func (s *pushServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	q := r.URL.Query()
+	matches := func(values map[string]string) bool {
+		for k, v := range values {
+			if f := q.Get(k); f != "" && f != v {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/push/publish":
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.published = append(s.published, body)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/push/deviceRegistrations/"):
+		var d ably.DeviceDetails
+		json.NewDecoder(r.Body).Decode(&d)
+		d.Push.State = ably.DevicePushStateActive
+		s.devices = append(s.devices, &d)
+		writeJSON(w, d)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/push/deviceRegistrations/"):
+		id := strings.TrimPrefix(r.URL.Path, "/push/deviceRegistrations/")
+		for _, d := range s.devices {
+			if d.ID == id {
+				writeJSON(w, d)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":40400,"statusCode":404,"message":"device not found"}}`))
+	case r.Method == "GET" && r.URL.Path == "/push/deviceRegistrations":
+		var found []*ably.DeviceDetails
+		for _, d := range s.devices {
+			if matches(map[string]string{"deviceId": d.ID, "clientId": d.ClientID}) {
+				found = append(found, d)
+			}
+		}
+		writePage(w, r, found)
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/push/deviceRegistrations"):
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/push/deviceRegistrations"), "/")
+		var kept []*ably.DeviceDetails
+		for _, d := range s.devices {
+			if id == d.ID || (id == "" && matches(map[string]string{"deviceId": d.ID, "clientId": d.ClientID})) {
+				continue
+			}
+			kept = append(kept, d)
+		}
+		s.devices = kept
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && r.URL.Path == "/push/channelSubscriptions":
+		var sub ably.PushChannelSubscription
+		json.NewDecoder(r.Body).Decode(&sub)
+		s.subs = append(s.subs, &sub)
+		writeJSON(w, sub)
+	case r.Method == "GET" && r.URL.Path == "/push/channelSubscriptions":
+		var found []*ably.PushChannelSubscription
+		for _, sub := range s.subs {
+			if matches(map[string]string{"channel": sub.Channel, "deviceId": sub.DeviceID, "clientId": sub.ClientID}) {
+				found = append(found, sub)
+			}
+		}
+		writePage(w, r, found)
+	case r.Method == "GET" && r.URL.Path == "/push/channels":
+		var channels []string
+		seen := map[string]bool{}
+		for _, sub := range s.subs {
+			if !seen[sub.Channel] {
+				seen[sub.Channel] = true
+				channels = append(channels, sub.Channel)
+			}
+		}
+		writePage(w, r, channels)
+	case r.Method == "DELETE" && r.URL.Path == "/push/channelSubscriptions":
+		var kept []*ably.PushChannelSubscription
+		for _, sub := range s.subs {
+			if !matches(map[string]string{"channel": sub.Channel, "deviceId": sub.DeviceID, "clientId": sub.ClientID}) {
+				kept = append(kept, sub)
+			}
+		}
+		s.subs = kept
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writePage writes the slice of items selected by the "limit" and "start"
+// query parameters, with a Link header to the next page if there is one.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	q := r.URL.Query()
+	start, _ := strconv.Atoi(q.Get("start"))
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil {
+		limit = 100
+	}
+	end := start + limit
+	if end >= len(items) {
+		end = len(items)
+	} else {
+		q.Set("start", strconv.Itoa(end))
+		w.Header().Set("Link", `<./`+pathBase(r.URL.Path)+"?"+q.Encode()+`>; rel="next"`)
+	}
+	writeJSON(w, append([]T{}, items[start:end]...))
+}
+
+func pathBase(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+func newPushTestClient(t *testing.T) (*ably.REST, *pushServer) {
+	srv := &pushServer{}
+	return newHTTPTestREST(t, srv), srv
+}
+
+func TestPushAdmin_Publish(t *testing.T) {
+	client, srv := newPushTestClient(t)
+	ctx := context.Background()
+
+	err := client.Push.Admin.Publish(ctx,
+		map[string]interface{}{"clientId": "bob"},
+		map[string]interface{}{"notification": map[string]interface{}{"title": "Hi"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{
+		"recipient":    map[string]interface{}{"clientId": "bob"},
+		"notification": map[string]interface{}{"title": "Hi"},
+	}}, srv.published)
+
+	err = client.Push.Admin.Publish(ctx, nil, map[string]interface{}{"data": "x"})
+	assert.Error(t, err)
+}
+
+func TestPushAdmin_DeviceRegistrations(t *testing.T) {
+	client, _ := newPushTestClient(t)
+	ctx := context.Background()
+	registrations := client.Push.Admin.DeviceRegistrations
+
+	for _, d := range []*ably.DeviceDetails{
+		{ID: "d1", ClientID: "alice", Platform: ably.DevicePlatformIOS, FormFactor: ably.DeviceFormFactorPhone},
+		{ID: "d2", ClientID: "alice", Platform: ably.DevicePlatformAndroid, FormFactor: ably.DeviceFormFactorTablet},
+		{ID: "d3", ClientID: "bob", Platform: ably.DevicePlatformBrowser, FormFactor: ably.DeviceFormFactorDesktop},
+	} {
+		d.Push.Recipient = map[string]interface{}{"transportType": "fcm", "registrationToken": d.ID}
+		saved, err := registrations.Save(ctx, d)
+		assert.NoError(t, err)
+		assert.Equal(t, ably.DevicePushStateActive, saved.Push.State)
+	}
+
+	d, err := registrations.Get(ctx, "d2")
+	assert.NoError(t, err)
+	assert.Equal(t, ably.DevicePlatformAndroid, d.Platform)
+
+	_, err = registrations.Get(ctx, "missing")
+	assert.Equal(t, ably.ErrNotFound, ably.UnwrapErrorCode(err))
+
+	pages, err := registrations.List(ably.PushWithClientID("alice"), ably.PushWithLimit(1)).Pages(ctx)
+	assert.NoError(t, err)
+	var ids []string
+	for pages.Next(ctx) {
+		assert.Len(t, pages.Items(), 1)
+		ids = append(ids, pages.Items()[0].ID)
+	}
+	assert.NoError(t, pages.Err())
+	assert.Equal(t, []string{"d1", "d2"}, ids)
+
+	// An empty ID must not address the whole collection.
+	_, err = registrations.Get(ctx, "")
+	assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+	err = registrations.Remove(ctx, "")
+	assert.Equal(t, ably.ErrBadRequest, ably.UnwrapErrorCode(err))
+
+	assert.NoError(t, registrations.Remove(ctx, "d1"))
+	assert.NoError(t, registrations.RemoveWhere(ctx, ably.PushWithClientID("bob")))
+	assert.Error(t, registrations.RemoveWhere(ctx), "expected an error without filters")
+
+	items, err := registrations.List().Items(ctx)
+	assert.NoError(t, err)
+	ids = nil
+	for items.Next(ctx) {
+		ids = append(ids, items.Item().ID)
+	}
+	assert.NoError(t, items.Err())
+	assert.Equal(t, []string{"d2"}, ids)
+}
+
+func TestDevicePushDetails_ErrorReason(t *testing.T) {
+	var details ably.DevicePushDetails
+	err := json.Unmarshal([]byte(`{"state":"FAILED","errorReason":{"code":40000,"statusCode":400,"message":"invalid token"}}`), &details)
+	assert.NoError(t, err)
+	assert.Equal(t, ably.DevicePushStateFailed, details.State)
+	assert.Equal(t, ably.ErrBadRequest, details.ErrorReason.Code)
+	assert.Equal(t, 400, details.ErrorReason.StatusCode)
+	assert.Equal(t, "invalid token", details.ErrorReason.Message())
+
+	js, err := json.Marshal(details)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"recipient":null,"state":"FAILED","errorReason":{"code":40000,"statusCode":400,"message":"invalid token"}}`, string(js))
+}
+
+func TestPushAdmin_ChannelSubscriptions(t *testing.T) {
+	client, _ := newPushTestClient(t)
+	ctx := context.Background()
+	subscriptions := client.Push.Admin.ChannelSubscriptions
+
+	for _, sub := range []*ably.PushChannelSubscription{
+		{Channel: "news", DeviceID: "d1"},
+		{Channel: "news", ClientID: "alice"},
+		{Channel: "sport", ClientID: "alice"},
+	} {
+		saved, err := subscriptions.Save(ctx, sub)
+		assert.NoError(t, err)
+		assert.Equal(t, sub, saved)
+	}
+	_, err := subscriptions.Save(ctx, &ably.PushChannelSubscription{Channel: "news", DeviceID: "d1", ClientID: "alice"})
+	assert.Error(t, err, "expected an error when both device ID and client ID are set")
+
+	items, err := subscriptions.List(ably.PushWithChannel("news")).Items(ctx)
+	assert.NoError(t, err)
+	var subs []*ably.PushChannelSubscription
+	for items.Next(ctx) {
+		subs = append(subs, items.Item())
+	}
+	assert.NoError(t, items.Err())
+	assert.Len(t, subs, 2)
+
+	channels, err := subscriptions.ListChannels(ably.PushWithLimit(1)).Items(ctx)
+	assert.NoError(t, err)
+	var names []string
+	for channels.Next(ctx) {
+		names = append(names, channels.Item())
+	}
+	assert.NoError(t, channels.Err())
+	assert.Equal(t, []string{"news", "sport"}, names)
+
+	assert.NoError(t, subscriptions.Remove(ctx, &ably.PushChannelSubscription{Channel: "news", DeviceID: "d1"}))
+	assert.NoError(t, subscriptions.RemoveWhere(ctx, ably.PushWithClientID("alice"), ably.PushWithChannel("sport")))
+
+	pages, err := subscriptions.List().Pages(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pages.Next(ctx))
+	assert.Equal(t, []*ably.PushChannelSubscription{{Channel: "news", ClientID: "alice"}}, pages.Items())
+	assert.True(t, pages.IsLast(ctx))
+}
+
+func TestPushChannel(t *testing.T) {
+	client, srv := newPushTestClient(t)
+	ctx := context.Background()
+	push := client.Channels.Get("news").Push
+
+	assert.NoError(t, push.SubscribeDevice(ctx, "d1"))
+	assert.NoError(t, push.SubscribeClient(ctx, "alice"))
+	assert.NoError(t, client.Channels.Get("sport").Push.SubscribeClient(ctx, "alice"))
+	assert.Error(t, push.SubscribeDevice(ctx, ""), "expected an error without a device ID")
+
+	listSubscriptions := func(o ...ably.PushListOption) []*ably.PushChannelSubscription {
+		t.Helper()
+		items, err := push.ListSubscriptions(o...).Items(ctx)
+		assert.NoError(t, err)
+		var subs []*ably.PushChannelSubscription
+		for items.Next(ctx) {
+			subs = append(subs, items.Item())
+		}
+		assert.NoError(t, items.Err())
+		return subs
+	}
+	assert.Equal(t, []*ably.PushChannelSubscription{
+		{Channel: "news", DeviceID: "d1"},
+		{Channel: "news", ClientID: "alice"},
+	}, listSubscriptions())
+	assert.Equal(t, []*ably.PushChannelSubscription{
+		{Channel: "news", ClientID: "alice"},
+	}, listSubscriptions(ably.PushWithClientID("alice")))
+
+	// The caller's options are left alone, even with room to spare.
+	opts := make([]ably.PushListOption, 1, 2)
+	opts[0] = ably.PushWithClientID("alice")
+	listSubscriptions(opts...)
+	assert.Nil(t, opts[:2][1])
+
+	assert.NoError(t, push.UnsubscribeDevice(ctx, "d1"))
+	assert.NoError(t, push.UnsubscribeClient(ctx, "alice"))
+	assert.Empty(t, listSubscriptions())
+	assert.Equal(t, []*ably.PushChannelSubscription{{Channel: "sport", ClientID: "alice"}}, srv.subs)
+}
+
+func TestPushChannel_Realtime(t *testing.T) {
+	srv := &pushServer{}
+	client, err := ably.NewRealtime(append(httpTestServerOptions(t, srv), ably.WithAutoConnect(false))...)
+	assert.NoError(t, err)
+
+	err = client.Channels.Get("news").Push.SubscribeClient(context.Background(), "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []*ably.PushChannelSubscription{{Channel: "news", ClientID: "alice"}}, srv.subs)
+}
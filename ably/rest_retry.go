@@ -0,0 +1,174 @@
+package ably
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how REST requests are retried once they have failed
+// against the primary host and every fallback host, or have been rejected
+// because of rate limiting. See [ably.WithRetryPolicy].
+//
+// Requests that Ably may have processed before failing, e.g. because of a
+// timeout, are only retried if they are idempotent: reads, and publishes
+// whose messages all have an ID, which Ably uses to discard duplicates (see
+// [ably.WithIdempotentRESTPublishing]). Requests rejected because of rate
+// limiting, with HTTP status 429 or a non-fatal rate limit error code, weren't
+// processed and are always retried, after waiting for as long as Ably asks for
+// in the Retry-After header, if present.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is performed,
+	// including the first one. With 1 or less, requests aren't retried.
+	MaxAttempts int
+	// InitialBackoff is the time waited before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time waited before each retry. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after each retry.
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, by which each backoff is
+	// randomly shortened, so that clients failing at the same time don't
+	// retry at the same time.
+	Jitter float64
+	// MaxDuration is the maximum time spent on a request, including all
+	// retries. Zero means no limit other than the context's deadline; a request
+	// isn't retried if the context would expire before the retry.
+	MaxDuration time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by [ably.WithRetryPolicy] when
+// passed a zero RetryPolicy, which can be tweaked as needed:
+//
+//	policy := ably.DefaultRetryPolicy()
+//	policy.MaxAttempts = 5
+//	client, err := ably.NewREST(ably.WithRetryPolicy(policy), ...)
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		MaxDuration:    30 * time.Second,
+	}
+}
+
+// WithRetryPolicy makes REST retry failed requests according to policy. By
+// default, failed requests are only retried against fallback hosts (RSC15).
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(os *clientOptions) {
+		if policy == (RetryPolicy{}) {
+			policy = DefaultRetryPolicy()
+		}
+		os.RetryPolicy = &policy
+	}
+}
+
+// backoff returns the time to wait before the given retry, starting at 1.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// doWithRetries performs r as doWithFallbacks does, retrying it according to
+// the client's retry policy.
+func (c *REST) doWithRetries(ctx context.Context, r *request, handle func(*http.Response, interface{}) (*http.Response, error)) (*http.Response, error) {
+	policy := c.opts.RetryPolicy
+	start := c.opts.Now()
+	for retry := 1; ; retry++ {
+		r.lastResponse = nil
+		resp, err := c.doWithFallbacks(ctx, r, handle)
+		if err == nil || retry >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case isRetryableRateLimit(err):
+			wait = policy.backoff(retry)
+			if after, ok := c.retryAfter(r.lastResponse); ok {
+				wait = after
+			}
+		case r.isIdempotent() && canFallBack(err, r.lastResponse):
+			wait = policy.backoff(retry)
+		default:
+			return nil, err
+		}
+
+		retryAt := c.opts.Now().Add(wait)
+		if policy.MaxDuration > 0 && retryAt.Sub(start) > policy.MaxDuration {
+			c.log.Infof("RestClient: not retrying request after %v, as the retry policy's maximum duration would be exceeded", wait)
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && retryAt.After(deadline) {
+			c.log.Infof("RestClient: not retrying request after %v, as the context would expire", wait)
+			return nil, err
+		}
+		c.log.Infof("RestClient: retrying request in %v (attempt %d of %d)", wait, retry+1, policy.MaxAttempts)
+		if _, ok := <-c.opts.After(ctx, wait); !ok {
+			return nil, err
+		}
+	}
+}
+
+// isRetryableRateLimit returns whether err rejects a request because of a
+// non-fatal rate limit.
+func isRetryableRateLimit(err error) bool {
+	var e *ErrorInfo
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.Code >= ErrRateLimitExceededFatal && e.Code < ErrRateLimitExceededFatal+10 {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.Code >= ErrRateLimitExceeded && e.Code < ErrRateLimitExceeded+10
+}
+
+// retryAfter returns the time to wait requested by the Retry-After header of
+// resp, either as seconds or as an HTTP date.
+func (c *REST) retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(c.opts.Now())
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// isIdempotent returns whether the request can be safely performed again even
+// if Ably may have processed it already.
+func (r *request) isIdempotent() bool {
+	switch r.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+	return r.Idempotent
+}
@@ -0,0 +1,146 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetryPolicy(t *testing.T) {
+	policy := ably.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		Multiplier:     2,
+	}
+	ctx := context.Background()
+
+	// newClient returns a client whose requests get the responses written by
+	// the given functions, in order, and then succeed.
+	newClient := func(t *testing.T, policy *ably.RetryPolicy, responses ...func(w http.ResponseWriter)) (*ably.REST, *fakeSleeper, *int32) {
+		var requests int32
+		// Start at the current time, so that context deadlines are comparable.
+		clock := &fakeSleeper{now: time.Now()}
+		opts := []ably.ClientOption{
+			ably.WithNow(clock.Now),
+			ably.WithAfter(clock.After),
+			ably.WithIdempotentRESTPublishing(false),
+		}
+		if policy != nil {
+			opts = append(opts, ably.WithRetryPolicy(*policy))
+		}
+		client := newHTTPTestREST(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(atomic.AddInt32(&requests, 1))
+			if n <= len(responses) {
+				responses[n-1](w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[1000]"))
+		}), opts...)
+		return client, clock, &requests
+	}
+	status := func(code int, header ...string) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			for i := 0; i < len(header); i += 2 {
+				w.Header().Set(header[i], header[i+1])
+			}
+			w.WriteHeader(code)
+		}
+	}
+	ablyError := func(statusCode int, code ably.ErrorCode) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			writeJSON(w, map[string]interface{}{"error": map[string]interface{}{"code": code, "statusCode": statusCode, "message": "slow down"}})
+		}
+	}
+	publish := func(client *ably.REST, id string) error {
+		return client.Channels.Get("retried").PublishMultiple(ctx, []*ably.Message{{ID: id, Name: "event", Data: "data"}})
+	}
+
+	t.Run("honors Retry-After on 429", func(t *testing.T) {
+		client, clock, requests := newClient(t, &policy, status(http.StatusTooManyRequests, "Retry-After", "2"))
+		assert.NoError(t, publish(client, ""))
+		assert.Equal(t, int32(2), *requests)
+		assert.Equal(t, []time.Duration{2 * time.Second}, clock.sleeps)
+	})
+
+	t.Run("backs off on rate limit error codes", func(t *testing.T) {
+		client, clock, requests := newClient(t, &policy,
+			ablyError(http.StatusBadRequest, ably.ErrRateLimitExceeded),
+			ablyError(http.StatusBadRequest, ably.ErrRateLimitExceeded+1),
+		)
+		assert.NoError(t, publish(client, ""))
+		assert.Equal(t, int32(3), *requests)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, clock.sleeps)
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		client, clock, requests := newClient(t, &policy,
+			status(http.StatusTooManyRequests),
+			status(http.StatusTooManyRequests),
+			status(http.StatusTooManyRequests),
+		)
+		err := publish(client, "")
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, err.(*ably.ErrorInfo).StatusCode)
+		assert.Equal(t, int32(3), *requests)
+		assert.Len(t, clock.sleeps, 2)
+	})
+
+	t.Run("doesn't retry fatal rate limit errors", func(t *testing.T) {
+		client, _, requests := newClient(t, &policy, ablyError(http.StatusTooManyRequests, ably.ErrRateLimitExceededFatal))
+		assert.Error(t, publish(client, ""))
+		assert.Equal(t, int32(1), *requests)
+	})
+
+	t.Run("retries server errors only for idempotent requests", func(t *testing.T) {
+		client, _, requests := newClient(t, &policy, status(http.StatusInternalServerError))
+		assert.Error(t, publish(client, ""), "publishes without IDs may have been processed")
+		assert.Equal(t, int32(1), *requests)
+
+		client, _, requests = newClient(t, &policy, status(http.StatusInternalServerError))
+		assert.NoError(t, publish(client, "known-id"))
+		assert.Equal(t, int32(2), *requests)
+
+		client, clock, requests := newClient(t, &policy, status(http.StatusBadGateway), status(http.StatusServiceUnavailable))
+		_, err := client.Time(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), *requests)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, clock.sleeps)
+	})
+
+	t.Run("bounded by MaxDuration", func(t *testing.T) {
+		bounded := policy
+		bounded.MaxDuration = time.Second
+		client, clock, requests := newClient(t, &bounded, status(http.StatusTooManyRequests, "Retry-After", "5"))
+		assert.Error(t, publish(client, ""))
+		assert.Equal(t, int32(1), *requests)
+		assert.Empty(t, clock.sleeps)
+	})
+
+	t.Run("bounded by context", func(t *testing.T) {
+		client, clock, requests := newClient(t, &policy, status(http.StatusTooManyRequests, "Retry-After", "5"))
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		err := client.Channels.Get("retried").Publish(ctx, "event", "data")
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), *requests)
+		assert.Empty(t, clock.sleeps)
+	})
+
+	t.Run("no retries by default", func(t *testing.T) {
+		client, _, requests := newClient(t, nil, status(http.StatusTooManyRequests))
+		assert.Error(t, publish(client, ""))
+		assert.Equal(t, int32(1), *requests)
+	})
+}
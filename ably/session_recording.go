@@ -0,0 +1,373 @@
+package ably
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ably/ably-go/ably/internal/ablyutil"
+)
+
+// Events of a [ably.SessionRecord].
+const (
+	// SessionDial is the Realtime connection dialing Ably. A recorded session
+	// is made of a connection per dial.
+	SessionDial = "dial"
+	// SessionSent is a protocol message sent to Ably.
+	SessionSent = "sent"
+	// SessionReceived is a protocol message received from Ably.
+	SessionReceived = "received"
+	// SessionError is the connection failing to receive a protocol message.
+	SessionError = "error"
+	// SessionClose is the client closing the connection.
+	SessionClose = "close"
+)
+
+// A SessionRecord is an event of a protocol session recorded with
+// [ably.WithSessionRecorder].
+type SessionRecord struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// URL is the URL dialed, without credentials, for SessionDial.
+	URL string `json:"url,omitempty"`
+	// Error is the error with which dialing or receiving failed, if any.
+	Error string `json:"error,omitempty"`
+	// Timeout is whether Error is a receive timing out.
+	Timeout bool `json:"timeout,omitempty"`
+	// Message is the protocol message sent or received, encoded with
+	// MessagePack, as used by the binary protocol, so that it's played back
+	// as it was recorded, including binary data.
+	Message []byte `json:"message,omitempty"`
+}
+
+// WithSessionRecorder records the Realtime connection's protocol session to
+// w, as a SessionRecord in JSON per line, so that it can be played back with
+// [ably.ReplaySession].
+//
+// Recorded protocol messages aren't redacted, as replaying needs them whole:
+// they contain secrets such as connection keys and message data.
+func WithSessionRecorder(w io.Writer) ClientOption {
+	return func(os *clientOptions) {
+		os.SessionRecorder = w
+	}
+}
+
+// sessionRecorder writes the SessionRecords of a client's connections.
+type sessionRecorder struct {
+	now    func() time.Time
+	redact *redactor
+
+	mtx sync.Mutex
+	enc *json.Encoder
+}
+
+func newSessionRecorder(opts *clientOptions) *sessionRecorder {
+	if opts.SessionRecorder == nil {
+		return nil
+	}
+	return &sessionRecorder{
+		now:    opts.Now,
+		redact: &redactor{rules: defaultLogRedactionRules},
+		enc:    json.NewEncoder(opts.SessionRecorder),
+	}
+}
+
+// record writes rec. Failing to write it doesn't fail the connection.
+func (r *sessionRecorder) record(rec SessionRecord) {
+	rec.Time = r.now()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.enc.Encode(rec)
+}
+
+func (r *sessionRecorder) recordMessage(event string, msg *protocolMessage) {
+	p, err := ablyutil.MarshalMsgpack(msg)
+	if err != nil {
+		r.record(SessionRecord{Event: event, Error: err.Error()})
+		return
+	}
+	r.record(SessionRecord{Event: event, Message: p})
+}
+
+// dial records dialing u, and wraps the resulting conn to record its
+// protocol messages.
+func (r *sessionRecorder) dial(u *url.URL, conn conn, err error) conn {
+	rec := SessionRecord{Event: SessionDial, URL: r.redact.redact(u.String())}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	r.record(rec)
+	if err != nil {
+		return conn
+	}
+	return recordingConn{conn: conn, r: r}
+}
+
+type recordingConn struct {
+	conn conn
+	r    *sessionRecorder
+}
+
+func (rc recordingConn) Send(msg *protocolMessage) error {
+	if err := rc.conn.Send(msg); err != nil {
+		return err
+	}
+	rc.r.recordMessage(SessionSent, msg)
+	return nil
+}
+
+func (rc recordingConn) Receive(timeout time.Duration) (*protocolMessage, error) {
+	msg, err := rc.conn.Receive(timeout)
+	if err != nil {
+		var netErr net.Error
+		rc.r.record(SessionRecord{
+			Event:   SessionError,
+			Error:   err.Error(),
+			Timeout: errors.As(err, &netErr) && netErr.Timeout(),
+		})
+		return nil, err
+	}
+	rc.r.recordMessage(SessionReceived, msg)
+	return msg, nil
+}
+
+func (rc recordingConn) Close() error {
+	rc.r.record(SessionRecord{Event: SessionClose})
+	return rc.conn.Close()
+}
+
+func (rc recordingConn) Unwrap() conn {
+	return rc.conn
+}
+
+// A ReplayOption configures how [ably.ReplaySession] plays a session back.
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	speed float64
+}
+
+// ReplayWithSpeed sets how fast a session is played back relative to its
+// original timing: e.g. 2 plays it twice as fast. With 0, protocol messages
+// are received without delay. The default is 1.
+func ReplayWithSpeed(speed float64) ReplayOption {
+	return func(o *replayOptions) {
+		o.speed = speed
+	}
+}
+
+// ReplaySession returns a dial function, to be set with [ably.WithDial],
+// that plays back a session recorded with [ably.WithSessionRecorder].
+//
+// Each dial plays back the next connection of the session. Protocol messages
+// sent by the client are discarded, but each recorded protocol message or
+// error is only received once the client has sent as many protocol messages
+// as had been sent before it, so that e.g. an ATTACHED isn't received before
+// the ATTACH it answers. It's then received with its original delay since the
+// last of those, or since the dial, scaled by ReplayWithSpeed and timed with
+// the client's clock, as set with [ably.WithClock]. Once the session is
+// exhausted, dialing fails.
+func ReplaySession(session io.Reader, opts ...ReplayOption) (func(protocol string, u *url.URL, timeout time.Duration) (conn, error), error) {
+	o := replayOptions{speed: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var conns [][]SessionRecord
+	scanner := bufio.NewScanner(session)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec SessionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("replay: line %d: %w", line, err)
+		}
+		switch {
+		case rec.Event == SessionDial:
+			conns = append(conns, []SessionRecord{rec})
+		case len(conns) > 0:
+			conns[len(conns)-1] = append(conns[len(conns)-1], rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	var mtx sync.Mutex
+	return func(protocol string, u *url.URL, timeout time.Duration) (conn, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if len(conns) == 0 {
+			return nil, errors.New("replay: no more recorded connections")
+		}
+		records := conns[0]
+		conns = conns[1:]
+		if records[0].Error != "" {
+			return nil, errors.New(records[0].Error)
+		}
+		return newReplayConn(records, o), nil
+	}, nil
+}
+
+// replayConn plays back the records of a connection, starting with its
+// dial.
+type replayConn struct {
+	speed   float64
+	records []replayRecord
+
+	// now and after are the client's clock, set by setClock, with which
+	// records are timed and Receive's timeout is measured.
+	now    func() time.Time
+	after  ablyutil.TimerFunc
+	dialed time.Time
+
+	mtx    sync.Mutex
+	sentAt []time.Time   // when each protocol message was sent by the client
+	sent   chan struct{} // closed when the client sends a protocol message
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// replayRecord is a record to be received, with what preceded it when
+// recorded.
+type replayRecord struct {
+	SessionRecord
+	sent  int       // number of protocol messages sent before it
+	after time.Time // when the last of them was sent, or the dial
+}
+
+func newReplayConn(records []SessionRecord, o replayOptions) *replayConn {
+	c := &replayConn{
+		speed:  o.speed,
+		now:    time.Now,
+		after:  ablyutil.After,
+		dialed: time.Now(),
+		sent:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+	sent, after := 0, records[0].Time
+	for _, rec := range records[1:] {
+		switch rec.Event {
+		case SessionSent:
+			sent, after = sent+1, rec.Time
+		case SessionReceived, SessionError:
+			c.records = append(c.records, replayRecord{SessionRecord: rec, sent: sent, after: after})
+		}
+	}
+	return c
+}
+
+func (c *replayConn) setClock(now func() time.Time, after ablyutil.TimerFunc) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now, c.after = now, after
+	c.dialed = now()
+}
+
+func (c *replayConn) Send(msg *protocolMessage) error {
+	select {
+	case <-c.closed:
+		return errors.New("replay: connection closed")
+	default:
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.sentAt = append(c.sentAt, c.now())
+	close(c.sent)
+	c.sent = make(chan struct{})
+	return nil
+}
+
+// anchor returns when the client sent the protocol message after which rec
+// was received, or false if it hasn't yet, with a channel closed when it
+// sends one.
+func (c *replayConn) anchor(rec replayRecord) (time.Time, bool, <-chan struct{}) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	switch {
+	case rec.sent == 0:
+		return c.dialed, true, nil
+	case len(c.sentAt) >= rec.sent:
+		return c.sentAt[rec.sent-1], true, nil
+	default:
+		return time.Time{}, false, c.sent
+	}
+}
+
+func (c *replayConn) Receive(timeout time.Duration) (*protocolMessage, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timedOut = c.after(ctx, timeout)
+	}
+	wait := func(ready <-chan struct{}, at <-chan time.Time) error {
+		select {
+		case <-c.closed:
+			return errors.New("replay: connection closed")
+		case <-timedOut:
+			return replayError{msg: "replay: receive timed out", timeout: true}
+		case <-ready:
+		case <-at:
+		}
+		return nil
+	}
+	if len(c.records) == 0 {
+		return nil, wait(nil, nil)
+	}
+	rec := c.records[0]
+
+	// Wait for the client to send what rec answered, if anything, and then
+	// for as long as it took to be received when recorded.
+	anchor, ok, sent := c.anchor(rec)
+	for !ok {
+		if err := wait(sent, nil); err != nil {
+			return nil, err
+		}
+		anchor, ok, sent = c.anchor(rec)
+	}
+	if c.speed > 0 {
+		at := anchor.Add(time.Duration(float64(rec.Time.Sub(rec.after)) / c.speed))
+		if err := wait(nil, c.after(ctx, at.Sub(c.now()))); err != nil {
+			return nil, err
+		}
+	}
+
+	c.records = c.records[1:]
+	if rec.Event == SessionError {
+		return nil, replayError{msg: rec.Error, timeout: rec.Timeout}
+	}
+	msg := &protocolMessage{}
+	if err := ablyutil.UnmarshalMsgpackBinary(rec.Message, msg); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return msg, nil
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// replayError is a recorded receive error, played back.
+type replayError struct {
+	msg     string
+	timeout bool
+}
+
+func (e replayError) Error() string   { return e.msg }
+func (e replayError) Timeout() bool   { return e.timeout }
+func (e replayError) Temporary() bool { return e.timeout }
+
+var _ net.Error = replayError{}
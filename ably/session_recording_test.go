@@ -0,0 +1,221 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestSessionRecording(t *testing.T) {
+	var session syncBuffer
+
+	// Record a session.
+	in := make(chan *ably.ProtocolMessage, 16)
+	out := make(chan *ably.ProtocolMessage, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithSessionRecorder(&session),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{
+		Action:            ably.ActionConnected,
+		ConnectionID:      "connection-id",
+		ConnectionDetails: &ably.ConnectionDetails{ConnectionKey: "connection-key"},
+	}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	channel := c.Channels.Get("recorded")
+	attached := make(chan error, 1)
+	go func() {
+		attached <- channel.Attach(context.Background())
+	}()
+	var msg *ably.ProtocolMessage
+	ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+	in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+	ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+	assert.NoError(t, err)
+	messages := make(chan *ably.Message, 1)
+	_, err = channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	in <- &ably.ProtocolMessage{
+		Action:   ably.ActionMessage,
+		Channel:  channel.Name,
+		Messages: []*ably.Message{{Name: "event", Data: "data"}},
+	}
+	var m *ably.Message
+	ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+	go func() {
+		ablytest.Soon.Recv(t, &msg, out, t.Errorf)
+		in <- &ably.ProtocolMessage{Action: ably.ActionClosed}
+	}()
+	assert.NoError(t, ablytest.Wait(ablytest.ConnWaiter(c, c.Close, ably.ConnectionEventClosed), nil))
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(session.Bytes())), "\n") {
+		var rec ably.SessionRecord
+		assert.NoError(t, json.Unmarshal([]byte(line), &rec))
+		events = append(events, rec.Event)
+		if rec.Event == ably.SessionDial {
+			assert.NotContains(t, rec.URL, "fake:token", "credentials must be redacted from the URL")
+		}
+	}
+	assert.Equal(t, []string{
+		ably.SessionDial,
+		ably.SessionReceived, // CONNECTED
+		ably.SessionSent,     // ATTACH
+		ably.SessionReceived, // ATTACHED
+		ably.SessionReceived, // MESSAGE
+		ably.SessionSent,     // CLOSE
+		ably.SessionReceived, // CLOSED
+	}, events[:7])
+
+	replay := func(t *testing.T, speed float64) time.Duration {
+		dial, err := ably.ReplaySession(bytes.NewReader(session.Bytes()), ably.ReplayWithSpeed(speed))
+		assert.NoError(t, err)
+		c, err := ably.NewRealtime(
+			ably.WithToken("fake:token"),
+			ably.WithAutoConnect(false),
+			ably.WithDial(dial),
+		)
+		assert.NoError(t, err)
+		defer c.Close()
+		start := time.Now()
+		err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "connection-id", c.Connection.ID())
+		assert.Equal(t, "connection-key", c.Connection.Key())
+
+		channel := c.Channels.Get("recorded")
+		messages := make(chan *ably.Message, 1)
+		_, err = channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+			messages <- m
+		})
+		assert.NoError(t, err)
+		var m *ably.Message
+		ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+		assert.Equal(t, "data", m.Data)
+		return time.Since(start)
+	}
+
+	t.Run("original timing", func(t *testing.T) {
+		assert.GreaterOrEqual(t, replay(t, 1), 50*time.Millisecond)
+	})
+	t.Run("accelerated", func(t *testing.T) {
+		assert.Less(t, replay(t, 0), 50*time.Millisecond)
+	})
+}
+
+func TestSessionRecording_BinaryAndEncryptedData(t *testing.T) {
+	key, err := ably.Crypto.GenerateRandomKey(128)
+	assert.NoError(t, err)
+	cipher, err := (&ably.ProtoChannelOptions{
+		Cipher: ably.Crypto.GetDefaultParams(ably.CipherParams{Key: key}),
+	}).GetCipher()
+	assert.NoError(t, err)
+	encrypted, err := ably.MessageWithEncodedData(ably.Message{Name: "encrypted", Data: "secret"}, cipher)
+	assert.NoError(t, err)
+	// As received with the binary protocol, encrypted data isn't base64-encoded.
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Data.(string))
+	assert.NoError(t, err)
+	encrypted.Data, encrypted.Encoding = ciphertext, strings.TrimSuffix(encrypted.Encoding, "/base64")
+
+	// Record a session in which, 2 seconds after attaching, binary and
+	// encrypted data are received.
+	var session syncBuffer
+	recClock := ablytest.NewFakeClock(time.Now())
+	in := make(chan *ably.ProtocolMessage, 16)
+	out := make(chan *ably.ProtocolMessage, 16)
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithClock(recClock),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithSessionRecorder(&session),
+	)
+	assert.NoError(t, err)
+	defer c.Close()
+	in <- &ably.ProtocolMessage{Action: ably.ActionConnected, ConnectionID: "connection-id"}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	channel := c.Channels.Get("secret", ably.ChannelWithCipherKey(key))
+	in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+	messages := make(chan *ably.Message, 2)
+	_, err = channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	recClock.Advance(2 * time.Second)
+	in <- &ably.ProtocolMessage{
+		Action:   ably.ActionMessage,
+		Channel:  channel.Name,
+		Messages: []*ably.Message{{Name: "binary", Data: []byte{1, 2, 3}}, &encrypted},
+	}
+	var m *ably.Message
+	ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+	ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+
+	// Play it back, timed by the client's clock.
+	dial, err := ably.ReplaySession(bytes.NewReader(session.Bytes()))
+	assert.NoError(t, err)
+	clock := ablytest.NewFakeClock(time.Now())
+	replayed, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithClock(clock),
+		ably.WithDial(dial),
+	)
+	assert.NoError(t, err)
+	defer replayed.Close()
+	err = ablytest.Wait(ablytest.ConnWaiter(replayed, replayed.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	channel = replayed.Channels.Get("secret", ably.ChannelWithCipherKey(key))
+	_, err = channel.SubscribeAll(context.Background(), func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+
+	ablytest.Instantly.NoRecv(t, nil, messages, t.Fatalf)
+	clock.Advance(2 * time.Second)
+	ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+	assert.Equal(t, "binary", m.Name)
+	assert.Equal(t, []byte{1, 2, 3}, m.Data)
+	ablytest.Soon.Recv(t, &m, messages, t.Fatalf)
+	assert.Equal(t, "encrypted", m.Name)
+	assert.Equal(t, "secret", m.Data)
+}
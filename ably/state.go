@@ -108,11 +108,13 @@ func channelStateError(state ChannelState, err error) *ErrorInfo {
 type pendingEmitter struct {
 	queue []msgWithAckCallback
 	log   logger
+	opts  *clientOptions
 }
 
-func newPendingEmitter(log logger) pendingEmitter {
+func newPendingEmitter(log logger, opts *clientOptions) pendingEmitter {
 	return pendingEmitter{
-		log: log,
+		log:  log,
+		opts: opts,
 	}
 }
 
@@ -132,7 +134,14 @@ func (q *pendingEmitter) Enqueue(msg *protocolMessage, onAck func(err error)) {
 			panic(fmt.Sprintf("protocol violation: expected next enqueued message to have msgSerial %d; got %d", expected, got))
 		}
 	}
-	q.queue = append(q.queue, msgWithAckCallback{msg, onAck})
+	q.queue = append(q.queue, msgWithAckCallback{msg: msg, onAck: onAck, sent: q.now()})
+}
+
+func (q *pendingEmitter) now() time.Time {
+	if q.opts == nil {
+		return time.Now()
+	}
+	return q.opts.Now()
 }
 
 func (q *pendingEmitter) Ack(msg *protocolMessage, errInfo *ErrorInfo) {
@@ -174,12 +183,14 @@ func (q *pendingEmitter) Ack(msg *protocolMessage, errInfo *ErrorInfo) {
 		err = errNACKWithoutError
 	}
 
+	now := q.now()
 	for i, sch := range acked {
 		err := err
 		if i < serialShift {
 			err = errImplictNACK
 		}
-		q.log.Verbosef("received %v for message serial %d", msg.Action, sch.msg.MsgSerial)
+		q.opts.metrics().MessageAcknowledged(now.Sub(sch.sent), err)
+		q.log.with(LogAttr{Key: LogKeyMsgSerial, Value: sch.msg.MsgSerial}).Verbosef("received %v for message serial %d", msg.Action, sch.msg.MsgSerial)
 		if sch.onAck != nil {
 			sch.onAck(err)
 		}
@@ -189,6 +200,7 @@ func (q *pendingEmitter) Ack(msg *protocolMessage, errInfo *ErrorInfo) {
 type msgWithAckCallback struct {
 	msg   *protocolMessage
 	onAck func(err error)
+	sent  time.Time // when msg was sent, if pending
 }
 
 type msgQueue struct {
@@ -206,17 +218,22 @@ func newMsgQueue(conn *Connection) *msgQueue {
 func (q *msgQueue) Enqueue(msg *protocolMessage, onAck func(err error)) {
 	q.mtx.Lock()
 	// TODO(rjeczalik): reorder the queue so Presence / Messages can be merged
-	q.queue = append(q.queue, msgWithAckCallback{msg, onAck})
+	q.queue = append(q.queue, msgWithAckCallback{msg: msg, onAck: onAck})
 	q.mtx.Unlock()
+	q.metrics().QueueLengthChanged(1)
 }
 
 func (q *msgQueue) Flush() {
 	q.mtx.Lock()
+	n := len(q.queue)
 	for _, queueMsg := range q.queue {
 		q.conn.send(queueMsg.msg, queueMsg.onAck)
 	}
 	q.queue = nil
 	q.mtx.Unlock()
+	if n > 0 {
+		q.metrics().QueueLengthChanged(-n)
+	}
 }
 
 func (q *msgQueue) Fail(err error) {
@@ -227,14 +244,25 @@ func (q *msgQueue) Fail(err error) {
 			queueMsg.onAck(newError(90000, err))
 		}
 	}
+	n := len(q.queue)
 	q.queue = nil
 	q.mtx.Unlock()
+	if n > 0 {
+		q.metrics().QueueLengthChanged(-n)
+	}
 }
 
 func (q *msgQueue) log() logger {
 	return q.conn.log()
 }
 
+func (q *msgQueue) metrics() Metrics {
+	if q.conn == nil {
+		return NopMetrics{}
+	}
+	return q.conn.opts.metrics()
+}
+
 var nopResult *errResult
 
 type errResult struct {
@@ -42,6 +42,13 @@ type TokenParams struct {
 	// timestamp is a "one-time" value, and is valid in a request, but is not validly a member of
 	// any default token params such as ClientOptions.defaultTokenParams (RSA9d, Tk2d).
 	Timestamp int64 `json:"timestamp,omitempty" codec:"timestamp,omitempty"`
+
+	// RevocationKey is an identifier shared by a group of tokens, so that they
+	// can all be revoked at once with [ably.Auth.RevokeTokens] and
+	// [ably.RevokeRevocationKey]. Tokens are only revocable if the issuing API
+	// key has revocable tokens enabled. It's sent with token requests, but
+	// isn't covered by their MAC (RSA9g).
+	RevocationKey string `json:"revocationKey,omitempty" codec:"revocationKey,omitempty"`
 }
 
 // Query encodes the params to query params value. If a field of params is
@@ -63,6 +70,9 @@ func (params *TokenParams) Query() url.Values {
 	if params.Timestamp != 0 {
 		q.Set("timestamp", strconv.FormatInt(params.Timestamp, 10))
 	}
+	if params.RevocationKey != "" {
+		q.Set("revocationKey", params.RevocationKey)
+	}
 	return q
 }
 
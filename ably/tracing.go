@@ -0,0 +1,185 @@
+package ably
+
+import (
+	"context"
+)
+
+// A Tracer creates the spans of a distributed tracing system, such as
+// OpenTelemetry, for operations made by a client. See [ably.WithTracer].
+//
+// The github.com/ably/ably-go/ablyotel module provides a Tracer backed by
+// OpenTelemetry.
+type Tracer interface {
+	// Start starts a span named name, as a child of the span in ctx if any.
+	// It returns a context holding the new span.
+	Start(ctx context.Context, name string, attributes ...TraceAttribute) (context.Context, Span)
+
+	// Inject writes the trace context held by ctx to headers, e.g. as W3C
+	// traceparent and tracestate headers.
+	Inject(ctx context.Context, headers map[string]string)
+
+	// Extract returns a copy of ctx holding the trace context read from
+	// headers, as written by Inject.
+	Extract(ctx context.Context, headers map[string]string) context.Context
+}
+
+// A Span is an operation being traced, started by a [ably.Tracer].
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attributes ...TraceAttribute)
+
+	// End ends the span. If err isn't nil, the operation failed with it.
+	End(err error)
+}
+
+// A TraceAttribute is a key-value pair describing a span. Value is a string,
+// bool, int or int64.
+type TraceAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Names of the spans started by the client.
+const (
+	SpanRESTRequest = "ably.rest.request"
+	SpanRESTAttempt = "ably.rest.attempt"
+	SpanPublish     = "ably.publish"
+	SpanAttach      = "ably.attach"
+	SpanDetach      = "ably.detach"
+	SpanPresence    = "ably.presence"
+	SpanPresenceGet = "ably.presence.get"
+)
+
+// Keys of the attributes of the spans started by the client.
+const (
+	attrMessagingSystem = "messaging.system"
+	attrChannel         = "ably.channel"
+	attrMessageCount    = "ably.message_count"
+	attrPresenceAction  = "ably.presence.action"
+	attrClientID        = "ably.client_id"
+	attrFallback        = "ably.fallback"
+	attrAttempt         = "ably.attempt"
+	attrHTTPMethod      = "http.request.method"
+	attrHTTPStatusCode  = "http.response.status_code"
+	attrURLPath         = "url.path"
+	attrServerAddress   = "server.address"
+)
+
+// traceHeadersExtra is the key of the message extras holding the headers
+// that trace context is injected into (TM2i).
+const traceHeadersExtra = "headers"
+
+// WithTracer makes the client trace its operations with tracer:
+//
+//   - REST requests, with a span per attempt, including attempts against
+//     fallback hosts.
+//   - Realtime publishes, from the message being sent until Ably acknowledges
+//     it.
+//   - Realtime channel attaches and detaches.
+//   - Realtime presence operations.
+//
+// The trace context of published messages is injected into their extras
+// headers, so that subscribers can continue the publisher's trace with
+// [ably.MessageTraceContext].
+func WithTracer(tracer Tracer) ClientOption {
+	return func(os *clientOptions) {
+		os.Tracer = tracer
+	}
+}
+
+// MessageTraceContext returns a copy of ctx holding the trace context that
+// the publisher of m injected into its extras headers, if any, so that spans
+// started from it continue the publisher's trace.
+func MessageTraceContext(ctx context.Context, tracer Tracer, m *Message) context.Context {
+	headers, ok := m.Extras[traceHeadersExtra].(map[string]interface{})
+	if !ok {
+		return ctx
+	}
+	h := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if s, ok := v.(string); ok {
+			h[k] = s
+		}
+	}
+	return tracer.Extract(ctx, h)
+}
+
+// tracer wraps the configured Tracer, doing nothing if there's none.
+type tracer struct {
+	t Tracer
+}
+
+func (c *clientOptions) tracer() tracer {
+	if c == nil {
+		return tracer{}
+	}
+	return tracer{t: c.Tracer}
+}
+
+func (t tracer) start(ctx context.Context, name string, attributes ...TraceAttribute) (context.Context, span) {
+	if t.t == nil {
+		return ctx, span{}
+	}
+	attributes = append(attributes, TraceAttribute{Key: attrMessagingSystem, Value: "ably"})
+	ctx, s := t.t.Start(ctx, name, attributes...)
+	return ctx, span{s: s}
+}
+
+// injectMessages returns copies of messages with the trace context held by
+// ctx injected into their extras headers.
+func (t tracer) injectMessages(ctx context.Context, messages []*Message) []*Message {
+	if t.t == nil {
+		return messages
+	}
+	headers := map[string]string{}
+	t.t.Inject(ctx, headers)
+	if len(headers) == 0 {
+		return messages
+	}
+	injected := make([]*Message, len(messages))
+	for i, m := range messages {
+		copied := *m
+		copied.Extras = make(map[string]interface{}, len(m.Extras)+1)
+		for k, v := range m.Extras {
+			copied.Extras[k] = v
+		}
+		h := map[string]interface{}{}
+		switch existing := m.Extras[traceHeadersExtra].(type) {
+		case map[string]interface{}:
+			for k, v := range existing {
+				h[k] = v
+			}
+		case map[string]string:
+			for k, v := range existing {
+				h[k] = v
+			}
+		}
+		for k, v := range headers {
+			h[k] = v
+		}
+		copied.Extras[traceHeadersExtra] = h
+		injected[i] = &copied
+	}
+	return injected
+}
+
+// span wraps a Span, doing nothing if there's none.
+type span struct {
+	s Span
+}
+
+func (s span) setAttributes(attributes ...TraceAttribute) {
+	if s.s != nil {
+		s.s.SetAttributes(attributes...)
+	}
+}
+
+func (s span) end(err error) {
+	if s.s != nil {
+		s.s.End(err)
+	}
+}
+
+func attr(key string, value interface{}) TraceAttribute {
+	return TraceAttribute{Key: key, Value: value}
+}
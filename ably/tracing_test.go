@@ -0,0 +1,179 @@
+//go:build !integration
+// +build !integration
+
+package ably_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTracer is a Tracer that records the spans it starts, and injects
+// the name of the span in the context as trace context.
+type recordingTracer struct {
+	mtx   sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+type spanKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attributes ...ably.TraceAttribute) (context.Context, ably.Span) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s := &recordedSpan{name: name, attributes: map[string]interface{}{}}
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		s.parent = parent
+	}
+	for _, a := range attributes {
+		s.attributes[a.Key] = a.Value
+	}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, name), &recordingSpan{t: t, s: s}
+}
+
+func (t *recordingTracer) Inject(ctx context.Context, headers map[string]string) {
+	if name, ok := ctx.Value(spanKey{}).(string); ok {
+		headers["span"] = name
+	}
+}
+
+func (t *recordingTracer) Extract(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, spanKey{}, headers["span"])
+}
+
+func (t *recordingTracer) recorded() []recordedSpan {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	var spans []recordedSpan
+	for _, s := range t.spans {
+		spans = append(spans, *s)
+	}
+	return spans
+}
+
+type recordingSpan struct {
+	t *recordingTracer
+	s *recordedSpan
+}
+
+func (s *recordingSpan) SetAttributes(attributes ...ably.TraceAttribute) {
+	s.t.mtx.Lock()
+	defer s.t.mtx.Unlock()
+	for _, a := range attributes {
+		s.s.attributes[a.Key] = a.Value
+	}
+}
+
+func (s *recordingSpan) End(err error) {
+	s.t.mtx.Lock()
+	defer s.t.mtx.Unlock()
+	s.s.ended = true
+	s.s.err = err
+}
+
+func TestWithTracer_Realtime(t *testing.T) {
+	in := make(chan *ably.ProtocolMessage, 1)
+	out := make(chan *ably.ProtocolMessage, 16)
+	tracer := &recordingTracer{}
+	c, err := ably.NewRealtime(
+		ably.WithToken("fake:token"),
+		ably.WithAutoConnect(false),
+		ably.WithDial(MessagePipe(in, out)),
+		ably.WithTracer(tracer),
+	)
+	assert.NoError(t, err)
+	in <- &ably.ProtocolMessage{
+		Action:            ably.ActionConnected,
+		ConnectionID:      "connection-id",
+		ConnectionDetails: &ably.ConnectionDetails{ClientID: "tracer"},
+	}
+	err = ablytest.Wait(ablytest.ConnWaiter(c, c.Connect, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	channel := c.Channels.Get("traced")
+	ctx := context.WithValue(context.Background(), spanKey{}, "caller")
+
+	t.Run("attach", func(t *testing.T) {
+		attached := make(chan error, 1)
+		go func() {
+			attached <- channel.Attach(ctx)
+		}()
+		var msg *ably.ProtocolMessage
+		ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+		in <- &ably.ProtocolMessage{Action: ably.ActionAttached, Channel: channel.Name}
+		ablytest.Soon.Recv(t, &err, attached, t.Fatalf)
+		assert.NoError(t, err)
+
+		spans := tracer.recorded()
+		assert.Equal(t, ably.SpanAttach, spans[0].name)
+		assert.Equal(t, "caller", spans[0].parent)
+		assert.Equal(t, "traced", spans[0].attributes["ably.channel"])
+		assert.True(t, spans[0].ended)
+	})
+
+	t.Run("publish until ACK", func(t *testing.T) {
+		message := &ably.Message{Name: "event", Data: "data"}
+		published := make(chan error, 1)
+		go func() {
+			published <- channel.PublishMultiple(ctx, []*ably.Message{message})
+		}()
+		var msg *ably.ProtocolMessage
+		ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+		assert.Equal(t, map[string]interface{}{"span": ably.SpanPublish}, msg.Messages[0].Extras["headers"])
+		assert.Nil(t, message.Extras, "the published message must not be modified")
+
+		spans := tracer.recorded()
+		publish := spans[len(spans)-1]
+		assert.Equal(t, ably.SpanPublish, publish.name)
+		assert.Equal(t, "caller", publish.parent)
+		assert.False(t, publish.ended, "span must wait for ACK")
+
+		in <- &ably.ProtocolMessage{Action: ably.ActionAck, MsgSerial: msg.MsgSerial, Count: 1}
+		ablytest.Soon.Recv(t, &err, published, t.Fatalf)
+		assert.NoError(t, err)
+		spans = tracer.recorded()
+		assert.True(t, spans[len(spans)-1].ended)
+
+		// A subscriber continues the trace from the message.
+		received := ably.MessageTraceContext(context.Background(), tracer, msg.Messages[0])
+		assert.Equal(t, ably.SpanPublish, received.Value(spanKey{}))
+	})
+
+	t.Run("presence", func(t *testing.T) {
+		entered := make(chan error, 1)
+		go func() {
+			entered <- channel.Presence.EnterClient(ctx, "tracer", "here")
+		}()
+		var msg *ably.ProtocolMessage
+		ablytest.Instantly.Recv(t, &msg, out, t.Fatalf)
+		in <- &ably.ProtocolMessage{
+			Action:    ably.ActionNack,
+			MsgSerial: msg.MsgSerial,
+			Count:     1,
+			Error:     &ably.ProtoErrorInfo{StatusCode: 400, Code: 40000, Message: "rejected"},
+		}
+		ablytest.Soon.Recv(t, &err, entered, t.Fatalf)
+		assert.Error(t, err)
+
+		spans := tracer.recorded()
+		enter := spans[len(spans)-1]
+		assert.Equal(t, ably.SpanPresence, enter.name)
+		assert.Equal(t, "ENTER", enter.attributes["ably.presence.action"])
+		assert.Equal(t, "tracer", enter.attributes["ably.client_id"])
+		assert.True(t, enter.ended)
+		assert.Error(t, enter.err)
+	})
+}
@@ -20,44 +20,68 @@ const (
 )
 
 type websocketConn struct {
-	conn  *websocket.Conn
-	proto proto
+	conn    *websocket.Conn
+	proto   proto
+	metrics Metrics
+
+	// after, if set, is the client's timer, with which Receive's timeout is
+	// measured.
+	after ablyutil.TimerFunc
+}
+
+func (ws *websocketConn) setClock(now func() time.Time, after ablyutil.TimerFunc) {
+	ws.after = after
 }
 
 func (ws *websocketConn) Send(msg *protocolMessage) error {
+	var typ websocket.MessageType
+	var p []byte
+	var err error
 	switch ws.proto {
 	case jsonProto:
-		p, err := json.Marshal(msg)
-		if err != nil {
-			return err
-		}
-		return ws.conn.Write(context.Background(), websocket.MessageText, p)
+		typ = websocket.MessageText
+		p, err = json.Marshal(msg)
 	case msgpackProto:
-		p, err := ablyutil.MarshalMsgpack(msg)
-		if err != nil {
-			return err
-		}
-		return ws.conn.Write(context.Background(), websocket.MessageBinary, p)
+		typ = websocket.MessageBinary
+		p, err = ablyutil.MarshalMsgpack(msg)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := ws.conn.Write(context.Background(), typ, p); err != nil {
+		return err
+	}
+	if ws.metrics != nil {
+		ws.metrics.BytesSent(len(p))
 	}
 	return nil
 }
 
-func (ws *websocketConn) Receive(deadline time.Time) (*protocolMessage, error) {
+func (ws *websocketConn) Receive(timeout time.Duration) (*protocolMessage, error) {
 	msg := &protocolMessage{}
 	var (
 		ctx    context.Context
 		cancel context.CancelFunc
 	)
-	if deadline.IsZero() {
+	switch {
+	case timeout <= 0:
 		ctx = context.Background()
-	} else {
-		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+	case ws.after != nil:
+		ctx, cancel = ablyutil.ContextWithTimeout(context.Background(), ws.after, timeout)
+		defer cancel()
+	default:
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 	}
 	_, data, err := ws.conn.Read(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if ws.metrics != nil {
+		ws.metrics.BytesReceived(len(data))
+	}
 	switch ws.proto {
 	case jsonProto:
 		err := json.Unmarshal(data, msg)
@@ -166,7 +166,7 @@ func TestWebsocketSendAndReceive(t *testing.T) {
 			}
 
 			ws.Send(&msg)
-			result, err := ws.Receive(time.Now().Add(timeout))
+			result, err := ws.Receive(timeout)
 
 			assert.Equal(t, test.expectedErr, err)
 			assert.Equal(t, test.expectedMessageType, result.Messages[0].Name)
@@ -0,0 +1,27 @@
+module github.com/ably/ably-go/ablyotel
+
+go 1.23
+
+require (
+	github.com/ably/ably-go v1.2.19
+	go.opentelemetry.io/otel v1.31.0
+	go.opentelemetry.io/otel/sdk v1.31.0
+	go.opentelemetry.io/otel/trace v1.31.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.10.3 // indirect
+	github.com/ugorji/go/codec v1.1.9 // indirect
+	go.opentelemetry.io/otel/metric v1.31.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	nhooyr.io/websocket v1.8.7 // indirect
+)
+
+// The replace directive builds against the enclosing tree during development;
+// consumers of this module get the version required above, the latest ably-go
+// release. It must be bumped to the release that adds the Tracer API before
+// this module is tagged.
+replace github.com/ably/ably-go => ../
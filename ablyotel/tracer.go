@@ -0,0 +1,126 @@
+// Package ablyotel traces Ably clients with OpenTelemetry.
+//
+//	client, err := ably.NewRealtime(
+//		ably.WithKey(key),
+//		ably.WithTracer(ablyotel.NewTracer()),
+//	)
+//
+// Spans are started with the global tracer provider and trace context is
+// propagated in the W3C format, unless set otherwise with [WithTracerProvider]
+// and [WithPropagator].
+package ablyotel
+
+import (
+	"context"
+
+	"github.com/ably/ably-go/ably"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans started by Tracer.
+const instrumentationName = "github.com/ably/ably-go"
+
+// Tracer is an [ably.Tracer] backed by OpenTelemetry.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// An Option configures a Tracer.
+type Option func(*Tracer)
+
+// WithTracerProvider sets the provider of the OpenTelemetry tracer spans are
+// started with. The default is the global provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+// WithPropagator sets how trace context is written to and read from message
+// headers. The default is W3C Trace Context.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = propagator
+	}
+}
+
+// NewTracer returns a Tracer to pass to [ably.WithTracer].
+func NewTracer(options ...Option) *Tracer {
+	t := &Tracer{
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+		propagator: propagation.TraceContext{},
+	}
+	for _, o := range options {
+		o(t)
+	}
+	return t
+}
+
+// Start implements [ably.Tracer].
+func (t *Tracer) Start(ctx context.Context, name string, attributes ...ably.TraceAttribute) (context.Context, ably.Span) {
+	kind := trace.SpanKindClient
+	if name == ably.SpanPublish {
+		kind = trace.SpanKindProducer
+	}
+	ctx, s := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(convertAttributes(attributes)...),
+	)
+	return ctx, span{s: s}
+}
+
+// Inject implements [ably.Tracer].
+func (t *Tracer) Inject(ctx context.Context, headers map[string]string) {
+	t.propagator.Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract implements [ably.Tracer].
+func (t *Tracer) Extract(ctx context.Context, headers map[string]string) context.Context {
+	return t.propagator.Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// MessageContext returns a copy of ctx holding the trace context of the
+// publisher of m, so that spans started from it, e.g. to process m, continue
+// the publisher's trace.
+func (t *Tracer) MessageContext(ctx context.Context, m *ably.Message) context.Context {
+	return ably.MessageTraceContext(ctx, t, m)
+}
+
+type span struct {
+	s trace.Span
+}
+
+func (s span) SetAttributes(attributes ...ably.TraceAttribute) {
+	s.s.SetAttributes(convertAttributes(attributes)...)
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
+
+func convertAttributes(attributes []ably.TraceAttribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		}
+	}
+	return kvs
+}
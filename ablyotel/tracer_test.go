@@ -0,0 +1,116 @@
+//go:build !integration
+// +build !integration
+
+package ablyotel_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablyotel"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	var published []*ably.Message
+	status := http.StatusCreated
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&published); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := ablyotel.NewTracer(ablyotel.WithTracerProvider(provider))
+	client, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithTLS(false),
+		ably.WithRESTHost(serverURL.Hostname()),
+		ably.WithPort(port),
+		ably.WithUseBinaryProtocol(false),
+		ably.WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	err = client.Channels.Get("traced").Publish(ctx, "event", "data")
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	attempt, request := spans[0], spans[1]
+	if got := request.Name(); got != ably.SpanRESTRequest {
+		t.Errorf("expected request span, got %q", got)
+	}
+	if got, want := request.Parent().SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Errorf("expected request span to be child of %v, got %v", want, got)
+	}
+	if got := attempt.Name(); got != ably.SpanRESTAttempt {
+		t.Errorf("expected attempt span, got %q", got)
+	}
+	if got, want := attempt.Parent().SpanID(), request.SpanContext().SpanID(); got != want {
+		t.Errorf("expected attempt span to be child of %v, got %v", want, got)
+	}
+	if !hasAttribute(attempt.Attributes(), attribute.Int("http.response.status_code", http.StatusCreated)) {
+		t.Errorf("expected status code attribute, got %v", attempt.Attributes())
+	}
+	if got := attempt.SpanKind(); got != trace.SpanKindClient {
+		t.Errorf("expected client span, got %v", got)
+	}
+
+	// Subscribers continue the publisher's trace.
+	if len(published) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(published))
+	}
+	received := tracer.MessageContext(context.Background(), published[0])
+	if got, want := trace.SpanContextFromContext(received).TraceID(), parent.SpanContext().TraceID(); got != want {
+		t.Errorf("expected trace ID %v from message headers %v, got %v", want, published[0].Extras, got)
+	}
+
+	t.Run("records errors", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider.RegisterSpanProcessor(recorder)
+		status = http.StatusBadRequest
+		err := client.Channels.Get("traced").Publish(context.Background(), "event", "data")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		for _, s := range recorder.Ended() {
+			if s.Status().Code != codes.Error {
+				t.Errorf("expected span %q to have failed, got %v", s.Name(), s.Status())
+			}
+		}
+	})
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attributes {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+// Package ablyprometheus exports measurements of Ably clients as Prometheus
+// metrics.
+//
+//	collector := ablyprometheus.NewCollector()
+//	prometheus.MustRegister(collector)
+//	client, err := ably.NewRealtime(
+//		ably.WithKey(key),
+//		ably.WithMetrics(collector),
+//	)
+//
+// A Collector can be shared by several clients, whose measurements are then
+// added up.
+package ablyprometheus
+
+import (
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is an [ably.Metrics] that exports measurements as Prometheus
+// metrics. It must be registered with a Prometheus registry.
+type Collector struct {
+	ably.NopMetrics
+
+	namespace    string
+	constLabels  prometheus.Labels
+	channelLabel bool
+	buckets      []float64
+
+	connectionStates *prometheus.CounterVec
+	reconnects       prometheus.Counter
+	channelStates    *prometheus.CounterVec
+	messagesSent     *prometheus.CounterVec
+	messagesReceived *prometheus.CounterVec
+	ackLatency       *prometheus.HistogramVec
+	queuedMessages   prometheus.Gauge
+	fallbacks        *prometheus.CounterVec
+	bytesSent        prometheus.Counter
+	bytesReceived    prometheus.Counter
+}
+
+// An Option configures a Collector.
+type Option func(*Collector)
+
+// WithNamespace sets the prefix of the metrics' names. The default is "ably".
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels sets labels added to every metric, e.g. to tell apart
+// clients sharing a registry through different collectors.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *Collector) {
+		c.constLabels = labels
+	}
+}
+
+// WithChannelLabel makes channel and message metrics labelled with the
+// channel name. It's disabled by default, as every channel then creates new
+// time series; only enable it if clients use few channels.
+func WithChannelLabel() Option {
+	return func(c *Collector) {
+		c.channelLabel = true
+	}
+}
+
+// WithLatencyBuckets sets the buckets, in seconds, of the histogram of the
+// time taken by Ably to acknowledge messages. The default is
+// prometheus.DefBuckets.
+func WithLatencyBuckets(buckets []float64) Option {
+	return func(c *Collector) {
+		c.buckets = buckets
+	}
+}
+
+// Labels of the metrics.
+const (
+	labelState   = "state"
+	labelChannel = "channel"
+	labelResult  = "result"
+	labelHost    = "host"
+)
+
+// NewCollector returns a Collector configured by opts.
+func NewCollector(opts ...Option) *Collector {
+	c := &Collector{
+		namespace: "ably",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	var channel []string
+	if c.channelLabel {
+		channel = []string{labelChannel}
+	}
+	c.connectionStates = prometheus.NewCounterVec(c.counterOpts(
+		"connection_state_changes_total",
+		"Number of times the Realtime connection changed state, by new state.",
+	), []string{labelState})
+	c.reconnects = prometheus.NewCounter(c.counterOpts(
+		"connection_reconnects_total",
+		"Number of times the Realtime connection tried to reconnect after being disconnected or suspended.",
+	))
+	c.channelStates = prometheus.NewCounterVec(c.counterOpts(
+		"channel_state_changes_total",
+		"Number of times Realtime channels changed state, by new state.",
+	), append([]string{labelState}, channel...))
+	c.messagesSent = prometheus.NewCounterVec(c.counterOpts(
+		"messages_sent_total",
+		"Number of messages sent on the Realtime connection.",
+	), channel)
+	c.messagesReceived = prometheus.NewCounterVec(c.counterOpts(
+		"messages_received_total",
+		"Number of messages received on the Realtime connection.",
+	), channel)
+	c.ackLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   c.namespace,
+		Name:        "message_ack_latency_seconds",
+		Help:        "Time taken by Ably to acknowledge messages sent on the Realtime connection, by result (ack or nack).",
+		ConstLabels: c.constLabels,
+		Buckets:     c.buckets,
+	}, []string{labelResult})
+	c.queuedMessages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Name:        "queued_messages",
+		Help:        "Number of messages queued until the Realtime connection can send them.",
+		ConstLabels: c.constLabels,
+	})
+	c.fallbacks = prometheus.NewCounterVec(c.counterOpts(
+		"rest_fallback_attempts_total",
+		"Number of REST requests retried against a fallback host, by host.",
+	), []string{labelHost})
+	c.bytesSent = prometheus.NewCounter(c.counterOpts(
+		"bytes_sent_total",
+		"Number of bytes sent to Ably in REST request bodies and on the Realtime connection.",
+	))
+	c.bytesReceived = prometheus.NewCounter(c.counterOpts(
+		"bytes_received_total",
+		"Number of bytes received from Ably in REST response bodies and on the Realtime connection.",
+	))
+	return c
+}
+
+func (c *Collector) counterOpts(name, help string) prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Name:        name,
+		Help:        help,
+		ConstLabels: c.constLabels,
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.connectionStates,
+		c.reconnects,
+		c.channelStates,
+		c.messagesSent,
+		c.messagesReceived,
+		c.ackLatency,
+		c.queuedMessages,
+		c.fallbacks,
+		c.bytesSent,
+		c.bytesReceived,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) channelLabels(channel string) []string {
+	if !c.channelLabel {
+		return nil
+	}
+	return []string{channel}
+}
+
+// ConnectionStateChanged implements ably.Metrics.
+func (c *Collector) ConnectionStateChanged(change ably.ConnectionStateChange) {
+	c.connectionStates.WithLabelValues(change.Current.String()).Inc()
+	if change.Current == ably.ConnectionStateConnecting &&
+		(change.Previous == ably.ConnectionStateDisconnected || change.Previous == ably.ConnectionStateSuspended) {
+		c.reconnects.Inc()
+	}
+}
+
+// ChannelStateChanged implements ably.Metrics.
+func (c *Collector) ChannelStateChanged(channel string, change ably.ChannelStateChange) {
+	c.channelStates.WithLabelValues(append([]string{change.Current.String()}, c.channelLabels(channel)...)...).Inc()
+}
+
+// MessagesSent implements ably.Metrics.
+func (c *Collector) MessagesSent(channel string, count int) {
+	c.messagesSent.WithLabelValues(c.channelLabels(channel)...).Add(float64(count))
+}
+
+// MessagesReceived implements ably.Metrics.
+func (c *Collector) MessagesReceived(channel string, count int) {
+	c.messagesReceived.WithLabelValues(c.channelLabels(channel)...).Add(float64(count))
+}
+
+// MessageAcknowledged implements ably.Metrics.
+func (c *Collector) MessageAcknowledged(latency time.Duration, err error) {
+	result := "ack"
+	if err != nil {
+		result = "nack"
+	}
+	c.ackLatency.WithLabelValues(result).Observe(latency.Seconds())
+}
+
+// QueueLengthChanged implements ably.Metrics.
+func (c *Collector) QueueLengthChanged(delta int) {
+	c.queuedMessages.Add(float64(delta))
+}
+
+// RESTFallbackAttempted implements ably.Metrics.
+func (c *Collector) RESTFallbackAttempted(host string) {
+	c.fallbacks.WithLabelValues(host).Inc()
+}
+
+// BytesSent implements ably.Metrics.
+func (c *Collector) BytesSent(n int) {
+	c.bytesSent.Add(float64(n))
+}
+
+// BytesReceived implements ably.Metrics.
+func (c *Collector) BytesReceived(n int) {
+	c.bytesReceived.Add(float64(n))
+}
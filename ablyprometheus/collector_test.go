@@ -0,0 +1,127 @@
+//go:build !integration
+// +build !integration
+
+package ablyprometheus_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablyprometheus"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	collector := ablyprometheus.NewCollector(ablyprometheus.WithChannelLabel())
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []ably.ConnectionStateChange{
+		{Previous: ably.ConnectionStateInitialized, Current: ably.ConnectionStateConnecting},
+		{Previous: ably.ConnectionStateConnecting, Current: ably.ConnectionStateConnected},
+		{Previous: ably.ConnectionStateConnected, Current: ably.ConnectionStateDisconnected},
+		{Previous: ably.ConnectionStateDisconnected, Current: ably.ConnectionStateConnecting},
+	} {
+		collector.ConnectionStateChanged(change)
+	}
+	collector.ChannelStateChanged("a", ably.ChannelStateChange{Current: ably.ChannelStateAttached})
+	collector.MessagesSent("a", 3)
+	collector.MessagesReceived("a", 2)
+	collector.MessagesReceived("b", 1)
+	collector.MessageAcknowledged(100*time.Millisecond, nil)
+	collector.MessageAcknowledged(time.Second, errors.New("rejected"))
+	collector.QueueLengthChanged(5)
+	collector.QueueLengthChanged(-2)
+	collector.RESTFallbackAttempted("a.ably-realtime.com")
+
+	expected := `
+# HELP ably_connection_reconnects_total Number of times the Realtime connection tried to reconnect after being disconnected or suspended.
+# TYPE ably_connection_reconnects_total counter
+ably_connection_reconnects_total 1
+# HELP ably_connection_state_changes_total Number of times the Realtime connection changed state, by new state.
+# TYPE ably_connection_state_changes_total counter
+ably_connection_state_changes_total{state="CONNECTED"} 1
+ably_connection_state_changes_total{state="CONNECTING"} 2
+ably_connection_state_changes_total{state="DISCONNECTED"} 1
+# HELP ably_channel_state_changes_total Number of times Realtime channels changed state, by new state.
+# TYPE ably_channel_state_changes_total counter
+ably_channel_state_changes_total{channel="a",state="ATTACHED"} 1
+# HELP ably_messages_received_total Number of messages received on the Realtime connection.
+# TYPE ably_messages_received_total counter
+ably_messages_received_total{channel="a"} 2
+ably_messages_received_total{channel="b"} 1
+# HELP ably_messages_sent_total Number of messages sent on the Realtime connection.
+# TYPE ably_messages_sent_total counter
+ably_messages_sent_total{channel="a"} 3
+# HELP ably_queued_messages Number of messages queued until the Realtime connection can send them.
+# TYPE ably_queued_messages gauge
+ably_queued_messages 3
+# HELP ably_rest_fallback_attempts_total Number of REST requests retried against a fallback host, by host.
+# TYPE ably_rest_fallback_attempts_total counter
+ably_rest_fallback_attempts_total{host="a.ably-realtime.com"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"ably_connection_reconnects_total",
+		"ably_connection_state_changes_total",
+		"ably_channel_state_changes_total",
+		"ably_messages_received_total",
+		"ably_messages_sent_total",
+		"ably_queued_messages",
+		"ably_rest_fallback_attempts_total",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(collector, "ably_message_ack_latency_seconds"); n != 2 {
+		t.Fatalf("expected ack and nack latency series, got %d", n)
+	}
+}
+
+func TestCollector_REST(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	collector := ablyprometheus.NewCollector(ablyprometheus.WithNamespace("test"))
+	client, err := ably.NewREST(
+		ably.WithToken("fake:token"),
+		ably.WithTLS(false),
+		ably.WithRESTHost(serverURL.Hostname()),
+		ably.WithPort(port),
+		ably.WithUseBinaryProtocol(false),
+		ably.WithMetrics(collector),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Channels.Get("measured").Publish(context.Background(), "event", "data"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP test_bytes_received_total Number of bytes received from Ably in REST response bodies and on the Realtime connection.
+# TYPE test_bytes_received_total counter
+test_bytes_received_total 2
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "test_bytes_received_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+module github.com/ably/ably-go/ablyprometheus
+
+go 1.23
+
+require (
+	github.com/ably/ably-go v1.2.19
+	github.com/prometheus/client_golang v1.20.5
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	github.com/ugorji/go/codec v1.1.9 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+	nhooyr.io/websocket v1.8.7 // indirect
+)
+
+// The replace directive builds against the enclosing tree during development;
+// consumers of this module get the version required above, the latest ably-go
+// release. It must be bumped to the release that adds the Metrics API before
+// this module is tagged.
+replace github.com/ably/ably-go => ../
@@ -0,0 +1,157 @@
+package ablytest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// FakeClock is an ably.Clock whose time only passes when tests advance it,
+// firing the timers the client set meanwhile. Pass it to clients with
+// ably.WithClock.
+type FakeClock struct {
+	mtx     sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{} // closed when timers are added
+}
+
+var _ ably.Clock = (*FakeClock)(nil)
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+	// stop stops the timer, reporting whether it hadn't been stopped yet,
+	// either by firing or by its context being done.
+	stop func() bool
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// After sets a timer that fires once the clock is advanced by d.
+func (c *FakeClock) After(ctx context.Context, d time.Duration) <-chan time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t := &fakeTimer{deadline: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t.ch
+	}
+	stopped := make(chan struct{})
+	var once sync.Once
+	t.stop = func() bool {
+		ok := false
+		once.Do(func() {
+			close(stopped)
+			ok = true
+		})
+		return ok
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			if t.stop() {
+				c.mtx.Lock()
+				defer c.mtx.Unlock()
+				c.removeTimer(t)
+				close(t.ch)
+			}
+		case <-stopped:
+		}
+	}()
+	c.timers = append(c.timers, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return t.ch
+}
+
+func (c *FakeClock) removeTimer(t *fakeTimer) {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i:i], c.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Advance moves the clock forward by d, firing the timers that are due by
+// then, earliest first.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	var due []*fakeTimer
+	for len(c.timers) > 0 && !c.timers[0].deadline.After(c.now) {
+		due, c.timers = append(due, c.timers[0]), c.timers[1:]
+	}
+	for _, t := range due {
+		// If stopping fails, the timer's context is done and its channel is
+		// being closed.
+		if t.stop() {
+			t.ch <- c.now
+		}
+	}
+}
+
+// AdvanceToNext moves the clock forward to the deadline of the earliest
+// pending timer, firing it, and returns by how much. Without pending timers,
+// it doesn't move the clock and returns 0.
+func (c *FakeClock) AdvanceToNext() time.Duration {
+	c.mtx.Lock()
+	if len(c.timers) == 0 {
+		c.mtx.Unlock()
+		return 0
+	}
+	next := c.timers[0].deadline
+	for _, t := range c.timers[1:] {
+		if t.deadline.Before(next) {
+			next = t.deadline
+		}
+	}
+	d := next.Sub(c.now)
+	c.mtx.Unlock()
+	c.Advance(d)
+	return d
+}
+
+// Pending returns how many timers are waiting to fire.
+func (c *FakeClock) Pending() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.timers)
+}
+
+// WaitPending blocks until at least n timers are waiting to fire, e.g. so
+// that the client has set the timer a test is about to fire by advancing
+// the clock. It fails if ctx is done first.
+func (c *FakeClock) WaitPending(ctx context.Context, n int) error {
+	for {
+		c.mtx.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.mtx.Unlock()
+		if pending >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !integration
+// +build !integration
+
+package ablytest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := ablytest.NewFakeClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	later := clock.After(context.Background(), 2*time.Second)
+	sooner := clock.After(context.Background(), time.Second)
+	cancelled := clock.After(ctx, time.Second)
+	assert.Equal(t, 3, clock.Pending())
+
+	cancel()
+	_, ok := <-cancelled
+	assert.False(t, ok, "expected the channel of a cancelled timer to be closed")
+	assert.Equal(t, 2, clock.Pending())
+
+	clock.Advance(500 * time.Millisecond)
+	ablytest.Instantly.NoRecv(t, nil, sooner, t.Fatalf)
+
+	assert.Equal(t, 500*time.Millisecond, clock.AdvanceToNext())
+	var fired time.Time
+	ablytest.Instantly.Recv(t, &fired, sooner, t.Fatalf)
+	assert.Equal(t, start.Add(time.Second), fired)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+	ablytest.Instantly.NoRecv(t, nil, later, t.Fatalf)
+
+	clock.Advance(time.Hour)
+	ablytest.Instantly.Recv(t, &fired, later, t.Fatalf)
+	assert.Equal(t, start.Add(time.Hour+time.Second), fired)
+	assert.Equal(t, 0, clock.Pending())
+}
+
+func TestFakeClock_RealtimeRequestTimeout(t *testing.T) {
+	server := ablytest.NewFakeServer()
+	defer server.Close()
+	clock := ablytest.NewFakeClock(time.Now())
+
+	client := server.NewRealtime(ably.WithClock(clock))
+	defer client.Close()
+	err := ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	// Without heartbeats from the server, the client disconnects once it
+	// hasn't received anything for realtimeRequestTimeout plus
+	// maxIdleInterval.
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+	err = clock.WaitPending(ctx, 1)
+	assert.NoError(t, err)
+	disconnected := make(chan ably.ConnectionStateChange, 1)
+	off := client.Connection.Once(ably.ConnectionEventDisconnected, func(change ably.ConnectionStateChange) {
+		disconnected <- change
+	})
+	defer off()
+	clock.Advance(10*time.Second + 15*time.Second - time.Millisecond)
+	ablytest.Instantly.NoRecv(t, nil, disconnected, t.Fatalf)
+	clock.Advance(time.Millisecond)
+	ablytest.Soon.Recv(t, nil, disconnected, t.Fatalf)
+}
@@ -21,12 +21,27 @@ type CryptoData struct {
 }
 
 func LoadCryptoData(rel string) (*CryptoData, []byte, []byte, error) {
-	data := &CryptoData{}
 	f, err := os.Open(filepath.Join("..", "common", filepath.FromSlash(rel)))
 	if err != nil {
 		return nil, nil, nil, errors.New("missing common subrepo - ensure git submodules are initialized")
 	}
-	err = json.NewDecoder(f).Decode(data)
+	return loadCryptoData(f)
+}
+
+// LoadLocalCryptoData loads crypto fixtures that are kept in this repository's
+// ablytest/testdata directory, for cipher modes not covered by the common
+// subrepo, such as AES-GCM.
+func LoadLocalCryptoData(rel string) (*CryptoData, []byte, []byte, error) {
+	f, err := os.Open(filepath.Join("..", "ablytest", "testdata", filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, nil, nil, errors.New("unable to open test fixture: " + err.Error())
+	}
+	return loadCryptoData(f)
+}
+
+func loadCryptoData(f *os.File) (*CryptoData, []byte, []byte, error) {
+	data := &CryptoData{}
+	err := json.NewDecoder(f).Decode(data)
 	f.Close()
 	if err != nil {
 		return nil, nil, nil, errors.New("unable to unmarshal test cases: " + err.Error())
@@ -0,0 +1,1052 @@
+package ablytest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"nhooyr.io/websocket"
+)
+
+// FakeServer is an in-process fake of Ably, serving both the Realtime
+// protocol over WebSocket and the REST endpoints used by this library, with
+// state held in memory. It lets tests run without network access to
+// Ably's sandbox.
+//
+// It implements:
+//
+//   - Realtime: connecting, resuming, heartbeats, attaching and detaching,
+//     publishing with ACKs, entering, updating and leaving presence with
+//     presence SYNC on attach, reauthorizing with AUTH and closing.
+//   - REST: time, requestToken, publishing, message history, presence,
+//     presence history and stats.
+//
+// Clients authenticate with tokens, which FakeServer issues for token
+// requests signed with its key; see FakeServer.Options. A connection whose
+// token expires is disconnected, as by Ably, until the client
+// reauthorizes. Only the JSON protocol is supported.
+//
+// As with Ably, a dropped connection can be resumed for connectionStateTtl,
+// and its presence members only leave if it isn't resumed within 15
+// seconds; they leave right away when it's closed. Channels attached again
+// with a channel serial, as clients do once they resume, get the messages
+// published since then.
+type FakeServer struct {
+	keyName   string
+	keySecret string
+
+	server *httptest.Server
+
+	mtx      sync.Mutex
+	nextID   int
+	tokens   map[string]*ably.TokenDetails
+	channels map[string]*fakeChannel
+	conns    map[string]*fakeConn // by connection key
+	stats    []*ably.Stats
+}
+
+// Protocol message actions, as in the ably package.
+const (
+	fakeActionHeartbeat = iota
+	fakeActionAck
+	fakeActionNack
+	fakeActionConnect
+	fakeActionConnected
+	fakeActionDisconnect
+	fakeActionDisconnected
+	fakeActionClose
+	fakeActionClosed
+	fakeActionError
+	fakeActionAttach
+	fakeActionAttached
+	fakeActionDetach
+	fakeActionDetached
+	fakeActionPresence
+	fakeActionMessage
+	fakeActionSync
+	fakeActionAuth
+)
+
+// Presence message actions, as in the ably package.
+const (
+	fakePresenceAbsent = iota
+	fakePresencePresent
+	fakePresenceEnter
+	fakePresenceLeave
+	fakePresenceUpdate
+)
+
+// Protocol message flags, as in the ably package.
+const (
+	fakeFlagHasPresence       = 1 << 0
+	fakeFlagAttachResume      = 1 << 5
+	fakeFlagPresence          = 1 << 16
+	fakeFlagPublish           = 1 << 17
+	fakeFlagSubscribe         = 1 << 18
+	fakeFlagPresenceSubscribe = 1 << 19
+)
+
+// fakeMaxIdleInterval is how often heartbeats are sent to connections.
+const fakeMaxIdleInterval = 15 * time.Second
+
+// fakeConnectionStateTTL is how long a dropped connection can be resumed.
+const fakeConnectionStateTTL = 2 * time.Minute
+
+// fakePresenceGracePeriod is how long the presence members of a dropped
+// connection stay present, waiting for it to be resumed.
+const fakePresenceGracePeriod = 15 * time.Second
+
+type fakeProtocolMessage struct {
+	Action            int                      `json:"action"`
+	ID                string                   `json:"id,omitempty"`
+	ConnectionID      string                   `json:"connectionId,omitempty"`
+	Channel           string                   `json:"channel,omitempty"`
+	ChannelSerial     string                   `json:"channelSerial,omitempty"`
+	ConnectionDetails *fakeConnectionDetails   `json:"connectionDetails,omitempty"`
+	Error             *fakeError               `json:"error,omitempty"`
+	MsgSerial         int64                    `json:"msgSerial"`
+	Timestamp         int64                    `json:"timestamp,omitempty"`
+	Count             int                      `json:"count,omitempty"`
+	Flags             int64                    `json:"flags,omitempty"`
+	Messages          []map[string]interface{} `json:"messages,omitempty"`
+	Presence          []map[string]interface{} `json:"presence,omitempty"`
+	Auth              *fakeAuthDetails         `json:"auth,omitempty"`
+}
+
+type fakeConnectionDetails struct {
+	ClientID           string `json:"clientId,omitempty"`
+	ConnectionKey      string `json:"connectionKey,omitempty"`
+	MaxMessageSize     int64  `json:"maxMessageSize,omitempty"`
+	ConnectionStateTTL int64  `json:"connectionStateTtl,omitempty"`
+	MaxIdleInterval    int64  `json:"maxIdleInterval,omitempty"`
+}
+
+type fakeAuthDetails struct {
+	AccessToken string `json:"accessToken,omitempty"`
+}
+
+type fakeError struct {
+	StatusCode int    `json:"statusCode,omitempty"`
+	Code       int    `json:"code,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+func (e *fakeError) Error() string {
+	return e.Message
+}
+
+type fakeChannel struct {
+	serial          int
+	messages        []map[string]interface{} // oldest first
+	broadcasts      []*fakeProtocolMessage   // MESSAGEs sent to attached connections, oldest first
+	messageIDs      map[string]bool
+	members         map[string]map[string]interface{} // by connection ID and client ID
+	presenceHistory []map[string]interface{}
+	attached        map[*fakeConn]bool
+}
+
+type fakeConn struct {
+	id       string
+	key      string
+	clientID string
+	echo     bool
+	ws       *websocket.Conn
+
+	// The following fields are guarded by the server's mtx.
+
+	// expires is when the connection's token expires, in milliseconds since
+	// the epoch, or 0 if it doesn't use a token.
+	expires int64
+	// reauthorized is sent to when the connection's token is replaced.
+	reauthorized chan struct{}
+	// closing is set once the client has asked to close the connection.
+	closing bool
+	// dropTimers are run once the connection has been dropped, unless it's
+	// resumed first.
+	dropTimers []*time.Timer
+
+	mtx    sync.Mutex // serializes writes to ws
+	closed bool
+}
+
+// NewFakeServer starts a FakeServer. It must be closed with Close.
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{
+		keyName:   "fake.key",
+		keySecret: "fakeSecretOfFakeServer",
+		tokens:    map[string]*ably.TokenDetails{},
+		channels:  map[string]*fakeChannel{},
+		conns:     map[string]*fakeConn{},
+	}
+	routes := []fakeRoute{
+		{"GET", "/time", s.handleTime},
+		{"POST", "/keys/{key}/requestToken", s.handleRequestToken},
+		{"POST", "/channels/{channel}/messages", s.authenticated(s.handlePublish)},
+		{"GET", "/channels/{channel}/messages", s.authenticated(s.handleHistory)},
+		{"GET", "/channels/{channel}/history", s.authenticated(s.handleHistory)},
+		{"GET", "/channels/{channel}/presence", s.authenticated(s.handlePresence)},
+		{"GET", "/channels/{channel}/presence/history", s.authenticated(s.handlePresenceHistory)},
+		{"GET", "/stats", s.authenticated(s.handleStats)},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			s.handleRealtime(w, r)
+			return
+		}
+		for _, route := range routes {
+			if values, ok := route.match(r); ok {
+				route.handle(w, r.WithContext(context.WithValue(r.Context(), fakePathValuesKey{}, values)))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	return s
+}
+
+// fakeRoute routes requests to a REST endpoint of a FakeServer. Its pattern
+// may have wildcard segments, e.g. "/channels/{channel}/messages", whose
+// values handlers get with fakePathValue.
+type fakeRoute struct {
+	method  string
+	pattern string
+	handle  http.HandlerFunc
+}
+
+type fakePathValuesKey struct{}
+
+func (route fakeRoute) match(r *http.Request) (map[string]string, bool) {
+	if r.Method != route.method {
+		return nil, false
+	}
+	// Split the escaped path, so that wildcards match escaped slashes, e.g.
+	// in channel names.
+	segments := strings.Split(r.URL.EscapedPath(), "/")
+	patterns := strings.Split(route.pattern, "/")
+	if len(segments) != len(patterns) {
+		return nil, false
+	}
+	values := map[string]string{}
+	for i, p := range patterns {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, false
+		}
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			values[p[1:len(p)-1]] = segment
+		} else if segment != p {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// fakePathValue returns the value of the named wildcard of the route r was
+// routed by.
+func fakePathValue(r *http.Request, name string) string {
+	values, _ := r.Context().Value(fakePathValuesKey{}).(map[string]string)
+	return values[name]
+}
+
+// Close disconnects Realtime clients and stops the server.
+func (s *FakeServer) Close() {
+	s.DropConnections()
+	s.server.Close()
+}
+
+// URL returns the base URL of the server, e.g. to put a FaultProxy in front
+// of it.
+func (s *FakeServer) URL() string {
+	return s.server.URL
+}
+
+// Key returns the API key of the server's app.
+func (s *FakeServer) Key() string {
+	return s.keyName + ":" + s.keySecret
+}
+
+// Options returns the options for a client of the server, with opts
+// applied last.
+func (s *FakeServer) Options(opts ...ably.ClientOption) []ably.ClientOption {
+	host, port, _ := net.SplitHostPort(s.server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return MergeOptions([]ably.ClientOption{
+		ably.WithKey(s.Key()),
+		// Basic authentication isn't allowed without TLS.
+		ably.WithUseTokenAuth(true),
+		ably.WithTLS(false),
+		ably.WithRESTHost(host),
+		ably.WithRealtimeHost(host),
+		ably.WithPort(p),
+		ably.WithUseBinaryProtocol(false),
+		ably.WithLogLevel(DefaultLogLevel),
+	}, opts)
+}
+
+// NewRealtime returns a Realtime client of the server.
+func (s *FakeServer) NewRealtime(opts ...ably.ClientOption) *ably.Realtime {
+	client, err := ably.NewRealtime(s.Options(opts...)...)
+	if err != nil {
+		panic("ably.NewRealtime failed: " + err.Error())
+	}
+	return client
+}
+
+// NewREST returns a REST client of the server.
+func (s *FakeServer) NewREST(opts ...ably.ClientOption) *ably.REST {
+	client, err := ably.NewREST(s.Options(opts...)...)
+	if err != nil {
+		panic("ably.NewREST failed: " + err.Error())
+	}
+	return client
+}
+
+// SetStats sets the stats returned by the stats endpoint, most recent first.
+func (s *FakeServer) SetStats(stats ...*ably.Stats) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.stats = stats
+}
+
+// DropConnections abruptly drops the Realtime connections, as if the
+// network failed. Clients can then resume them.
+func (s *FakeServer) DropConnections() {
+	s.mtx.Lock()
+	var conns []*fakeConn
+	for _, c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mtx.Unlock()
+	for _, c := range conns {
+		c.ws.Close(websocket.StatusGoingAway, "connection dropped")
+	}
+}
+
+func (s *FakeServer) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+func (s *FakeServer) channel(name string) *fakeChannel {
+	ch, ok := s.channels[name]
+	if !ok {
+		ch = &fakeChannel{
+			messageIDs: map[string]bool{},
+			members:    map[string]map[string]interface{}{},
+			attached:   map[*fakeConn]bool{},
+		}
+		s.channels[name] = ch
+	}
+	return ch
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// token returns the token details of a valid token.
+func (s *FakeServer) token(token string) (*ably.TokenDetails, *fakeError) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	details, ok := s.tokens[token]
+	switch {
+	case !ok:
+		return nil, &fakeError{StatusCode: 401, Code: 40140, Message: "invalid token"}
+	case details.Expires <= nowMillis():
+		return nil, &fakeError{StatusCode: 401, Code: 40142, Message: "token expired"}
+	}
+	return details, nil
+}
+
+// authenticate checks the credentials of a request, returning the client ID
+// they're bound to.
+func (s *FakeServer) authenticate(r *http.Request) (string, *fakeError) {
+	auth := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(auth, "Bearer "):
+		token, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			return "", &fakeError{StatusCode: 401, Code: 40140, Message: "invalid token"}
+		}
+		details, ferr := s.token(string(token))
+		if ferr != nil {
+			return "", ferr
+		}
+		return details.ClientID, nil
+	case strings.HasPrefix(auth, "Basic "):
+		name, secret, _ := r.BasicAuth()
+		if name != s.keyName || secret != s.keySecret {
+			return "", &fakeError{StatusCode: 401, Code: 40101, Message: "invalid credentials"}
+		}
+		return "*", nil
+	}
+	return "", &fakeError{StatusCode: 401, Code: 40101, Message: "no credentials"}
+}
+
+func (s *FakeServer) authenticated(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.authenticate(r); err != nil {
+			writeFakeError(w, err)
+			return
+		}
+		if typ := r.Header.Get("Content-Type"); r.Body != http.NoBody && typ != "" && !strings.HasPrefix(typ, "application/json") {
+			writeFakeError(w, &fakeError{StatusCode: 415, Code: 40000, Message: "only JSON is supported"})
+			return
+		}
+		handle(w, r)
+	}
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeFakeError(w http.ResponseWriter, err *fakeError) {
+	writeFakeJSON(w, err.StatusCode, map[string]interface{}{"error": err})
+}
+
+func (s *FakeServer) handleTime(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, []int64{nowMillis()})
+}
+
+func (s *FakeServer) handleRequestToken(w http.ResponseWriter, r *http.Request) {
+	var req ably.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeError(w, &fakeError{StatusCode: 400, Code: 40000, Message: err.Error()})
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(s.keySecret))
+	fmt.Fprintln(mac, req.KeyName)
+	fmt.Fprintln(mac, req.TTL)
+	fmt.Fprintln(mac, req.Capability)
+	fmt.Fprintln(mac, req.ClientID)
+	fmt.Fprintln(mac, req.Timestamp)
+	fmt.Fprintln(mac, req.Nonce)
+	if req.KeyName != s.keyName || req.KeyName != fakePathValue(r, "key") ||
+		req.MAC != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		writeFakeError(w, &fakeError{StatusCode: 401, Code: 40101, Message: "invalid token request"})
+		return
+	}
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = int64(time.Hour / time.Millisecond)
+	}
+	capability := req.Capability
+	if capability == "" {
+		capability = `{"*":["*"]}`
+	}
+	now := nowMillis()
+	s.mtx.Lock()
+	details := &ably.TokenDetails{
+		Token:      s.newID("fake-token"),
+		KeyName:    req.KeyName,
+		Issued:     now,
+		Expires:    now + ttl,
+		ClientID:   req.ClientID,
+		Capability: capability,
+	}
+	s.tokens[details.Token] = details
+	s.mtx.Unlock()
+	writeFakeJSON(w, http.StatusOK, details)
+}
+
+func (s *FakeServer) handlePublish(w http.ResponseWriter, r *http.Request) {
+	clientID, _ := s.authenticate(r)
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeFakeError(w, &fakeError{StatusCode: 400, Code: 40000, Message: err.Error()})
+		return
+	}
+	var messages []map[string]interface{}
+	if err := json.Unmarshal(body, &messages); err != nil {
+		var message map[string]interface{}
+		if err := json.Unmarshal(body, &message); err != nil {
+			writeFakeError(w, &fakeError{StatusCode: 400, Code: 40000, Message: err.Error()})
+			return
+		}
+		messages = []map[string]interface{}{message}
+	}
+	for _, m := range messages {
+		if id, ok := m["clientId"].(string); ok && clientID != "*" && clientID != "" && id != clientID {
+			writeFakeError(w, &fakeError{StatusCode: 401, Code: 40012, Message: "mismatched clientId"})
+			return
+		}
+		if _, ok := m["clientId"]; !ok && clientID != "*" && clientID != "" {
+			m["clientId"] = clientID
+		}
+	}
+
+	s.mtx.Lock()
+	s.publish(fakePathValue(r, "channel"), s.newID("rest"), "", messages, nil)
+	s.mtx.Unlock()
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{})
+}
+
+// publish stores messages published to a channel, and broadcasts them to
+// the connections attached to it, except from if it doesn't want echoes.
+// Messages whose ID has already been published are ignored (RSL1k). It must
+// be called with mtx held.
+func (s *FakeServer) publish(channel, id, connectionID string, messages []map[string]interface{}, from *fakeConn) {
+	ch := s.channel(channel)
+	now := nowMillis()
+	var published []map[string]interface{}
+	for i, m := range messages {
+		if _, ok := m["id"]; !ok {
+			m["id"] = fmt.Sprintf("%s:%d", id, i)
+		}
+		if msgID, _ := m["id"].(string); ch.messageIDs[msgID] {
+			continue
+		} else {
+			ch.messageIDs[msgID] = true
+		}
+		if connectionID != "" {
+			m["connectionId"] = connectionID
+		}
+		if _, ok := m["timestamp"]; !ok {
+			m["timestamp"] = now
+		}
+		ch.messages = append(ch.messages, m)
+		published = append(published, m)
+	}
+	if len(published) == 0 {
+		return
+	}
+	ch.serial++
+	msg := &fakeProtocolMessage{
+		Action:        fakeActionMessage,
+		ID:            id,
+		ConnectionID:  connectionID,
+		Channel:       channel,
+		ChannelSerial: strconv.Itoa(ch.serial),
+		Timestamp:     now,
+		Messages:      published,
+	}
+	ch.broadcasts = append(ch.broadcasts, msg)
+	for c := range ch.attached {
+		if c != from || c.echo {
+			c.send(msg)
+		}
+	}
+}
+
+// presence applies presence messages sent by a connection to a channel, and
+// broadcasts them to the connections attached to it. It must be called with
+// mtx held.
+func (s *FakeServer) presence(channel, id string, from *fakeConn, messages []map[string]interface{}) {
+	ch := s.channel(channel)
+	now := nowMillis()
+	for i, m := range messages {
+		if _, ok := m["clientId"]; !ok {
+			m["clientId"] = from.clientID
+		}
+		m["id"] = fmt.Sprintf("%s:%d", id, i)
+		m["connectionId"] = from.id
+		m["timestamp"] = now
+		memberKey := from.id + ":" + fmt.Sprint(m["clientId"])
+		action, _ := m["action"].(float64)
+		switch int(action) {
+		case fakePresenceEnter, fakePresenceUpdate:
+			if _, ok := ch.members[memberKey]; !ok {
+				m["action"] = fakePresenceEnter
+			}
+			member := copyFakeMap(m)
+			member["action"] = fakePresencePresent
+			ch.members[memberKey] = member
+		case fakePresenceLeave:
+			delete(ch.members, memberKey)
+		}
+	}
+	s.broadcastPresence(channel, id, from.id, messages)
+}
+
+func (s *FakeServer) broadcastPresence(channel, id, connectionID string, messages []map[string]interface{}) {
+	ch := s.channel(channel)
+	ch.presenceHistory = append(ch.presenceHistory, messages...)
+	ch.serial++
+	msg := &fakeProtocolMessage{
+		Action:        fakeActionPresence,
+		ID:            id,
+		ConnectionID:  connectionID,
+		Channel:       channel,
+		ChannelSerial: strconv.Itoa(ch.serial),
+		Timestamp:     nowMillis(),
+		Presence:      messages,
+	}
+	for c := range ch.attached {
+		c.send(msg)
+	}
+}
+
+// leave removes the presence members of a connection from a channel. It
+// must be called with mtx held.
+func (s *FakeServer) leave(channel string, c *fakeConn) {
+	ch := s.channel(channel)
+	id := s.newID("leave")
+	var left []map[string]interface{}
+	for key, member := range ch.members {
+		if member["connectionId"] == c.id {
+			delete(ch.members, key)
+			m := copyFakeMap(member)
+			// Unlike the member's, the ID doesn't start with the connection ID,
+			// so that clients compare them by timestamp (RTP2b1).
+			m["id"] = fmt.Sprintf("%s:%d", id, len(left))
+			m["action"] = fakePresenceLeave
+			m["timestamp"] = nowMillis()
+			left = append(left, m)
+		}
+	}
+	if len(left) > 0 {
+		s.broadcastPresence(channel, id, c.id, left)
+	}
+}
+
+func copyFakeMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (s *FakeServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	messages := append([]map[string]interface{}(nil), s.channel(fakePathValue(r, "channel")).messages...)
+	s.mtx.Unlock()
+	writeFakePage(w, r, messages)
+}
+
+func (s *FakeServer) handlePresence(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	s.mtx.Lock()
+	var members []map[string]interface{}
+	for _, m := range s.channel(fakePathValue(r, "channel")).members {
+		if id := query.Get("clientId"); id != "" && m["clientId"] != id {
+			continue
+		}
+		if id := query.Get("connectionId"); id != "" && m["connectionId"] != id {
+			continue
+		}
+		members = append(members, m)
+	}
+	s.mtx.Unlock()
+	sort.Slice(members, func(i, j int) bool {
+		return fmt.Sprint(members[i]["id"]) < fmt.Sprint(members[j]["id"])
+	})
+	// Presence isn't in time order, so it isn't affected by direction.
+	query.Set("direction", "forwards")
+	r.URL.RawQuery = query.Encode()
+	writeFakePage(w, r, members)
+}
+
+func (s *FakeServer) handlePresenceHistory(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	messages := append([]map[string]interface{}(nil), s.channel(fakePathValue(r, "channel")).presenceHistory...)
+	s.mtx.Unlock()
+	writeFakePage(w, r, messages)
+}
+
+func (s *FakeServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	var stats []map[string]interface{}
+	for _, st := range s.stats {
+		p, _ := json.Marshal(st)
+		var m map[string]interface{}
+		json.Unmarshal(p, &m)
+		stats = append(stats, m)
+	}
+	s.mtx.Unlock()
+	// Stats are stored most recent first.
+	for i, j := 0, len(stats)-1; i < j; i, j = i+1, j-1 {
+		stats[i], stats[j] = stats[j], stats[i]
+	}
+	writeFakePage(w, r, stats)
+}
+
+// writeFakePage writes a page of items, stored oldest first, as selected by
+// the request's start, end, direction, limit and offset parameters, with a
+// link to the next page.
+func writeFakePage(w http.ResponseWriter, r *http.Request, items []map[string]interface{}) {
+	query := r.URL.Query()
+	if start, err := strconv.ParseInt(query.Get("start"), 10, 64); err == nil {
+		items = filterFakeItems(items, func(ts int64) bool { return ts >= start })
+	}
+	if end, err := strconv.ParseInt(query.Get("end"), 10, 64); err == nil {
+		items = filterFakeItems(items, func(ts int64) bool { return ts <= end })
+	}
+	if query.Get("direction") != "forwards" {
+		reversed := make([]map[string]interface{}, len(items))
+		for i, m := range items {
+			reversed[len(items)-1-i] = m
+		}
+		items = reversed
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if offset > len(items) {
+		offset = len(items)
+	}
+	page := items[offset:]
+	if len(page) > limit {
+		page = page[:limit]
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("offset", strconv.Itoa(offset+limit))
+		w.Header().Add("Link", fmt.Sprintf(`<./%s?%s>; rel="next"`, path.Base(r.URL.Path), next.Encode()))
+	}
+	if page == nil {
+		page = []map[string]interface{}{}
+	}
+	writeFakeJSON(w, http.StatusOK, page)
+}
+
+func filterFakeItems(items []map[string]interface{}, keep func(int64) bool) []map[string]interface{} {
+	var kept []map[string]interface{}
+	for _, m := range items {
+		var ts int64
+		switch v := m["timestamp"].(type) {
+		case int64:
+			ts = v
+		case float64:
+			ts = int64(v)
+		}
+		if keep(ts) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+func (s *FakeServer) handleRealtime(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	ws.SetReadLimit(1 << 20)
+	c := &fakeConn{ws: ws, echo: query.Get("echo") != "false", reauthorized: make(chan struct{}, 1)}
+
+	fail := func(err *fakeError) {
+		c.send(&fakeProtocolMessage{Action: fakeActionError, Error: err})
+		ws.Close(websocket.StatusNormalClosure, "")
+	}
+	if query.Get("format") != "json" {
+		fail(&fakeError{StatusCode: 400, Code: 40000, Message: "only the JSON protocol is supported"})
+		return
+	}
+	switch {
+	case query.Get("access_token") != "":
+		details, err := s.token(query.Get("access_token"))
+		if err != nil {
+			fail(err)
+			return
+		}
+		c.clientID = details.ClientID
+		c.expires = details.Expires
+	case query.Get("key") == s.Key():
+		c.clientID = query.Get("clientId")
+	default:
+		fail(&fakeError{StatusCode: 401, Code: 40101, Message: "invalid credentials"})
+		return
+	}
+
+	connected := &fakeProtocolMessage{Action: fakeActionConnected}
+	s.mtx.Lock()
+	if key := query.Get("resume"); key != "" {
+		if resumed, ok := s.conns[key]; ok {
+			c.id, c.key = resumed.id, resumed.key
+			for _, t := range resumed.dropTimers {
+				t.Stop()
+			}
+		} else {
+			connected.Error = &fakeError{StatusCode: 400, Code: 80008, Message: "unable to recover connection"}
+		}
+	}
+	if c.id == "" {
+		c.id = s.newID("connection")
+		c.key = s.newID("connection-key")
+	}
+	s.conns[c.key] = c
+	s.mtx.Unlock()
+	connected.ConnectionID = c.id
+	connected.ConnectionDetails = c.details()
+	c.send(connected)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.heartbeats(ctx)
+	go s.expireToken(ctx, c)
+	s.serveConn(ctx, c)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for name, ch := range s.channels {
+		if ch.attached[c] {
+			delete(ch.attached, c)
+			if c.closing {
+				s.leave(name, c)
+			}
+		}
+	}
+	if c.closing {
+		return
+	}
+	// A dropped connection is kept in conns so that it can be resumed, and
+	// its presence members stay for a while.
+	c.dropTimers = []*time.Timer{
+		time.AfterFunc(fakePresenceGracePeriod, func() {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			if s.conns[c.key] != c {
+				return
+			}
+			for name := range s.channels {
+				s.leave(name, c)
+			}
+		}),
+		time.AfterFunc(fakeConnectionStateTTL, func() {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+			if s.conns[c.key] == c {
+				delete(s.conns, c.key)
+			}
+		}),
+	}
+}
+
+// expireToken disconnects c once its token expires, unless it's replaced
+// first.
+func (s *FakeServer) expireToken(ctx context.Context, c *fakeConn) {
+	for {
+		s.mtx.Lock()
+		expires := c.expires
+		s.mtx.Unlock()
+		if expires == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.reauthorized:
+				continue
+			}
+		}
+		timer := time.NewTimer(time.Until(time.Unix(0, expires*int64(time.Millisecond))))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-c.reauthorized:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		c.send(&fakeProtocolMessage{
+			Action: fakeActionDisconnected,
+			Error:  &fakeError{StatusCode: 401, Code: 40142, Message: "token expired"},
+		})
+		c.ws.Close(websocket.StatusNormalClosure, "")
+		return
+	}
+}
+
+func (c *fakeConn) details() *fakeConnectionDetails {
+	return &fakeConnectionDetails{
+		ClientID:           c.clientID,
+		ConnectionKey:      c.key,
+		MaxMessageSize:     65536,
+		ConnectionStateTTL: int64(fakeConnectionStateTTL / time.Millisecond),
+		MaxIdleInterval:    int64(fakeMaxIdleInterval / time.Millisecond),
+	}
+}
+
+func (c *fakeConn) send(msg *fakeProtocolMessage) {
+	p, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return
+	}
+	if err := c.ws.Write(context.Background(), websocket.MessageText, p); err != nil {
+		c.closed = true
+	}
+}
+
+func (c *fakeConn) heartbeats(ctx context.Context) {
+	ticker := time.NewTicker(fakeMaxIdleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.send(&fakeProtocolMessage{Action: fakeActionHeartbeat})
+		}
+	}
+}
+
+// serveConn handles the protocol messages received from a connection until
+// it's closed.
+func (s *FakeServer) serveConn(ctx context.Context, c *fakeConn) {
+	for {
+		_, p, err := c.ws.Read(ctx)
+		if err != nil {
+			return
+		}
+		var msg fakeProtocolMessage
+		if err := json.Unmarshal(p, &msg); err != nil {
+			c.send(&fakeProtocolMessage{Action: fakeActionError, Error: &fakeError{StatusCode: 400, Code: 40000, Message: err.Error()}})
+			continue
+		}
+		if !s.handleMessage(c, &msg) {
+			c.ws.Close(websocket.StatusNormalClosure, "")
+			return
+		}
+	}
+}
+
+// handleMessage handles a protocol message received from a connection,
+// returning false if the connection must be closed.
+func (s *FakeServer) handleMessage(c *fakeConn, msg *fakeProtocolMessage) bool {
+	ack := func() {
+		c.send(&fakeProtocolMessage{Action: fakeActionAck, MsgSerial: msg.MsgSerial, Count: 1})
+	}
+	nack := func(err *fakeError) {
+		c.send(&fakeProtocolMessage{Action: fakeActionNack, MsgSerial: msg.MsgSerial, Count: 1, Error: err})
+	}
+
+	switch msg.Action {
+	case fakeActionHeartbeat:
+		c.send(&fakeProtocolMessage{Action: fakeActionHeartbeat, ID: msg.ID})
+
+	case fakeActionAttach:
+		s.mtx.Lock()
+		ch := s.channel(msg.Channel)
+		ch.attached[c] = true
+		flags := msg.Flags & (fakeFlagPresence | fakeFlagPublish | fakeFlagSubscribe | fakeFlagPresenceSubscribe)
+		if flags == 0 {
+			flags = fakeFlagPresence | fakeFlagPublish | fakeFlagSubscribe | fakeFlagPresenceSubscribe
+		}
+		var members []map[string]interface{}
+		for _, m := range ch.members {
+			members = append(members, m)
+		}
+		if len(members) > 0 {
+			flags |= fakeFlagHasPresence
+		}
+		serial := strconv.Itoa(ch.serial)
+		// Send while holding mtx, so that nothing is broadcast on the channel
+		// before the connection gets ATTACHED and SYNC, and the messages it
+		// missed.
+		c.send(&fakeProtocolMessage{Action: fakeActionAttached, Channel: msg.Channel, ChannelSerial: serial, Flags: flags})
+		if len(members) > 0 {
+			c.send(&fakeProtocolMessage{Action: fakeActionSync, Channel: msg.Channel, ChannelSerial: "sync:", Presence: members})
+		}
+		if since, err := strconv.Atoi(msg.ChannelSerial); err == nil && msg.Flags&fakeFlagAttachResume != 0 {
+			for _, missed := range ch.broadcasts {
+				serial, _ := strconv.Atoi(missed.ChannelSerial)
+				if serial > since && (missed.ConnectionID != c.id || c.echo) {
+					c.send(missed)
+				}
+			}
+		}
+		s.mtx.Unlock()
+
+	case fakeActionDetach:
+		s.mtx.Lock()
+		ch := s.channel(msg.Channel)
+		if ch.attached[c] {
+			delete(ch.attached, c)
+			s.leave(msg.Channel, c)
+		}
+		s.mtx.Unlock()
+		c.send(&fakeProtocolMessage{Action: fakeActionDetached, Channel: msg.Channel})
+
+	case fakeActionMessage:
+		for _, m := range msg.Messages {
+			if id, ok := m["clientId"].(string); ok && c.clientID != "*" && c.clientID != "" && id != c.clientID {
+				nack(&fakeError{StatusCode: 401, Code: 40012, Message: "mismatched clientId"})
+				return true
+			}
+			if _, ok := m["clientId"]; !ok && c.clientID != "*" && c.clientID != "" {
+				m["clientId"] = c.clientID
+			}
+		}
+		s.mtx.Lock()
+		s.publish(msg.Channel, fmt.Sprintf("%s:%d", c.id, msg.MsgSerial), c.id, msg.Messages, c)
+		s.mtx.Unlock()
+		ack()
+
+	case fakeActionPresence:
+		for _, m := range msg.Presence {
+			id, _ := m["clientId"].(string)
+			switch {
+			case id == "" && (c.clientID == "" || c.clientID == "*"):
+				nack(&fakeError{StatusCode: 400, Code: 40012, Message: "presence requires a clientId"})
+				return true
+			case id != "" && c.clientID != "*" && id != c.clientID:
+				nack(&fakeError{StatusCode: 401, Code: 40012, Message: "mismatched clientId"})
+				return true
+			}
+		}
+		s.mtx.Lock()
+		s.presence(msg.Channel, fmt.Sprintf("%s:%d", c.id, msg.MsgSerial), c, msg.Presence)
+		s.mtx.Unlock()
+		ack()
+
+	case fakeActionAuth:
+		if msg.Auth == nil {
+			c.send(&fakeProtocolMessage{Action: fakeActionError, Error: &fakeError{StatusCode: 400, Code: 40000, Message: "missing auth details"}})
+			return false
+		}
+		details, err := s.token(msg.Auth.AccessToken)
+		if err != nil {
+			c.send(&fakeProtocolMessage{Action: fakeActionDisconnected, Error: err})
+			return false
+		}
+		if c.clientID != "" && c.clientID != "*" && details.ClientID != c.clientID {
+			c.send(&fakeProtocolMessage{Action: fakeActionError, Error: &fakeError{StatusCode: 401, Code: 40012, Message: "mismatched clientId"}})
+			return false
+		}
+		s.mtx.Lock()
+		c.expires = details.Expires
+		s.mtx.Unlock()
+		select {
+		case c.reauthorized <- struct{}{}:
+		default:
+		}
+		c.send(&fakeProtocolMessage{Action: fakeActionConnected, ConnectionID: c.id, ConnectionDetails: c.details()})
+
+	case fakeActionClose:
+		s.mtx.Lock()
+		// A closed connection can't be resumed.
+		c.closing = true
+		delete(s.conns, c.key)
+		s.mtx.Unlock()
+		c.send(&fakeProtocolMessage{Action: fakeActionClosed})
+		return false
+	}
+	return true
+}
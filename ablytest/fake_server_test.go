@@ -0,0 +1,215 @@
+//go:build !integration
+// +build !integration
+
+package ablytest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeServer_Realtime(t *testing.T) {
+	server := ablytest.NewFakeServer()
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	publisher := server.NewRealtime(ably.WithClientID("publisher"))
+	defer publisher.Close()
+	subscriber := server.NewRealtime(ably.WithClientID("subscriber"))
+	defer subscriber.Close()
+
+	err := ablytest.Wait(ablytest.ConnWaiter(publisher, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+
+	pubChannel := publisher.Channels.Get("test")
+	err = pubChannel.Presence.Enter(ctx, "here")
+	assert.NoError(t, err)
+
+	// The subscriber is synced the publisher's presence on attach.
+	subChannel := subscriber.Channels.Get("test")
+	messages := make(chan *ably.Message, 1)
+	unsubscribe, err := subChannel.SubscribeAll(ctx, func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+	members, err := subChannel.Presence.Get(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, members, 1) {
+		assert.Equal(t, "publisher", members[0].ClientID)
+		assert.Equal(t, "here", members[0].Data)
+	}
+
+	presence := make(chan *ably.PresenceMessage, 1)
+	unsubscribePresence, err := subChannel.Presence.SubscribeAll(ctx, func(m *ably.PresenceMessage) {
+		presence <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribePresence()
+
+	err = pubChannel.Publish(ctx, "event", "data")
+	assert.NoError(t, err)
+	var msg *ably.Message
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "event", msg.Name)
+	assert.Equal(t, "data", msg.Data)
+	assert.Equal(t, "publisher", msg.ClientID)
+	assert.Equal(t, publisher.Connection.ID(), msg.ConnectionID)
+
+	err = pubChannel.Detach(ctx)
+	assert.NoError(t, err)
+	var left *ably.PresenceMessage
+	ablytest.Soon.Recv(t, &left, presence, t.Fatalf)
+	assert.Equal(t, ably.PresenceActionLeave, left.Action)
+	assert.Equal(t, "publisher", left.ClientID)
+}
+
+func TestFakeServer_Resume(t *testing.T) {
+	server := ablytest.NewFakeServer()
+	defer server.Close()
+	proxy, err := ablytest.NewFaultProxy(server.URL())
+	assert.NoError(t, err)
+	defer proxy.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+	rest := server.NewREST()
+
+	client := server.NewRealtime(proxy.Options(ably.WithClientID("member"))...)
+	defer client.Close()
+	err = ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	id := client.Connection.ID()
+	channel := client.Channels.Get("test")
+	messages := make(chan *ably.Message, 1)
+	unsubscribe, err := channel.SubscribeAll(ctx, func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+	err = channel.Presence.Enter(ctx, nil)
+	assert.NoError(t, err)
+
+	// While the client is away, a message is published, and its presence
+	// member stays.
+	reattaching := make(chan struct{})
+	var once sync.Once
+	proxy.OnFrame(func(f *ablytest.Frame) bool {
+		if f.Conn.Index == 1 && f.Direction == ablytest.FrameToServer && f.Action() == "attach" {
+			once.Do(func() {
+				defer close(reattaching)
+				assert.NoError(t, rest.Channels.Get("test").Publish(ctx, "event", "missed"))
+				var members []*ably.PresenceMessage
+				assert.NoError(t, ablytest.AllPages(&members, rest.Channels.Get("test").Presence.Get()))
+				assert.Len(t, members, 1)
+			})
+		}
+		return false
+	}, ablytest.Drop())
+
+	disconnected := make(chan ably.ConnectionStateChange, 1)
+	off := client.Connection.Once(ably.ConnectionEventDisconnected, func(change ably.ConnectionStateChange) {
+		disconnected <- change
+	})
+	defer off()
+	server.DropConnections()
+	ablytest.Soon.Recv(t, nil, disconnected, t.Fatalf)
+
+	err = ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, id, client.Connection.ID())
+	ablytest.Soon.Recv(t, nil, reattaching, t.Fatalf)
+
+	// Once resumed, the client gets the message it missed.
+	var msg *ably.Message
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "missed", msg.Data)
+	ablytest.Instantly.NoRecv(t, nil, messages, t.Fatalf)
+	var members []*ably.PresenceMessage
+	err = ablytest.AllPages(&members, rest.Channels.Get("test").Presence.Get())
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+}
+
+func TestFakeServer_TokenExpiry(t *testing.T) {
+	server := ablytest.NewFakeServer()
+	defer server.Close()
+
+	client := server.NewRealtime(ably.WithDefaultTokenParams(ably.TokenParams{TTL: 1000}))
+	defer client.Close()
+	err := ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	id := client.Connection.ID()
+
+	// The server disconnects the client once its token expires; it then
+	// gets a new token and resumes, which is an UPDATE.
+	updated := make(chan ably.ConnectionStateChange, 1)
+	off := client.Connection.Once(ably.ConnectionEventUpdate, func(change ably.ConnectionStateChange) {
+		updated <- change
+	})
+	defer off()
+	ablytest.Soon.Recv(t, nil, updated, t.Fatalf)
+	assert.Equal(t, ably.ConnectionStateConnected, client.Connection.State())
+	assert.Equal(t, id, client.Connection.ID())
+}
+
+func TestFakeServer_REST(t *testing.T) {
+	server := ablytest.NewFakeServer()
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	client := server.NewREST()
+	serverTime, err := client.Time(ctx)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), serverTime, time.Minute)
+
+	_, err = client.Auth.RequestToken(ctx, &ably.TokenParams{RevocationKey: "group-1"})
+	assert.NoError(t, err, "expected a signed token request with a revocation key to be accepted")
+
+	channel := client.Channels.Get("test")
+	for _, name := range []string{"a", "b", "c"} {
+		err := channel.Publish(ctx, name, name)
+		assert.NoError(t, err)
+	}
+	var history []*ably.Message
+	err = ablytest.AllPages(&history, channel.History(ably.HistoryWithLimit(2)))
+	assert.NoError(t, err)
+	var names []string
+	for _, m := range history {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+
+	realtime := server.NewRealtime(ably.WithClientID("member"))
+	defer realtime.Close()
+	err = realtime.Channels.Get("test").Presence.Enter(ctx, nil)
+	assert.NoError(t, err)
+	var members []*ably.PresenceMessage
+	err = ablytest.AllPages(&members, channel.Presence.Get())
+	assert.NoError(t, err)
+	if assert.Len(t, members, 1) {
+		assert.Equal(t, "member", members[0].ClientID)
+	}
+	var presenceHistory []*ably.PresenceMessage
+	err = ablytest.AllPages(&presenceHistory, channel.Presence.History())
+	assert.NoError(t, err)
+	if assert.Len(t, presenceHistory, 1) {
+		assert.Equal(t, ably.PresenceActionEnter, presenceHistory[0].Action)
+	}
+
+	server.SetStats(&ably.Stats{IntervalID: "2024-01-01:10:00", Unit: "minute"})
+	var stats []*ably.Stats
+	err = ablytest.AllPages(&stats, client.Stats())
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, "2024-01-01:10:00", stats[0].IntervalID)
+	}
+}
@@ -0,0 +1,496 @@
+package ablytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+
+	"nhooyr.io/websocket"
+)
+
+var actionNames = map[int64]string{
+	fakeActionHeartbeat:    "heartbeat",
+	fakeActionAck:          "ack",
+	fakeActionNack:         "nack",
+	fakeActionConnect:      "connect",
+	fakeActionConnected:    "connected",
+	fakeActionDisconnect:   "disconnect",
+	fakeActionDisconnected: "disconnected",
+	fakeActionClose:        "close",
+	fakeActionClosed:       "closed",
+	fakeActionError:        "error",
+	fakeActionAttach:       "attach",
+	fakeActionAttached:     "attached",
+	fakeActionDetach:       "detach",
+	fakeActionDetached:     "detached",
+	fakeActionPresence:     "presence",
+	fakeActionMessage:      "message",
+	fakeActionSync:         "sync",
+	fakeActionAuth:         "auth",
+}
+
+// FaultProxy is a proxy between Realtime clients and Ably, or a FakeServer,
+// that injects faults into their connections as scripted by tests, so that
+// they can exercise what clients do when the network or Ably misbehaves,
+// e.g. resuming connections and resending pending messages.
+//
+// Protocol messages going through it can be dropped, delayed, duplicated or
+// reordered, and connections can be cut at a chosen protocol message; see
+// FaultProxy.OnFrame. Protocol messages can also be injected into
+// connections; see FaultConn.Inject. HTTP requests are proxied as they are.
+type FaultProxy struct {
+	upstream *url.URL
+	server   *httptest.Server
+
+	mtx   sync.Mutex
+	rules []*faultRule
+	conns []*FaultConn
+}
+
+// NewFaultProxy starts a FaultProxy to upstream, the base URL of Ably's
+// Realtime endpoint, e.g. "https://sandbox-realtime.ably.io", or
+// FakeServer.URL. It must be closed with Close.
+func NewFaultProxy(upstream string) (*FaultProxy, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	p := &FaultProxy{upstream: u}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = u.Host
+	}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			p.handleRealtime(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	return p, nil
+}
+
+// Close cuts the connections and stops the proxy.
+func (p *FaultProxy) Close() {
+	for _, c := range p.Conns() {
+		c.Cut()
+	}
+	p.server.Close()
+}
+
+// Options returns the options for a client going through the proxy, with
+// opts applied last.
+//
+// The proxy doesn't use TLS, so clients must use token authentication.
+func (p *FaultProxy) Options(opts ...ably.ClientOption) []ably.ClientOption {
+	host, port, _ := net.SplitHostPort(p.server.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return MergeOptions([]ably.ClientOption{
+		ably.WithTLS(false),
+		ably.WithRESTHost(host),
+		ably.WithRealtimeHost(host),
+		ably.WithPort(n),
+	}, opts)
+}
+
+// Conns returns the connections made through the proxy so far, in order.
+func (p *FaultProxy) Conns() []*FaultConn {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return append([]*FaultConn(nil), p.conns...)
+}
+
+// OnFrame makes the proxy apply fault to every protocol message for which
+// match returns true, until remove is called. Rules are tried in the order
+// they were added, and only the first matching one applies.
+func (p *FaultProxy) OnFrame(match func(*Frame) bool, fault Fault) (remove func()) {
+	return p.addRule(&faultRule{match: match, fault: fault, times: -1})
+}
+
+// OnNextFrame is like OnFrame, but fault only applies to the first protocol
+// message for which match returns true.
+func (p *FaultProxy) OnNextFrame(match func(*Frame) bool, fault Fault) (remove func()) {
+	return p.addRule(&faultRule{match: match, fault: fault, times: 1})
+}
+
+func (p *FaultProxy) addRule(rule *faultRule) (remove func()) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.rules = append(p.rules, rule)
+	return func() {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		for i, r := range p.rules {
+			if r == rule {
+				p.rules = append(p.rules[:i:i], p.rules[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// fault returns the fault to apply to f, if any.
+func (p *FaultProxy) fault(f *Frame) (Fault, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for i, r := range p.rules {
+		if !r.match(f) {
+			continue
+		}
+		if r.times > 0 {
+			r.times--
+			if r.times == 0 {
+				p.rules = append(p.rules[:i:i], p.rules[i+1:]...)
+			}
+		}
+		return r.fault, true
+	}
+	return Fault{}, false
+}
+
+type faultRule struct {
+	match func(*Frame) bool
+	fault Fault
+	times int // how many more times it applies, or -1 for always
+}
+
+// FrameDirection is the direction in which a Frame goes through a
+// FaultProxy.
+type FrameDirection int
+
+const (
+	// FrameToServer is a protocol message sent by the client.
+	FrameToServer FrameDirection = iota
+	// FrameToClient is a protocol message sent to the client.
+	FrameToClient
+)
+
+func (d FrameDirection) String() string {
+	if d == FrameToServer {
+		return "to server"
+	}
+	return "to client"
+}
+
+// A Frame is a protocol message going through a FaultProxy.
+type Frame struct {
+	Conn      *FaultConn
+	Direction FrameDirection
+	// Index is the number of protocol messages that went through Conn in
+	// Direction before this one, faulty or not.
+	Index int
+	// Message is the decoded protocol message. It's forwarded as it was
+	// received, so changing it has no effect.
+	Message map[string]interface{}
+
+	typ  websocket.MessageType
+	data []byte
+}
+
+// Action returns the name of the protocol message's action, as in the ably
+// package, e.g. "message" or "ack".
+func (f *Frame) Action() string {
+	n, _ := frameInt(f.Message["action"])
+	return actionNames[n]
+}
+
+// Channel returns the protocol message's channel, if any.
+func (f *Frame) Channel() string {
+	channel, _ := f.Message["channel"].(string)
+	return channel
+}
+
+// MatchAction returns a matcher, for FaultProxy.OnFrame, of the protocol
+// messages going in direction with any of the given actions.
+func MatchAction(direction FrameDirection, actions ...string) func(*Frame) bool {
+	return func(f *Frame) bool {
+		if f.Direction != direction {
+			return false
+		}
+		for _, action := range actions {
+			if f.Action() == action {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func frameInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// A Fault is what a FaultProxy does with a protocol message instead of
+// forwarding it.
+type Fault struct {
+	apply func(pipe *faultPipe, f *Frame)
+}
+
+// Drop drops the protocol message, e.g. to block ACKs. Protocol messages
+// held back by Reorder are forwarded instead.
+func Drop() Fault {
+	return Fault{apply: func(pipe *faultPipe, f *Frame) {
+		pipe.flush()
+	}}
+}
+
+// Delay delays the protocol message by d. The protocol messages after it in
+// the same direction are held back behind it, as on a slow network. If the
+// connection is cut meanwhile, it's never forwarded.
+func Delay(d time.Duration) Fault {
+	return Fault{apply: func(pipe *faultPipe, f *Frame) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			pipe.forward(f)
+		case <-f.Conn.Done():
+		}
+	}}
+}
+
+// Duplicate forwards the protocol message twice.
+func Duplicate() Fault {
+	return Fault{apply: func(pipe *faultPipe, f *Frame) {
+		pipe.forward(f)
+		pipe.forward(f)
+	}}
+}
+
+// Reorder holds the protocol message back until the next one in the same
+// direction has been forwarded or dropped. If the connection is cut first,
+// it's never forwarded.
+func Reorder() Fault {
+	return Fault{apply: func(pipe *faultPipe, f *Frame) {
+		pipe.held = append(pipe.held, f)
+	}}
+}
+
+// Cut cuts the connection instead of forwarding the protocol message, as if
+// the network failed.
+func Cut() Fault {
+	return Fault{apply: func(pipe *faultPipe, f *Frame) {
+		f.Conn.Cut()
+	}}
+}
+
+// A FaultConn is a Realtime connection going through a FaultProxy.
+type FaultConn struct {
+	// Index is the number of connections made through the proxy before this
+	// one.
+	Index int
+
+	msgpack bool
+	client  faultWriter
+	server  faultWriter
+
+	cutOnce sync.Once
+	cut     chan struct{}
+}
+
+// Inject sends msg through the connection in direction, as if sent by the
+// client or Ably.
+//
+// See DisconnectedMessage and ErrorMessage for protocol messages to inject.
+func (c *FaultConn) Inject(direction FrameDirection, msg map[string]interface{}) error {
+	var typ websocket.MessageType
+	var data []byte
+	var err error
+	if c.msgpack {
+		typ = websocket.MessageBinary
+		data, err = marshalMsgpack(msg)
+	} else {
+		typ = websocket.MessageText
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return err
+	}
+	if direction == FrameToServer {
+		return c.server.write(typ, data)
+	}
+	return c.client.write(typ, data)
+}
+
+// Cut cuts the connection, as if the network failed.
+func (c *FaultConn) Cut() {
+	c.cutOnce.Do(func() {
+		close(c.cut)
+		go c.client.ws.Close(websocket.StatusGoingAway, "connection cut")
+		go c.server.ws.Close(websocket.StatusGoingAway, "connection cut")
+	})
+}
+
+// Done returns a channel that's closed once the connection is cut, either
+// by FaultConn.Cut or by the client or Ably closing it.
+func (c *FaultConn) Done() <-chan struct{} {
+	return c.cut
+}
+
+// DisconnectedMessage returns a DISCONNECTED protocol message with the given
+// error, to be injected with FaultConn.Inject.
+func DisconnectedMessage(code, statusCode int, message string) map[string]interface{} {
+	return errorProtocolMessage(fakeActionDisconnected, code, statusCode, message)
+}
+
+// ErrorMessage returns an ERROR protocol message with the given error, to be
+// injected with FaultConn.Inject. Without a channel, it fails the connection.
+func ErrorMessage(channel string, code, statusCode int, message string) map[string]interface{} {
+	msg := errorProtocolMessage(fakeActionError, code, statusCode, message)
+	if channel != "" {
+		msg["channel"] = channel
+	}
+	return msg
+}
+
+func errorProtocolMessage(action, code, statusCode int, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"action": action,
+		"error": map[string]interface{}{
+			"code":       code,
+			"statusCode": statusCode,
+			"message":    message,
+		},
+	}
+}
+
+// faultWriter serializes writes to a side of a connection.
+type faultWriter struct {
+	ws  *websocket.Conn
+	mtx *sync.Mutex
+}
+
+func (w faultWriter) write(typ websocket.MessageType, data []byte) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.ws.Write(context.Background(), typ, data)
+}
+
+// faultPipe forwards the protocol messages going in a direction of a
+// connection, applying faults to them.
+type faultPipe struct {
+	proxy     *FaultProxy
+	conn      *FaultConn
+	direction FrameDirection
+	from      *websocket.Conn
+	to        faultWriter
+
+	held []*Frame // held back by Reorder
+}
+
+func (pipe *faultPipe) run() {
+	defer pipe.conn.Cut()
+	// Protocol messages still held back are lost with the connection.
+	defer func() { pipe.held = nil }()
+	for i := 0; ; i++ {
+		typ, data, err := pipe.from.Read(context.Background())
+		if err != nil {
+			return
+		}
+		f := &Frame{
+			Conn:      pipe.conn,
+			Direction: pipe.direction,
+			Index:     i,
+			typ:       typ,
+			data:      data,
+		}
+		if pipe.conn.msgpack {
+			err = unmarshalMsgpack(data, &f.Message)
+		} else {
+			err = json.Unmarshal(data, &f.Message)
+		}
+		if err != nil {
+			// Not a protocol message; forward it as it is.
+			pipe.to.write(typ, data)
+			continue
+		}
+		if fault, ok := pipe.proxy.fault(f); ok {
+			fault.apply(pipe, f)
+		} else {
+			pipe.forward(f)
+		}
+	}
+}
+
+// forward forwards f, and then the protocol messages held back before it.
+func (pipe *faultPipe) forward(f *Frame) {
+	pipe.to.write(f.typ, f.data)
+	pipe.flush()
+}
+
+// flush forwards the protocol messages held back.
+func (pipe *faultPipe) flush() {
+	held := pipe.held
+	pipe.held = nil
+	for _, f := range held {
+		pipe.to.write(f.typ, f.data)
+	}
+}
+
+func (p *FaultProxy) handleRealtime(w http.ResponseWriter, r *http.Request) {
+	u := *p.upstream
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = r.URL.Path
+	u.RawQuery = r.URL.RawQuery
+	header := http.Header{}
+	for _, h := range []string{"Ably-Agent", "X-Ably-Version"} {
+		if v := r.Header.Get(h); v != "" {
+			header.Set(h, v)
+		}
+	}
+	server, resp, err := websocket.Dial(r.Context(), u.String(), &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		status := http.StatusBadGateway
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		http.Error(w, fmt.Sprintf("dialing %s: %v", u.Host, err), status)
+		return
+	}
+	client, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		server.Close(websocket.StatusInternalError, "")
+		return
+	}
+	client.SetReadLimit(1 << 20)
+	server.SetReadLimit(1 << 20)
+
+	c := &FaultConn{
+		msgpack: r.URL.Query().Get("format") != "json",
+		client:  faultWriter{ws: client, mtx: &sync.Mutex{}},
+		server:  faultWriter{ws: server, mtx: &sync.Mutex{}},
+		cut:     make(chan struct{}),
+	}
+	p.mtx.Lock()
+	c.Index = len(p.conns)
+	p.conns = append(p.conns, c)
+	p.mtx.Unlock()
+
+	go (&faultPipe{proxy: p, conn: c, direction: FrameToServer, from: client, to: c.server}).run()
+	(&faultPipe{proxy: p, conn: c, direction: FrameToClient, from: server, to: c.client}).run()
+}
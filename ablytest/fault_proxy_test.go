@@ -0,0 +1,234 @@
+//go:build !integration
+// +build !integration
+
+package ablytest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/ably/ably-go/ablytest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFaultProxy(t *testing.T) (*ablytest.FakeServer, *ablytest.FaultProxy) {
+	t.Helper()
+	server := ablytest.NewFakeServer()
+	t.Cleanup(server.Close)
+	proxy, err := ablytest.NewFaultProxy(server.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(proxy.Close)
+	return server, proxy
+}
+
+func TestFaultProxy_CutResendsPending(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	client := server.NewRealtime(proxy.Options()...)
+	defer client.Close()
+	err := ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	id := client.Connection.ID()
+
+	// Cut the connection instead of forwarding the ACK, so that the message is
+	// resent once the connection is resumed.
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "ack"), ablytest.Cut())
+	err = client.Channels.Get("test").Publish(ctx, "event", "data")
+	assert.NoError(t, err)
+
+	conns := proxy.Conns()
+	if assert.Len(t, conns, 2) {
+		ablytest.Instantly.Recv(t, nil, conns[0].Done(), t.Fatalf)
+	}
+	assert.Equal(t, id, client.Connection.ID())
+
+	// Resent with the same connection ID and serial, it's only published once.
+	var history []*ably.Message
+	err = ablytest.AllPages(&history, server.NewREST().Channels.Get("test").History())
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestFaultProxy_InjectDisconnected(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	client := server.NewRealtime(proxy.Options()...)
+	defer client.Close()
+	err := ablytest.Wait(ablytest.ConnWaiter(client, nil, ably.ConnectionEventConnected), nil)
+	assert.NoError(t, err)
+	id := client.Connection.ID()
+
+	// With a token error, the client renews its token and reconnects.
+	err = proxy.Conns()[0].Inject(ablytest.FrameToClient, ablytest.DisconnectedMessage(40142, 401, "token expired"))
+	assert.NoError(t, err)
+	assert.True(t, ablytest.Soon.IsTrue(func() bool {
+		return len(proxy.Conns()) == 2
+	}))
+	err = client.Channels.Get("test").Publish(ctx, "event", "data")
+	assert.NoError(t, err)
+	assert.Equal(t, id, client.Connection.ID())
+	assert.Len(t, proxy.Conns(), 2)
+}
+
+func TestFaultProxy_ReorderAndDuplicate(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	subscriber := server.NewRealtime(proxy.Options()...)
+	defer subscriber.Close()
+	publisher := server.NewRealtime()
+	defer publisher.Close()
+
+	messages := make(chan *ably.Message, 4)
+	unsubscribe, err := subscriber.Channels.Get("test").SubscribeAll(ctx, func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Reorder())
+	for _, name := range []string{"a", "b"} {
+		err := publisher.Channels.Get("test").Publish(ctx, name, nil)
+		assert.NoError(t, err)
+	}
+	var msg *ably.Message
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "b", msg.Name)
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "a", msg.Name)
+
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Duplicate())
+	err = publisher.Channels.Get("test").Publish(ctx, "c", nil)
+	assert.NoError(t, err)
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "c", msg.Name)
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "c", msg.Name)
+}
+
+func TestFaultProxy_Drop(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	subscriber := server.NewRealtime(proxy.Options()...)
+	defer subscriber.Close()
+	publisher := server.NewRealtime()
+	defer publisher.Close()
+
+	messages := make(chan *ably.Message, 4)
+	unsubscribe, err := subscriber.Channels.Get("test").SubscribeAll(ctx, func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	// The message held back behind the dropped one is forwarded instead.
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Reorder())
+	err = publisher.Channels.Get("test").Publish(ctx, "a", nil)
+	assert.NoError(t, err)
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Drop())
+	err = publisher.Channels.Get("test").Publish(ctx, "b", nil)
+	assert.NoError(t, err)
+	err = publisher.Channels.Get("test").Publish(ctx, "c", nil)
+	assert.NoError(t, err)
+
+	var msg *ably.Message
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "a", msg.Name)
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "c", msg.Name)
+	ablytest.Instantly.NoRecv(t, nil, messages, t.Fatalf)
+}
+
+func TestFaultProxy_Delay(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	subscriber := server.NewRealtime(proxy.Options()...)
+	defer subscriber.Close()
+	publisher := server.NewRealtime()
+	defer publisher.Close()
+
+	messages := make(chan *ably.Message, 4)
+	unsubscribe, err := subscriber.Channels.Get("test").SubscribeAll(ctx, func(m *ably.Message) {
+		messages <- m
+	})
+	assert.NoError(t, err)
+	defer unsubscribe()
+
+	// The message after the delayed one is held back behind it.
+	const delay = 100 * time.Millisecond
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Delay(delay))
+	start := time.Now()
+	for _, name := range []string{"a", "b"} {
+		err := publisher.Channels.Get("test").Publish(ctx, name, nil)
+		assert.NoError(t, err)
+	}
+	var msg *ably.Message
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "a", msg.Name)
+	assert.GreaterOrEqual(t, time.Since(start), delay)
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "b", msg.Name)
+
+	// Once the connection is cut, the delayed message isn't forwarded; the
+	// client gets it once resumed instead, and only once.
+	proxy.OnNextFrame(ablytest.MatchAction(ablytest.FrameToClient, "message"), ablytest.Delay(time.Hour))
+	err = publisher.Channels.Get("test").Publish(ctx, "c", nil)
+	assert.NoError(t, err)
+	ablytest.Instantly.NoRecv(t, nil, messages, t.Fatalf)
+	proxy.Conns()[0].Cut()
+	ablytest.Soon.Recv(t, &msg, messages, t.Fatalf)
+	assert.Equal(t, "c", msg.Name)
+	ablytest.Instantly.NoRecv(t, nil, messages, t.Fatalf)
+	assert.Len(t, proxy.Conns(), 2)
+}
+
+func TestFaultProxy_InjectError(t *testing.T) {
+	server, proxy := newFaultProxy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), ablytest.Timeout)
+	defer cancel()
+
+	client := server.NewRealtime(proxy.Options()...)
+	defer client.Close()
+	channel := client.Channels.Get("test")
+	err := channel.Attach(ctx)
+	assert.NoError(t, err)
+
+	// With a channel, only the channel fails.
+	err = proxy.Conns()[0].Inject(ablytest.FrameToClient, ablytest.ErrorMessage("test", 40160, 401, "not permitted"))
+	assert.NoError(t, err)
+	assert.True(t, ablytest.Soon.IsTrue(func() bool {
+		return channel.State() == ably.ChannelStateFailed
+	}))
+	if assert.NotNil(t, channel.ErrorReason()) {
+		assert.Equal(t, ably.ErrorCode(40160), channel.ErrorReason().Code)
+	}
+	assert.Equal(t, ably.ConnectionStateConnected, client.Connection.State())
+
+	// Without one, the connection fails.
+	failed := make(chan ably.ConnectionStateChange, 1)
+	off := client.Connection.Once(ably.ConnectionEventFailed, func(change ably.ConnectionStateChange) {
+		failed <- change
+	})
+	defer off()
+	err = proxy.Conns()[0].Inject(ablytest.FrameToClient, ablytest.ErrorMessage("", 40000, 400, "bad request"))
+	assert.NoError(t, err)
+	var change ably.ConnectionStateChange
+	ablytest.Soon.Recv(t, &change, failed, t.Fatalf)
+	if assert.NotNil(t, change.Reason) {
+		assert.Equal(t, ably.ErrorCode(40000), change.Reason.Code)
+	}
+}
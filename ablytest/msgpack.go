@@ -30,3 +30,8 @@ func encodeMsg(w io.Writer, v interface{}) error {
 	enc := codec.NewEncoder(w, &handle)
 	return enc.Encode(v)
 }
+
+// unmarshalMsgpack decodes the msgpack byte array into v
+func unmarshalMsgpack(b []byte, v interface{}) error {
+	return codec.NewDecoderBytes(b, &handle).Decode(v)
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-
-	"github.com/ably/ably-go/ably"
 )
 
 // AllPages appends all items from all pages resulting from a paginated
@@ -123,17 +121,7 @@ func testPagination(request reflect.Value, expectedItems []interface{}, perPage
 		}
 		var gotItems []interface{}
 		for items.next() {
-			switch items.item().(type) {
-			case *ably.PresenceMessage:
-				item := *items.item().(*ably.PresenceMessage)
-				gotItems = append(gotItems, &item)
-			case *ably.Message:
-				item := *items.item().(*ably.Message)
-				gotItems = append(gotItems, &item)
-			case *ably.Stats:
-				item := *items.item().(*ably.Stats)
-				gotItems = append(gotItems, &item)
-			}
+			gotItems = append(gotItems, copyItem(items.item()))
 		}
 		if err := items.err(); err != nil {
 			return fmt.Errorf("iterating items: %w", err)
@@ -150,9 +138,72 @@ func testPagination(request reflect.Value, expectedItems []interface{}, perPage
 		}
 	}
 
+	if all := request.MethodByName("All"); all.IsValid() {
+		if err := testPaginationAll(request, expectedItems, opts); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// testPaginationAll tests the All and Collect methods of an ably.Paginated
+// request.
+func testPaginationAll(request reflect.Value, expectedItems []interface{}, opts paginationOptions) error {
+	ctx := reflect.ValueOf(context.Background())
+
+	var gotItems []interface{}
+	var iterErr error
+	yield := reflect.MakeFunc(
+		request.MethodByName("All").Type().Out(0).In(0),
+		func(args []reflect.Value) []reflect.Value {
+			if err, _ := args[1].Interface().(error); err != nil {
+				iterErr = err
+				return []reflect.Value{reflect.ValueOf(false)}
+			}
+			gotItems = append(gotItems, copyItem(args[0].Interface()))
+			return []reflect.Value{reflect.ValueOf(true)}
+		},
+	)
+	request.MethodByName("All").Call([]reflect.Value{ctx})[0].Call([]reflect.Value{yield})
+	if iterErr != nil {
+		return fmt.Errorf("iterating All: %w", iterErr)
+	}
+	opts.sortResult(gotItems)
+	if !ItemsEqual(expectedItems, gotItems, opts.equal) {
+		return fmt.Errorf("All: expected items: %+v, got: %+v", expectedItems, gotItems)
+	}
+
+	max := len(expectedItems) - 1
+	if max < 1 {
+		max = 1
+	}
+	ret := request.MethodByName("Collect").Call([]reflect.Value{ctx, reflect.ValueOf(max)})
+	if err, _ := ret[1].Interface().(error); err != nil {
+		return fmt.Errorf("calling Collect: %w", err)
+	}
+	expected := max
+	if len(expectedItems) < expected {
+		expected = len(expectedItems)
+	}
+	if ret[0].Len() != expected {
+		return fmt.Errorf("Collect(%d): expected %d items, got %d", max, expected, ret[0].Len())
+	}
+	return nil
+}
+
+// copyItem returns a shallow copy of the value pointed to by item if it's a
+// pointer, so that it isn't affected by later pages reusing it.
+func copyItem(item interface{}) interface{} {
+	v := reflect.ValueOf(item)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return item
+	}
+	copied := reflect.New(v.Elem().Type())
+	copied.Elem().Set(v.Elem())
+	return copied.Interface()
+}
+
 type paginated struct {
 	next    func() bool
 	first   func() error